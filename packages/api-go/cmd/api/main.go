@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"os"
@@ -44,6 +45,48 @@ func main() {
 		}
 	}()
 
+	go func() {
+		ticker := time.NewTicker(1 * time.Hour)
+		for range ticker.C {
+			if err := services.PurgeDeletedPlaylists(); err != nil {
+				log.Printf("Failed to purge deleted playlists: %v", err)
+			}
+			if err := services.PurgeSyncOperations(); err != nil {
+				log.Printf("Failed to purge sync operations: %v", err)
+			}
+			if err := services.ExpireWatchLater(); err != nil {
+				log.Printf("Failed to expire Watch Later items: %v", err)
+			}
+			if err := services.PurgeWatchHistory(); err != nil {
+				log.Printf("Failed to purge watch history: %v", err)
+			}
+			if err := services.PurgeEvents(); err != nil {
+				log.Printf("Failed to purge events: %v", err)
+			}
+		}
+	}()
+
+	// Recommendations and trending start empty, so compute them on boot too
+	go func() {
+		computeRankings := func() {
+			if err := services.ComputeRecommendations(); err != nil {
+				log.Printf("Failed to compute recommendations: %v", err)
+			}
+			if err := services.ComputeTrending(); err != nil {
+				log.Printf("Failed to compute trending posts: %v", err)
+			}
+		}
+		computeRankings()
+
+		ticker := time.NewTicker(1 * time.Hour)
+		for range ticker.C {
+			computeRankings()
+		}
+	}()
+
+	// Fan out change events from every API instance to connected devices
+	go services.ListenEvents(context.Background())
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
@@ -8,6 +8,8 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -78,3 +80,57 @@ func RunMigrations(migrationsDir string) error {
 
 	return nil
 }
+
+// DBTX is satisfied by both Pool and a pgx.Tx, so helpers can run inside or
+// outside a transaction.
+type DBTX interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+type txContextKey struct{}
+
+// WithTx returns a context whose queries run in tx, see Conn.
+func WithTx(ctx context.Context, tx pgx.Tx) context.Context {
+	return context.WithValue(ctx, txContextKey{}, tx)
+}
+
+// Conn returns the transaction stored in ctx by WithTx, or Pool if there is
+// none. Handlers query through Conn so they can be composed into a single
+// transaction.
+func Conn(ctx context.Context) DBTX {
+	if tx, ok := ctx.Value(txContextKey{}).(pgx.Tx); ok {
+		return tx
+	}
+	return Pool
+}
+
+// Begin starts a transaction, or a savepoint if ctx already holds one, so a
+// handler that needs its own transaction still runs inside a batch.
+func Begin(ctx context.Context) (pgx.Tx, error) {
+	if tx, ok := ctx.Value(txContextKey{}).(pgx.Tx); ok {
+		return tx.Begin(ctx)
+	}
+	return Pool.Begin(ctx)
+}
+
+// Savepoint runs fn in a savepoint if ctx holds a transaction, rolling back to
+// it when fn fails so the transaction can still commit. Best effort writes go
+// through it, as a failed statement otherwise aborts the whole transaction.
+// Outside a transaction fn simply runs.
+func Savepoint(ctx context.Context, fn func(ctx context.Context) error) error {
+	tx, ok := ctx.Value(txContextKey{}).(pgx.Tx)
+	if !ok {
+		return fn(ctx)
+	}
+	sp, err := tx.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	if err := fn(WithTx(ctx, sp)); err != nil {
+		sp.Rollback(ctx)
+		return err
+	}
+	return sp.Commit(ctx)
+}
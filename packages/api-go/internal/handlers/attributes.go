@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"math"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/models"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/services"
+)
+
+const maxEmojiRunes = 10 // Room for ZWJ sequences and skin tone modifiers
+
+const (
+	zeroWidthJoiner   = '\u200d'
+	variationSelector = '\ufe0f' // Emoji presentation
+	combiningKeycap   = '\u20e3'
+	cancelTag         = '\U000e007f'
+)
+
+// extendedPictographic is the Extended_Pictographic property from the Unicode
+// emoji data, which the standard library doesn't have.
+var extendedPictographic = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{0x00a9, 0x00ae, 5}, {0x203c, 0x2049, 13}, {0x2122, 0x2139, 23},
+		{0x2194, 0x2199, 1}, {0x21a9, 0x21aa, 1}, {0x231a, 0x231b, 1},
+		{0x2328, 0x2388, 96}, {0x23cf, 0x23cf, 1}, {0x23e9, 0x23f3, 1},
+		{0x23f8, 0x23fa, 1}, {0x24c2, 0x24c2, 1}, {0x25aa, 0x25ab, 1},
+		{0x25b6, 0x25c0, 10}, {0x25fb, 0x25fe, 1}, {0x2600, 0x2605, 1},
+		{0x2607, 0x2612, 1}, {0x2614, 0x2685, 1}, {0x2690, 0x2705, 1},
+		{0x2708, 0x2712, 1}, {0x2714, 0x2716, 2}, {0x271d, 0x2721, 4},
+		{0x2728, 0x2728, 1}, {0x2733, 0x2734, 1}, {0x2744, 0x2747, 3},
+		{0x274c, 0x274e, 2}, {0x2753, 0x2755, 1}, {0x2757, 0x2757, 1},
+		{0x2763, 0x2767, 1}, {0x2795, 0x2797, 1}, {0x27a1, 0x27b0, 15},
+		{0x27bf, 0x27bf, 1}, {0x2934, 0x2935, 1}, {0x2b05, 0x2b07, 1},
+		{0x2b1b, 0x2b1c, 1}, {0x2b50, 0x2b55, 5}, {0x3030, 0x303d, 13},
+		{0x3297, 0x3299, 2},
+	},
+	R32: []unicode.Range32{
+		{0x1f000, 0x1f0ff, 1}, {0x1f10d, 0x1f10f, 1}, {0x1f12f, 0x1f12f, 1},
+		{0x1f16c, 0x1f171, 1}, {0x1f17e, 0x1f17f, 1}, {0x1f18e, 0x1f18e, 1},
+		{0x1f191, 0x1f19a, 1}, {0x1f1ad, 0x1f1e5, 1}, {0x1f201, 0x1f20f, 1},
+		{0x1f21a, 0x1f22f, 21}, {0x1f232, 0x1f23a, 1}, {0x1f23c, 0x1f23f, 1},
+		{0x1f249, 0x1f3fa, 1}, {0x1f400, 0x1f53d, 1}, {0x1f546, 0x1f64f, 1},
+		{0x1f680, 0x1f6ff, 1}, {0x1f774, 0x1f77f, 1}, {0x1f7d5, 0x1f7ff, 1},
+		{0x1f80c, 0x1f80f, 1}, {0x1f848, 0x1f84f, 1}, {0x1f85a, 0x1f85f, 1},
+		{0x1f888, 0x1f88f, 1}, {0x1f8ae, 0x1f8ff, 1}, {0x1f90c, 0x1f93a, 1},
+		{0x1f93c, 0x1f945, 1}, {0x1f947, 0x1faff, 1}, {0x1fc00, 0x1fffd, 1},
+	},
+	LatinOffset: 1,
+}
+
+func isRegionalIndicator(r rune) bool { return r >= 0x1f1e6 && r <= 0x1f1ff }
+func isSkinTone(r rune) bool          { return r >= 0x1f3fb && r <= 0x1f3ff }
+func isTag(r rune) bool               { return r >= 0xe0020 && r <= 0xe007e }
+
+// isEmoji reports whether s is a single emoji: a keycap like 1️⃣, a flag, or
+// pictographs joined by zero width joiners, each optionally followed by a
+// presentation selector or skin tone. Subdivision flags end in tag characters.
+func isEmoji(s string) bool {
+	runes := []rune(s)
+	if len(runes) == 0 || len(runes) > maxEmojiRunes {
+		return false
+	}
+	if r := runes[0]; (r >= '0' && r <= '9') || r == '#' || r == '*' {
+		rest := runes[1:]
+		if len(rest) > 0 && rest[0] == variationSelector {
+			rest = rest[1:]
+		}
+		return len(rest) == 1 && rest[0] == combiningKeycap
+	}
+	if isRegionalIndicator(runes[0]) {
+		return len(runes) == 2 && isRegionalIndicator(runes[1])
+	}
+	for i := 0; ; i++ {
+		if i == len(runes) || !unicode.Is(extendedPictographic, runes[i]) {
+			return false
+		}
+		i++
+		if i < len(runes) && (runes[i] == variationSelector || isSkinTone(runes[i])) {
+			i++
+		}
+		if i < len(runes) && isTag(runes[i]) {
+			for i < len(runes) && isTag(runes[i]) {
+				i++
+			}
+			return i == len(runes)-1 && runes[i] == cancelTag
+		}
+		if i == len(runes) {
+			return true
+		}
+		if runes[i] != zeroWidthJoiner {
+			return false
+		}
+	}
+}
+
+var hexColor = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// PlaylistAttributes are the optional presentation fields accepted by
+// CreatePlaylist and UpdatePlaylist. On update, nil leaves a field unchanged
+// and an empty string clears it.
+type PlaylistAttributes struct {
+	Description  *string `json:"description"`
+	CoverVideoID *string `json:"cover_video_id"` // Post ID or floatplane.com post URL
+	Color        *string `json:"color"`          // #RRGGBB
+	Emoji        *string `json:"emoji"`
+	Pinned       *bool   `json:"pinned"`
+	SortOrder    *int    `json:"sort_order"`
+
+	RemoveWhenWatched *bool `json:"remove_when_watched"`
+}
+
+func (a PlaylistAttributes) isEmpty() bool {
+	return a.Description == nil && a.CoverVideoID == nil && a.Color == nil && a.Emoji == nil && a.Pinned == nil && a.SortOrder == nil && a.RemoveWhenWatched == nil
+}
+
+// validatePlaylistAttributes returns a message describing the first problem
+// with attrs, or "" if they are valid. It also normalises the cover video to
+// a post ID and the color to lower case. The description length is a quota,
+// see checkDescriptionQuota.
+func validatePlaylistAttributes(attrs *PlaylistAttributes) string {
+	if attrs.CoverVideoID != nil && *attrs.CoverVideoID != "" {
+		id, ok := services.ExtractPostID(*attrs.CoverVideoID)
+		if !ok {
+			return "cover_video_id must be a Floatplane post ID or URL"
+		}
+		attrs.CoverVideoID = &id
+	}
+	if attrs.Color != nil && *attrs.Color != "" {
+		if !hexColor.MatchString(*attrs.Color) {
+			return "color must be a hex color like #1a2b3c"
+		}
+		color := strings.ToLower(*attrs.Color)
+		attrs.Color = &color
+	}
+	if attrs.Emoji != nil && *attrs.Emoji != "" && !isEmoji(*attrs.Emoji) {
+		return "emoji must be a single emoji"
+	}
+	if attrs.SortOrder != nil && (*attrs.SortOrder < math.MinInt32 || *attrs.SortOrder > math.MaxInt32) {
+		return "sort_order is out of range"
+	}
+	return ""
+}
+
+// applyPlaylistAttributes copies the set fields of attrs onto p.
+func applyPlaylistAttributes(p *models.Playlist, attrs PlaylistAttributes) {
+	if attrs.Description != nil {
+		p.Description = *attrs.Description
+	}
+	if attrs.CoverVideoID != nil {
+		p.CoverVideoID = *attrs.CoverVideoID
+	}
+	if attrs.Color != nil {
+		p.Color = *attrs.Color
+	}
+	if attrs.Emoji != nil {
+		p.Emoji = *attrs.Emoji
+	}
+	if attrs.Pinned != nil {
+		p.Pinned = *attrs.Pinned
+	}
+	if attrs.SortOrder != nil {
+		p.SortOrder = *attrs.SortOrder
+	}
+	if attrs.RemoveWhenWatched != nil {
+		p.RemoveWhenWatched = *attrs.RemoveWhenWatched
+	}
+}
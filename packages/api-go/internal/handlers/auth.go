@@ -147,11 +147,7 @@ func Login(w http.ResponseWriter, r *http.Request) {
 		isNewUser = true
 
 		// Create WatchLater
-		_, _ = database.Pool.Exec(r.Context(), `
-			INSERT INTO playlists (floatplane_user_id, name, is_watch_later, video_ids, created_at, updated_at)
-			VALUES ($1, 'Watch Later', true, '{}', $2, $2)
-			ON CONFLICT DO NOTHING
-		`, fpUserID, time.Now())
+		_ = ensureWatchLater(r.Context(), fpUserID)
 	}
 
 	// 4. Check Device Session
@@ -206,7 +202,7 @@ func Login(w http.ResponseWriter, r *http.Request) {
 
 func Logout(w http.ResponseWriter, r *http.Request) {
 	// Authenticate via context (AuthMiddleware must run first)
-	_, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
 	if !ok {
 		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
 		return
@@ -245,6 +241,11 @@ func Logout(w http.ResponseWriter, r *http.Request) {
 		`, newKey, currentAPIKey)
 	}
 
+	// Tell the user's devices, and close this session's event streams
+	if session, ok := r.Context().Value(middleware.SessionContextKey).(*models.DeviceSession); ok {
+		publishEvent(r.Context(), user.FloatplaneUserID, services.EventSessionRevoked, map[string]string{"session_id": session.ID})
+	}
+
 	respondJSON(w, http.StatusOK, map[string]string{
 		"message": "Logged out successfully. API key invalidated.",
 	})
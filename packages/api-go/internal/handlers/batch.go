@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/database"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/middleware"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/models"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/services"
+)
+
+const maxBatchOperations = 100
+
+// BatchOperation is one step of a batch. Type is one of playlistOperations and
+// Data is the request body of the equivalent REST call.
+type BatchOperation struct {
+	Type       string          `json:"type"`
+	PlaylistID string          `json:"playlist_id,omitempty"`
+	Data       json.RawMessage `json:"data,omitempty"`
+}
+
+// BatchOperationResult is the outcome of a BatchOperation. Status is applied,
+// rolled_back (it succeeded but a later operation failed) or failed.
+type BatchOperationResult struct {
+	Status string          `json:"status"`
+	Code   int             `json:"code"`
+	Result json.RawMessage `json:"result,omitempty"`
+}
+
+type BatchResponse struct {
+	Committed   bool                   `json:"committed"`
+	FailedIndex *int                   `json:"failed_index,omitempty"`
+	Results     []BatchOperationResult `json:"results"`
+}
+
+// PostBatch applies an ordered list of playlist and Watch Later operations in
+// one transaction. If any operation fails, everything is rolled back and the
+// response has the failed operation's status code; operations after it are
+// not attempted.
+func PostBatch(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+
+	var req struct {
+		Operations []BatchOperation `json:"operations"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Invalid request body")
+		return
+	}
+	if len(req.Operations) == 0 || len(req.Operations) > maxBatchOperations {
+		respondError(w, http.StatusBadRequest, "Bad Request", fmt.Sprintf("operations must contain between 1 and %d operations", maxBatchOperations))
+		return
+	}
+	for i, op := range req.Operations {
+		spec, ok := playlistOperations[op.Type]
+		if !ok {
+			respondError(w, http.StatusBadRequest, "Bad Request", fmt.Sprintf("Unknown type %q in operation %d", op.Type, i))
+			return
+		}
+		if spec.target && op.PlaylistID == "" {
+			respondError(w, http.StatusBadRequest, "Bad Request", fmt.Sprintf("Missing playlist_id in operation %d", i))
+			return
+		}
+	}
+
+	tx, err := database.Pool.Begin(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to start transaction")
+		return
+	}
+	defer tx.Rollback(r.Context())
+	ctx := database.WithTx(r.Context(), tx)
+	// Operations lock rows as they go, so take the write lock up front, as
+	// the operations that need it would otherwise take it after a row lock
+	if err := services.LockPlaylistWrites(ctx, user.FloatplaneUserID); err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to start transaction")
+		return
+	}
+
+	results := make([]BatchOperationResult, 0, len(req.Operations))
+	for i, op := range req.Operations {
+		code, body := runPlaylistOperation(ctx, r, playlistOperations[op.Type], op.PlaylistID, op.Data)
+		if code >= 300 {
+			for j := range results {
+				results[j].Status = "rolled_back"
+			}
+			results = append(results, BatchOperationResult{Status: "failed", Code: code, Result: body})
+			respondJSON(w, code, BatchResponse{Committed: false, FailedIndex: &i, Results: results})
+			return
+		}
+		results = append(results, BatchOperationResult{Status: "applied", Code: code, Result: body})
+	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		log.Printf("Failed to commit batch: %v", err)
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to commit batch")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, BatchResponse{Committed: true, Results: results})
+}
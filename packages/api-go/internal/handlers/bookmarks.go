@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/database"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/middleware"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/models"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/services"
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	maxBookmarkLabelLength = 200
+	defaultBookmarkLimit   = 100
+	maxBookmarkLimit       = 500
+)
+
+// bookmarkColumns is the column list scanned by scanBookmark, with b as
+// bookmarks and fp as a LEFT JOIN on fp_posts.
+const bookmarkColumns = `b.id, b.video_id, b.timestamp_seconds, b.label, b.note, b.created_at, b.updated_at,
+	COALESCE(fp.title, ''), COALESCE(fp.thumbnail_url, '')`
+
+func scanBookmark(row rowScanner, b *models.Bookmark) error {
+	return row.Scan(&b.ID, &b.VideoID, &b.TimestampSeconds, &b.Label, &b.Note, &b.CreatedAt, &b.UpdatedAt, &b.Title, &b.ThumbnailURL)
+}
+
+// validateBookmark returns a message describing the first invalid field, or
+// "" if all are valid. The note's length is a quota, see checkNoteQuota.
+func validateBookmark(timestamp float64, label string) string {
+	switch {
+	case timestamp < 0:
+		return "timestamp_seconds must be zero or more"
+	case utf8.RuneCountInString(label) > maxBookmarkLabelLength:
+		return fmt.Sprintf("label can be at most %d characters", maxBookmarkLabelLength)
+	}
+	return ""
+}
+
+// GetBookmarks lists the user's bookmarks with the post's title and
+// thumbnail where known.
+//
+// Query parameters:
+//   - video_id: only bookmarks in this post, ordered by timestamp
+//   - playlist_id: only bookmarks in posts on this playlist
+//   - limit: max bookmarks (default 100, max 500)
+//
+// Without video_id, bookmarks are listed newest first.
+func GetBookmarks(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+
+	limit := defaultBookmarkLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxBookmarkLimit {
+			respondError(w, http.StatusBadRequest, "Bad Request", fmt.Sprintf("limit must be between 1 and %d", maxBookmarkLimit))
+			return
+		}
+		limit = n
+	}
+
+	args := []any{user.FloatplaneUserID, limit}
+	cond := ""
+	order := "b.created_at DESC, b.id DESC"
+	if v := r.URL.Query().Get("video_id"); v != "" {
+		videoID, ok := services.NormalizeVideoID(v)
+		if !ok {
+			respondError(w, http.StatusBadRequest, "Bad Request", "Invalid video_id")
+			return
+		}
+		args = append(args, videoID)
+		cond += fmt.Sprintf(" AND b.video_id = $%d", len(args))
+		order = "b.timestamp_seconds, b.id"
+	}
+	if playlistID := r.URL.Query().Get("playlist_id"); playlistID != "" {
+		if _, ok := requirePlaylistRole(w, r, playlistID, user, RoleViewer); !ok {
+			return
+		}
+		var p models.Playlist
+		err := scanPlaylist(database.Conn(r.Context()).QueryRow(r.Context(), `
+			SELECT `+playlistColumns+` FROM playlists WHERE id = $1 AND deleted_at IS NULL
+		`, playlistID), &p)
+		if errors.Is(err, pgx.ErrNoRows) {
+			respondError(w, http.StatusNotFound, "Not Found", "Playlist not found or access denied")
+			return
+		}
+		if err == nil {
+			err = resolveSmartPlaylist(r.Context(), &p)
+		}
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to fetch playlist")
+			return
+		}
+		videoIDs := p.VideoIDs
+		if videoIDs == nil {
+			videoIDs = []string{}
+		}
+		args = append(args, videoIDs)
+		cond += fmt.Sprintf(" AND b.video_id = ANY($%d)", len(args))
+	}
+
+	rows, err := database.Conn(r.Context()).Query(r.Context(), `
+		SELECT `+bookmarkColumns+`
+		FROM bookmarks b
+		LEFT JOIN fp_posts fp ON fp.id = b.video_id
+		WHERE b.floatplane_user_id = $1`+cond+`
+		ORDER BY `+order+`
+		LIMIT $2
+	`, args...)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to fetch bookmarks")
+		return
+	}
+	defer rows.Close()
+
+	bookmarks := []models.Bookmark{}
+	for rows.Next() {
+		var b models.Bookmark
+		if err := scanBookmark(rows, &b); err != nil {
+			respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to scan bookmark")
+			return
+		}
+		bookmarks = append(bookmarks, b)
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"bookmarks": bookmarks,
+		"count":     len(bookmarks),
+	})
+}
+
+// CreateBookmark saves a moment in a post.
+func CreateBookmark(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+
+	var req struct {
+		VideoID          string   `json:"video_id"`
+		TimestampSeconds *float64 `json:"timestamp_seconds"`
+		Label            string   `json:"label"`
+		Note             string   `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Invalid request body")
+		return
+	}
+	videoID, ok := services.NormalizeVideoID(req.VideoID)
+	if !ok {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Invalid video_id")
+		return
+	}
+	if req.TimestampSeconds == nil {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Missing timestamp_seconds")
+		return
+	}
+	if msg := validateBookmark(*req.TimestampSeconds, req.Label); msg != "" {
+		respondError(w, http.StatusBadRequest, "Bad Request", msg)
+		return
+	}
+	if !checkNoteQuota(w, req.Note) {
+		return
+	}
+
+	var b models.Bookmark
+	err := scanBookmark(database.Conn(r.Context()).QueryRow(r.Context(), `
+		WITH b AS (
+			INSERT INTO bookmarks (floatplane_user_id, video_id, timestamp_seconds, label, note, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $6)
+			RETURNING *
+		)
+		SELECT `+bookmarkColumns+` FROM b LEFT JOIN fp_posts fp ON fp.id = b.video_id
+	`, user.FloatplaneUserID, videoID, *req.TimestampSeconds, req.Label, req.Note, time.Now()), &b)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to save bookmark")
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, b)
+}
+
+// UpdateBookmark changes a bookmark's timestamp, label or note. Fields left
+// out keep their value.
+func UpdateBookmark(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Not Found", "Bookmark not found")
+		return
+	}
+
+	var req struct {
+		TimestampSeconds *float64 `json:"timestamp_seconds"`
+		Label            *string  `json:"label"`
+		Note             *string  `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Invalid request body")
+		return
+	}
+	var timestamp float64
+	var label string
+	if req.TimestampSeconds != nil {
+		timestamp = *req.TimestampSeconds
+	}
+	if req.Label != nil {
+		label = *req.Label
+	}
+	if msg := validateBookmark(timestamp, label); msg != "" {
+		respondError(w, http.StatusBadRequest, "Bad Request", msg)
+		return
+	}
+	if req.Note != nil && !checkNoteQuota(w, *req.Note) {
+		return
+	}
+
+	var b models.Bookmark
+	err = scanBookmark(database.Conn(r.Context()).QueryRow(r.Context(), `
+		WITH b AS (
+			UPDATE bookmarks
+			SET timestamp_seconds = COALESCE($3, timestamp_seconds),
+			    label = COALESCE($4, label),
+			    note = COALESCE($5, note),
+			    updated_at = $6
+			WHERE id = $1 AND floatplane_user_id = $2
+			RETURNING *
+		)
+		SELECT `+bookmarkColumns+` FROM b LEFT JOIN fp_posts fp ON fp.id = b.video_id
+	`, id, user.FloatplaneUserID, req.TimestampSeconds, req.Label, req.Note, time.Now()), &b)
+	if errors.Is(err, pgx.ErrNoRows) {
+		respondError(w, http.StatusNotFound, "Not Found", "Bookmark not found")
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to update bookmark")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, b)
+}
+
+// DeleteBookmark deletes a bookmark.
+func DeleteBookmark(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Not Found", "Bookmark not found")
+		return
+	}
+
+	tag, err := database.Conn(r.Context()).Exec(r.Context(), `
+		DELETE FROM bookmarks WHERE id = $1 AND floatplane_user_id = $2
+	`, id, user.FloatplaneUserID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to delete bookmark")
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		respondError(w, http.StatusNotFound, "Not Found", "Bookmark not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
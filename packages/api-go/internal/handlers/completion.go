@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/database"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/middleware"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// Completion modes
+const (
+	CompletionModePercent = "percent" // Finished at a percentage of the duration
+	CompletionModeCredits = "credits" // Finished when the client reports the credits, or at the end
+)
+
+// CompletionSettings decide when a video counts as finished, and whether
+// finishing it removes it from Watch Later. Playlists opt in separately with
+// remove_when_watched.
+type CompletionSettings struct {
+	Mode                 string     `json:"mode"`
+	Percent              int        `json:"percent"`
+	RemoveFromWatchLater bool       `json:"remove_from_watch_later"`
+	UpdatedAt            *time.Time `json:"updated_at,omitempty"`
+}
+
+var defaultCompletionSettings = CompletionSettings{
+	Mode:                 CompletionModePercent,
+	Percent:              90,
+	RemoveFromWatchLater: true,
+}
+
+// loadCompletionSettings returns the user's completion settings, or the
+// defaults if they haven't set any.
+func loadCompletionSettings(ctx context.Context, userID string) (CompletionSettings, error) {
+	s := defaultCompletionSettings
+	err := database.Conn(ctx).QueryRow(ctx, `
+		SELECT mode, percent, remove_from_watch_later, updated_at
+		FROM watch_completion_settings WHERE floatplane_user_id = $1
+	`, userID).Scan(&s.Mode, &s.Percent, &s.RemoveFromWatchLater, &s.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return defaultCompletionSettings, nil
+	}
+	return s, err
+}
+
+// isComplete reports whether a position counts as finishing the video.
+// duration is nil if unknown, in which case only reported credits count.
+func (s CompletionSettings) isComplete(position float64, duration *float64, creditsReached bool) bool {
+	if s.Mode == CompletionModeCredits {
+		return creditsReached || (duration != nil && position >= *duration)
+	}
+	return duration != nil && position >= *duration*float64(s.Percent)/100
+}
+
+// removeWatchedVideo takes a finished video out of the user's Watch Later (if
+// enabled) and their playlists flagged remove_when_watched. Each change bumps
+// the playlist's sync version and is recorded as a remove revision, so other
+// devices pick it up. It returns the IDs of the playlists changed.
+func removeWatchedVideo(ctx context.Context, userID, videoID string, settings CompletionSettings) ([]string, error) {
+	rows, err := database.Conn(ctx).Query(ctx, `
+		UPDATE playlists SET video_ids = array_remove(video_ids, $2), updated_at = $3
+		WHERE floatplane_user_id = $1 AND deleted_at IS NULL AND NOT is_smart AND $2 = ANY(video_ids)
+		  AND ((is_watch_later AND $4) OR (NOT is_watch_later AND remove_when_watched))
+		RETURNING `+playlistColumns+`
+	`, userID, videoID, time.Now(), settings.RemoveFromWatchLater)
+	if err != nil {
+		return nil, err
+	}
+	var changed []models.Playlist
+	for rows.Next() {
+		var p models.Playlist
+		if err := scanPlaylist(rows, &p); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		changed = append(changed, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(changed))
+	for i := range changed {
+		syncPlaylistItems(ctx, changed[i].ID, userID, changed[i].VideoIDs)
+		recordRevision(ctx, &changed[i], RevisionRemove)
+		ids[i] = changed[i].ID
+	}
+	return ids, nil
+}
+
+// GetCompletionSettings returns the user's completion settings.
+func GetCompletionSettings(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+
+	s, err := loadCompletionSettings(r.Context(), user.FloatplaneUserID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to fetch completion settings")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, s)
+}
+
+// UpdateCompletionSettings changes the user's completion settings. Fields
+// left out keep their current value.
+func UpdateCompletionSettings(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+
+	s, err := loadCompletionSettings(r.Context(), user.FloatplaneUserID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to fetch completion settings")
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Invalid request body")
+		return
+	}
+	if s.Mode != CompletionModePercent && s.Mode != CompletionModeCredits {
+		respondError(w, http.StatusBadRequest, "Bad Request", "mode must be percent or credits")
+		return
+	}
+	if s.Percent < 1 || s.Percent > 100 {
+		respondError(w, http.StatusBadRequest, "Bad Request", "percent must be between 1 and 100")
+		return
+	}
+
+	err = database.Conn(r.Context()).QueryRow(r.Context(), `
+		INSERT INTO watch_completion_settings (floatplane_user_id, mode, percent, remove_from_watch_later, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (floatplane_user_id) DO UPDATE
+		SET mode = EXCLUDED.mode, percent = EXCLUDED.percent,
+		    remove_from_watch_later = EXCLUDED.remove_from_watch_later, updated_at = EXCLUDED.updated_at
+		RETURNING updated_at
+	`, user.FloatplaneUserID, s.Mode, s.Percent, s.RemoveFromWatchLater, time.Now()).Scan(&s.UpdatedAt)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to save completion settings")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, s)
+}
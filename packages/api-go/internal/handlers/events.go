@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/database"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/middleware"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/models"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/services"
+	"github.com/gorilla/websocket"
+)
+
+// eventPingInterval keeps idle connections open through proxies.
+const eventPingInterval = 25 * time.Second
+
+// eventResync tells a device it missed events and must refetch its state.
+const eventResync = "resync"
+
+// publishEvent pushes a change to the acting user's other devices. Failures
+// are logged rather than failing the change; devices still see it on their
+// next refresh.
+func publishEvent(ctx context.Context, userID, eventType string, data interface{}) {
+	var sessionID string
+	if session, ok := ctx.Value(middleware.SessionContextKey).(*models.DeviceSession); ok {
+		sessionID = session.ID
+	}
+	err := database.Savepoint(ctx, func(ctx context.Context) error {
+		return services.PublishEvent(ctx, userID, sessionID, eventType, data)
+	})
+	if err != nil {
+		log.Printf("Failed to publish %s event: %v", eventType, err)
+	}
+}
+
+// publishPlaylistEvent pushes a playlist's new contents. Watch Later gets its
+// own event type, as most clients show it separately.
+func publishPlaylistEvent(ctx context.Context, userID string, p *models.Playlist, action string) {
+	eventType := services.EventPlaylistChanged
+	if p.IsWatchLater || p.Name == WatchLaterName {
+		eventType = services.EventWatchLaterChanged
+	}
+	videoIDs := p.VideoIDs
+	if videoIDs == nil {
+		videoIDs = []string{}
+	}
+	publishEvent(ctx, userID, eventType, map[string]interface{}{
+		"playlist_id": p.ID,
+		"action":      action,
+		"name":        p.Name,
+		"video_ids":   videoIDs,
+	})
+}
+
+// eventSink writes events to one connection, as SSE or WebSocket.
+type eventSink interface {
+	send(eventType string, e *models.UserEvent) error
+	ping() error
+}
+
+// StreamEvents pushes the user's changes from other devices as they happen,
+// over a WebSocket if the client asks to upgrade and as server-sent events
+// otherwise. A reconnecting client sends the last event ID it saw, in the
+// Last-Event-ID header or ?last_event_id=, to get what it missed.
+func StreamEvents(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+	var sessionID string
+	if session, ok := r.Context().Value(middleware.SessionContextKey).(*models.DeviceSession); ok {
+		sessionID = session.ID
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+	var lastID int64
+	if lastEventID != "" {
+		id, err := strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil || id < 0 {
+			respondError(w, http.StatusBadRequest, "Bad Request", "Invalid Last-Event-ID")
+			return
+		}
+		lastID = id
+	}
+
+	var sink eventSink
+	var closeSink func()
+	ctx := r.Context()
+	if websocket.IsWebSocketUpgrade(r) {
+		ws, ok := upgradeWebSocket(w, r)
+		if !ok {
+			return
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		go ws.readLoop(cancel)
+		sink, closeSink = ws, func() { cancel(); ws.close() }
+	} else {
+		sse, err := startSSE(w)
+		if err != nil {
+			// The 200 has already been sent, so all that's left is to hang up
+			log.Printf("Failed to start event stream: %v", err)
+			return
+		}
+		sink, closeSink = sse, func() {}
+	}
+	defer closeSink()
+
+	// Subscribe before replaying so nothing published in between is missed
+	sub := services.Events.Subscribe(user.FloatplaneUserID, sessionID)
+	defer services.Events.Unsubscribe(sub)
+
+	var last services.EventCursor
+	if lastEventID != "" {
+		var events []models.UserEvent
+		cursor, complete, err := services.EventCursorAt(ctx, user.FloatplaneUserID, lastID)
+		if err == nil && complete {
+			last = cursor
+			events, complete, err = services.EventsSince(ctx, user.FloatplaneUserID, cursor)
+		}
+		if err != nil {
+			log.Printf("Failed to replay events: %v", err)
+			complete = false
+		}
+		if !complete {
+			if sink.send(eventResync, nil) != nil {
+				return
+			}
+		}
+		for i := range events {
+			if sub.Wants(events[i]) && sink.send(events[i].Type, &events[i]) != nil {
+				return
+			}
+			last = services.CursorOf(events[i])
+		}
+	}
+
+	ticker := time.NewTicker(eventPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-sub.C:
+			if !last.Before(services.CursorOf(e)) {
+				continue // Already sent in the replay
+			}
+			if sink.send(e.Type, &e) != nil {
+				return
+			}
+			last = services.CursorOf(e)
+		case <-sub.Done:
+			// Dropped for falling behind or a revoked session. Flush what
+			// was queued, including the revocation itself.
+			for {
+				select {
+				case e := <-sub.C:
+					if last.Before(services.CursorOf(e)) && sink.send(e.Type, &e) != nil {
+						return
+					}
+				default:
+					return
+				}
+			}
+		case <-ticker.C:
+			if sink.ping() != nil {
+				return
+			}
+		}
+	}
+}
+
+// sseSink writes server-sent events.
+type sseSink struct {
+	w  http.ResponseWriter
+	rc *http.ResponseController
+}
+
+func startSSE(w http.ResponseWriter) (*sseSink, error) {
+	s := &sseSink{w: w, rc: http.NewResponseController(w)}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // Stop nginx buffering the stream
+	w.WriteHeader(http.StatusOK)
+	if _, err := fmt.Fprint(w, "retry: 3000\n\n"); err != nil {
+		return nil, err
+	}
+	return s, s.rc.Flush()
+}
+
+func (s *sseSink) send(eventType string, e *models.UserEvent) error {
+	var err error
+	if e == nil {
+		_, err = fmt.Fprintf(s.w, "event: %s\ndata: {}\n\n", eventType)
+	} else {
+		data, _ := json.Marshal(e)
+		_, err = fmt.Fprintf(s.w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, eventType, data)
+	}
+	if err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+func (s *sseSink) ping() error {
+	if _, err := fmt.Fprint(s.w, ": ping\n\n"); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
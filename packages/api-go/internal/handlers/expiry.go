@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/database"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/middleware"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/models"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/services"
+	"github.com/jackc/pgx/v5"
+)
+
+const maxExpiryAgeDays = 3650
+
+// WatchLaterExpiry is a user's Watch Later expiry rules. A nil rule is off.
+type WatchLaterExpiry struct {
+	MaxAgeDays *int       `json:"max_age_days"`
+	MaxItems   *int       `json:"max_items"`
+	UpdatedAt  *time.Time `json:"updated_at,omitempty"`
+}
+
+// ExpiredItem is a video the expiry job removed from Watch Later.
+type ExpiredItem struct {
+	VideoID   string    `json:"video_id"`
+	AddedAt   time.Time `json:"added_at"`
+	ExpiredAt time.Time `json:"expired_at"`
+	Reason    string    `json:"reason"` // max_age, max_items
+}
+
+// GetWatchLaterExpiry returns the user's Watch Later expiry rules.
+func GetWatchLaterExpiry(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+
+	var e WatchLaterExpiry
+	err := database.Conn(r.Context()).QueryRow(r.Context(), `
+		SELECT max_age_days, max_items, updated_at FROM watch_later_expiry WHERE floatplane_user_id = $1
+	`, user.FloatplaneUserID).Scan(&e.MaxAgeDays, &e.MaxItems, &e.UpdatedAt)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to fetch expiry rules")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, e)
+}
+
+// UpdateWatchLaterExpiry replaces the user's Watch Later expiry rules and
+// applies them straight away rather than waiting for the next scheduled run.
+func UpdateWatchLaterExpiry(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+
+	var req WatchLaterExpiry
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Invalid request body")
+		return
+	}
+	if req.MaxAgeDays != nil && (*req.MaxAgeDays < 1 || *req.MaxAgeDays > maxExpiryAgeDays) {
+		respondError(w, http.StatusBadRequest, "Bad Request", fmt.Sprintf("max_age_days must be between 1 and %d", maxExpiryAgeDays))
+		return
+	}
+	if limit := services.UserLimits().MaxItemsPerPlaylist; req.MaxItems != nil && (*req.MaxItems < 1 || *req.MaxItems > limit) {
+		respondError(w, http.StatusBadRequest, "Bad Request", fmt.Sprintf("max_items must be between 1 and %d", limit))
+		return
+	}
+
+	var e WatchLaterExpiry
+	err := database.Conn(r.Context()).QueryRow(r.Context(), `
+		INSERT INTO watch_later_expiry (floatplane_user_id, max_age_days, max_items, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (floatplane_user_id) DO UPDATE
+		SET max_age_days = EXCLUDED.max_age_days, max_items = EXCLUDED.max_items, updated_at = EXCLUDED.updated_at
+		RETURNING max_age_days, max_items, updated_at
+	`, user.FloatplaneUserID, req.MaxAgeDays, req.MaxItems, time.Now()).Scan(&e.MaxAgeDays, &e.MaxItems, &e.UpdatedAt)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to save expiry rules")
+		return
+	}
+
+	expired, err := services.ExpireWatchLaterForUser(r.Context(), user.FloatplaneUserID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to apply expiry rules")
+		return
+	}
+	if expired == nil {
+		expired = []string{}
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"max_age_days": e.MaxAgeDays,
+		"max_items":    e.MaxItems,
+		"updated_at":   e.UpdatedAt,
+		"expired":      expired,
+	})
+}
+
+// GetExpiredWatchLater lists videos that expired from Watch Later and can
+// still be restored, most recently expired first.
+func GetExpiredWatchLater(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+
+	limit := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 500 {
+			respondError(w, http.StatusBadRequest, "Bad Request", "limit must be between 1 and 500")
+			return
+		}
+		limit = n
+	}
+
+	rows, err := database.Conn(r.Context()).Query(r.Context(), `
+		SELECT video_id, added_at, expired_at, reason FROM watch_later_expired
+		WHERE floatplane_user_id = $1 AND expired_at >= $2
+		ORDER BY expired_at DESC, video_id
+		LIMIT $3
+	`, user.FloatplaneUserID, time.Now().Add(-services.ExpiredRetention), limit)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to fetch expired items")
+		return
+	}
+	defer rows.Close()
+
+	items := []ExpiredItem{}
+	for rows.Next() {
+		var item ExpiredItem
+		if err := rows.Scan(&item.VideoID, &item.AddedAt, &item.ExpiredAt, &item.Reason); err != nil {
+			respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to scan expired item")
+			return
+		}
+		items = append(items, item)
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"items": items,
+		"count": len(items),
+	})
+}
+
+// RestoreExpiredWatchLater puts expired videos back at the end of Watch Later,
+// in the order they were originally added. Restored videos count as newly
+// added, so the expiry rules don't remove them again straight away.
+func RestoreExpiredWatchLater(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+
+	var req struct {
+		VideoIDs []string `json:"video_ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.VideoIDs) == 0 {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Missing video_ids")
+		return
+	}
+
+	tx, err := database.Pool.Begin(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to start transaction")
+		return
+	}
+	defer tx.Rollback(r.Context())
+	r = r.WithContext(database.WithTx(r.Context(), tx))
+	db := database.Conn(r.Context())
+	if err := services.LockPlaylistWrites(r.Context(), user.FloatplaneUserID); err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to restore expired items")
+		return
+	}
+
+	rows, err := db.Query(r.Context(), `
+		DELETE FROM watch_later_expired
+		WHERE floatplane_user_id = $1 AND video_id = ANY($2) AND expired_at >= $3
+		RETURNING video_id, added_at
+	`, user.FloatplaneUserID, req.VideoIDs, time.Now().Add(-services.ExpiredRetention))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to restore expired items")
+		return
+	}
+	var restored []ExpiredItem
+	for rows.Next() {
+		var item ExpiredItem
+		if err := rows.Scan(&item.VideoID, &item.AddedAt); err != nil {
+			rows.Close()
+			respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to scan expired item")
+			return
+		}
+		restored = append(restored, item)
+	}
+	rows.Close()
+	if len(restored) == 0 {
+		respondError(w, http.StatusNotFound, "Not Found", "No matching expired items")
+		return
+	}
+
+	if err := ensureWatchLater(r.Context(), user.FloatplaneUserID); err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to create Watch Later")
+		return
+	}
+	var p models.Playlist
+	err = db.QueryRow(r.Context(), `
+		SELECT id, video_ids FROM playlists WHERE floatplane_user_id = $1 AND is_watch_later = true FOR UPDATE
+	`, user.FloatplaneUserID).Scan(&p.ID, &p.VideoIDs)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to fetch Watch Later")
+		return
+	}
+
+	present := make(map[string]bool, len(p.VideoIDs))
+	for _, vid := range p.VideoIDs {
+		present[vid] = true
+	}
+	sort.SliceStable(restored, func(i, j int) bool { return restored[i].AddedAt.Before(restored[j].AddedAt) })
+	before := len(p.VideoIDs)
+	for _, item := range restored {
+		if !present[item.VideoID] {
+			present[item.VideoID] = true
+			p.VideoIDs = append(p.VideoIDs, item.VideoID)
+		}
+	}
+	if !checkItemQuota(w, len(p.VideoIDs)) {
+		return
+	}
+
+	err = db.QueryRow(r.Context(), `
+		UPDATE playlists SET video_ids = $1, updated_at = $2 WHERE id = $3
+		RETURNING id, name, video_ids, updated_at
+	`, p.VideoIDs, time.Now(), p.ID).Scan(&p.ID, &p.Name, &p.VideoIDs, &p.UpdatedAt)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to update Watch Later")
+		return
+	}
+	syncPlaylistItems(r.Context(), p.ID, user.FloatplaneUserID, p.VideoIDs)
+	if len(p.VideoIDs) != before {
+		recordRevision(r.Context(), &p, RevisionAdd)
+	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to restore expired items")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"id":         p.ID,
+		"video_ids":  p.VideoIDs,
+		"updated_at": p.UpdatedAt,
+		"restored":   len(restored),
+	})
+}
@@ -0,0 +1,385 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/database"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/middleware"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/models"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/services"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	maxImportBytes      = 5 << 20
+	maxUnresolvedLength = 200 // Characters; unresolved values are truncated so garbage isn't echoed back in full
+)
+
+// ExportItem is a single entry in an exported playlist.
+type ExportItem struct {
+	VideoID string `json:"video_id"`
+	Title   string `json:"title,omitempty"`
+	URL     string `json:"url,omitempty"`
+}
+
+// ExportedPlaylist is the JSON export format. It is also accepted by import.
+type ExportedPlaylist struct {
+	Name       string       `json:"name"`
+	ExportedAt time.Time    `json:"exported_at"`
+	Items      []ExportItem `json:"items"`
+	VideoIDs   []string     `json:"video_ids,omitempty"` // Import only
+}
+
+// UnresolvedLine is an import entry that didn't contain a recognisable post ID.
+type UnresolvedLine struct {
+	Line  int    `json:"line"`
+	Value string `json:"value"`
+}
+
+type ImportPlaylistResponse struct {
+	Playlist   models.Playlist  `json:"playlist"`
+	Imported   int              `json:"imported"`
+	Duplicates int              `json:"duplicates"`
+	Unresolved []UnresolvedLine `json:"unresolved"`
+}
+
+var unsafeFilenameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// ExportPlaylist exports a playlist as JSON, CSV or M3U.
+func ExportPlaylist(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+	id := chi.URLParam(r, "id")
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "csv" && format != "m3u" {
+		respondError(w, http.StatusBadRequest, "Bad Request", "format must be json, csv or m3u")
+		return
+	}
+
+	if _, ok := requirePlaylistRole(w, r, id, user, RoleViewer); !ok {
+		return
+	}
+
+	var p models.Playlist
+	err := database.Conn(r.Context()).QueryRow(r.Context(), `
+		SELECT name, video_ids, is_smart, smart_rules FROM playlists WHERE id = $1
+	`, id).Scan(&p.Name, &p.VideoIDs, &p.IsSmart, &p.SmartRules)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Not Found", "Playlist not found")
+		return
+	}
+	if err := resolveSmartPlaylist(r.Context(), &p); err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to resolve smart playlist")
+		return
+	}
+
+	posts, err := fetchPostsByIDs(r.Context(), p.VideoIDs)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to fetch posts")
+		return
+	}
+
+	export := ExportedPlaylist{Name: p.Name, ExportedAt: time.Now(), Items: []ExportItem{}}
+	for _, vid := range p.VideoIDs {
+		export.Items = append(export.Items, ExportItem{
+			VideoID: vid,
+			Title:   posts[vid].Title,
+			URL:     services.PostURL(vid),
+		})
+	}
+
+	filename := unsafeFilenameChars.ReplaceAllString(p.Name, "_") + "." + format
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	switch format {
+	case "json":
+		respondJSON(w, http.StatusOK, export)
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"video_id", "title", "url"})
+		for _, item := range export.Items {
+			cw.Write([]string{item.VideoID, item.Title, item.URL})
+		}
+		cw.Flush()
+	case "m3u":
+		w.Header().Set("Content-Type", "audio/x-mpegurl; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "#EXTM3U\n#PLAYLIST:%s\n", p.Name)
+		for _, item := range export.Items {
+			duration := -1
+			if post, ok := posts[item.VideoID]; ok && post.VideoDuration > 0 {
+				duration = post.VideoDuration
+			}
+			title := item.Title
+			if title == "" {
+				title = item.VideoID
+			}
+			fmt.Fprintf(w, "#EXTINF:%d,%s\n%s\n", duration, title, item.URL)
+		}
+	}
+}
+
+// ImportPlaylist creates a playlist from a JSON, CSV or M3U export, or from a
+// plain list of floatplane.com URLs. The format is taken from ?format= or
+// detected from the body. Post IDs are deduplicated in order and entries that
+// couldn't be resolved are reported back.
+func ImportPlaylist(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImportBytes))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Failed to read request body")
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = detectImportFormat(body)
+	}
+
+	var parsed parsedImport
+	switch format {
+	case "json":
+		parsed, err = parseJSONImport(body)
+	case "csv":
+		parsed, err = parseCSVImport(body)
+	case "m3u", "text":
+		parsed, err = parseLineImport(body)
+	default:
+		respondError(w, http.StatusBadRequest, "Bad Request", "format must be json, csv, m3u or text")
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Failed to parse "+format+": "+err.Error())
+		return
+	}
+
+	name := r.URL.Query().Get("name")
+	if isReservedName(name) {
+		respondError(w, http.StatusBadRequest, "Bad Request", `"Watch Later" is a reserved name`)
+		return
+	}
+	if name == "" && !isReservedName(parsed.name) { // An exported Watch Later gets the default name
+		name = parsed.name
+	}
+	if name == "" {
+		name = "Imported Playlist"
+	}
+	if !checkNameQuota(w, name) {
+		return
+	}
+
+	// Dedupe preserving first occurrence
+	seen := make(map[string]bool)
+	videoIDs := []string{}
+	duplicates := 0
+	for _, vid := range parsed.ids {
+		if seen[vid] {
+			duplicates++
+			continue
+		}
+		seen[vid] = true
+		videoIDs = append(videoIDs, vid)
+	}
+	if !checkItemQuota(w, len(videoIDs)) {
+		return
+	}
+
+	ctx := r.Context()
+	tx, err := database.Begin(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to start transaction")
+		return
+	}
+	defer tx.Rollback(ctx)
+	r = r.WithContext(database.WithTx(ctx, tx))
+
+	if !checkPlaylistQuota(w, r, user.FloatplaneUserID, 1) {
+		return
+	}
+
+	var p models.Playlist
+	err = scanPlaylist(database.Conn(r.Context()).QueryRow(r.Context(), `
+		INSERT INTO playlists (floatplane_user_id, name, video_ids, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $4)
+		RETURNING `+playlistColumns+`
+	`, user.FloatplaneUserID, name, videoIDs, time.Now()), &p)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to import playlist")
+		return
+	}
+	syncPlaylistItems(r.Context(), p.ID, user.FloatplaneUserID, p.VideoIDs)
+	recordRevision(r.Context(), &p, RevisionCreate)
+	p.Role = RoleOwner
+	if err := tx.Commit(r.Context()); err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to import playlist")
+		return
+	}
+	p.UnknownVideoIDs = unknownVideoIDs(ctx, p.VideoIDs)
+
+	respondJSON(w, http.StatusCreated, ImportPlaylistResponse{
+		Playlist:   p,
+		Imported:   len(videoIDs),
+		Duplicates: duplicates,
+		Unresolved: parsed.unresolved,
+	})
+}
+
+type parsedImport struct {
+	name       string
+	ids        []string
+	unresolved []UnresolvedLine
+}
+
+func (p *parsedImport) add(line int, value string, id string, ok bool) {
+	if ok {
+		p.ids = append(p.ids, id)
+	} else {
+		if utf8.RuneCountInString(value) > maxUnresolvedLength {
+			value = string([]rune(value)[:maxUnresolvedLength])
+		}
+		p.unresolved = append(p.unresolved, UnresolvedLine{Line: line, Value: value})
+	}
+}
+
+func detectImportFormat(body []byte) string {
+	trimmed := bytes.TrimSpace(body)
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		return "json"
+	case bytes.HasPrefix(trimmed, []byte("#EXTM3U")):
+		return "m3u"
+	case bytes.HasPrefix(trimmed, []byte("video_id,")):
+		return "csv"
+	}
+	return "text"
+}
+
+// parseJSONImport accepts the export format, or an object with a video_ids array.
+// Line numbers refer to the 1-based position in items or video_ids.
+func parseJSONImport(body []byte) (parsedImport, error) {
+	var doc ExportedPlaylist
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return parsedImport{}, err
+	}
+
+	parsed := parsedImport{name: doc.Name, unresolved: []UnresolvedLine{}}
+	for i, item := range doc.Items {
+		value := item.VideoID
+		if value == "" {
+			value = item.URL
+		}
+		id, ok := services.ExtractPostID(value)
+		parsed.add(i+1, value, id, ok)
+	}
+	for i, value := range doc.VideoIDs {
+		id, ok := services.ExtractPostID(value)
+		parsed.add(len(doc.Items)+i+1, value, id, ok)
+	}
+	return parsed, nil
+}
+
+// parseCSVImport reads the video_id and url columns when there is a header row.
+// Without one, a URL in any column or a bare ID in the first column is used.
+func parseCSVImport(body []byte) (parsedImport, error) {
+	cr := csv.NewReader(bytes.NewReader(body))
+	cr.FieldsPerRecord = -1
+	records, err := cr.ReadAll()
+	if err != nil {
+		return parsedImport{}, err
+	}
+
+	parsed := parsedImport{unresolved: []UnresolvedLine{}}
+	idCol, urlCol := -1, -1
+	start := 0
+	if len(records) > 0 {
+		for i, field := range records[0] {
+			switch strings.ToLower(strings.TrimSpace(field)) {
+			case "video_id", "id":
+				idCol = i
+			case "url":
+				urlCol = i
+			}
+		}
+		if idCol >= 0 || urlCol >= 0 {
+			start = 1
+		}
+	}
+
+	for i := start; i < len(records); i++ {
+		record := records[i]
+		line := i + 1
+		id, ok := "", false
+		if start == 1 {
+			if idCol >= 0 && idCol < len(record) && record[idCol] != "" {
+				id, ok = services.ExtractPostID(record[idCol])
+			}
+			if !ok && urlCol >= 0 && urlCol < len(record) {
+				id, ok = services.ExtractPostIDFromURL(record[urlCol])
+			}
+		} else {
+			for _, field := range record {
+				if id, ok = services.ExtractPostIDFromURL(field); ok {
+					break
+				}
+			}
+			if !ok && len(record) > 0 {
+				id, ok = services.ExtractPostID(record[0])
+			}
+		}
+		parsed.add(line, strings.Join(record, ","), id, ok)
+	}
+	return parsed, nil
+}
+
+// parseLineImport handles M3U and plain text: one URL or ID per line. Blank
+// lines and # comments are skipped, except the M3U #PLAYLIST name.
+func parseLineImport(body []byte) (parsedImport, error) {
+	parsed := parsedImport{unresolved: []UnresolvedLine{}}
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	// Any line that fits in the body fits in the buffer
+	scanner.Buffer(nil, maxImportBytes+1)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		if strings.HasPrefix(text, "#") {
+			if name, ok := strings.CutPrefix(text, "#PLAYLIST:"); ok {
+				parsed.name = strings.TrimSpace(name)
+			}
+			continue
+		}
+		id, ok := services.ExtractPostID(text)
+		parsed.add(line, text, id, ok)
+	}
+	if err := scanner.Err(); err != nil {
+		return parsedImport{}, err
+	}
+	return parsed, nil
+}
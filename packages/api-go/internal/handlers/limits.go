@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"unicode/utf8"
+
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/database"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/middleware"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/models"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/services"
+)
+
+// Quota names reported in quota_exceeded errors
+const (
+	QuotaPlaylists         = "playlists"
+	QuotaItemsPerPlaylist  = "items_per_playlist"
+	QuotaNameLength        = "name_length"
+	QuotaDescriptionLength = "description_length"
+	QuotaNoteLength        = "note_length"
+)
+
+// respondQuotaExceeded writes a quota_exceeded error naming the quota and its
+// limit, so clients can tell it apart from other 403s.
+func respondQuotaExceeded(w http.ResponseWriter, quota string, limit int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":   "quota_exceeded",
+		"message": message,
+		"quota":   quota,
+		"limit":   limit,
+	})
+}
+
+// checkPlaylistQuota checks the user can own adding more playlists and writes
+// the error response if not. Callers should return when it returns false.
+//
+// It must run in the transaction that then adds the playlists. It takes the
+// user's playlist write lock first, so concurrent creates can't both pass the
+// count.
+func checkPlaylistQuota(w http.ResponseWriter, r *http.Request, userID string, adding int) bool {
+	limit := services.UserLimits().MaxPlaylists
+	if err := services.LockPlaylistWrites(r.Context(), userID); err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to check playlist quota")
+		return false
+	}
+	count, err := countOwnedPlaylists(r, userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to check playlist quota")
+		return false
+	}
+	if count+adding > limit {
+		respondQuotaExceeded(w, QuotaPlaylists, limit, fmt.Sprintf("You can have at most %d playlists", limit))
+		return false
+	}
+	return true
+}
+
+func countOwnedPlaylists(r *http.Request, userID string) (int, error) {
+	var count int
+	err := database.Conn(r.Context()).QueryRow(r.Context(), `
+		SELECT COUNT(*) FROM playlists
+		WHERE floatplane_user_id = $1 AND deleted_at IS NULL AND NOT is_watch_later
+	`, userID).Scan(&count)
+	return count, err
+}
+
+// checkItemQuota checks a playlist may hold n videos.
+func checkItemQuota(w http.ResponseWriter, n int) bool {
+	limit := services.UserLimits().MaxItemsPerPlaylist
+	if n > limit {
+		respondQuotaExceeded(w, QuotaItemsPerPlaylist, limit, fmt.Sprintf("A playlist can hold at most %d videos", limit))
+		return false
+	}
+	return true
+}
+
+// checkNameQuota checks the length of a playlist name. Empty names are left
+// to the caller.
+func checkNameQuota(w http.ResponseWriter, name string) bool {
+	limit := services.UserLimits().MaxNameLength
+	if utf8.RuneCountInString(name) > limit {
+		respondQuotaExceeded(w, QuotaNameLength, limit, fmt.Sprintf("Playlist names can be at most %d characters", limit))
+		return false
+	}
+	return true
+}
+
+// checkDescriptionQuota checks the length of a playlist description, if set.
+func checkDescriptionQuota(w http.ResponseWriter, attrs PlaylistAttributes) bool {
+	limit := services.UserLimits().MaxDescriptionLength
+	if attrs.Description != nil && utf8.RuneCountInString(*attrs.Description) > limit {
+		respondQuotaExceeded(w, QuotaDescriptionLength, limit, fmt.Sprintf("Descriptions can be at most %d characters", limit))
+		return false
+	}
+	return true
+}
+
+// checkNoteQuota checks the length of a bookmark note.
+func checkNoteQuota(w http.ResponseWriter, note string) bool {
+	limit := services.UserLimits().MaxNoteLength
+	if utf8.RuneCountInString(note) > limit {
+		respondQuotaExceeded(w, QuotaNoteLength, limit, fmt.Sprintf("Notes can be at most %d characters", limit))
+		return false
+	}
+	return true
+}
+
+// GetAccountLimits returns the user's quotas and current usage.
+func GetAccountLimits(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+
+	var usage struct {
+		Playlists            int `json:"playlists"`
+		LargestPlaylistItems int `json:"largest_playlist_items"`
+		WatchLaterItems      int `json:"watch_later_items"`
+	}
+	err := database.Conn(r.Context()).QueryRow(r.Context(), `
+		SELECT COUNT(*) FILTER (WHERE NOT is_watch_later),
+		       COALESCE(MAX(cardinality(video_ids)) FILTER (WHERE NOT is_watch_later), 0),
+		       COALESCE(MAX(cardinality(video_ids)) FILTER (WHERE is_watch_later), 0)
+		FROM playlists
+		WHERE floatplane_user_id = $1 AND deleted_at IS NULL
+	`, user.FloatplaneUserID).Scan(&usage.Playlists, &usage.LargestPlaylistItems, &usage.WatchLaterItems)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to fetch usage")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"limits": services.UserLimits(),
+		"usage":  usage,
+	})
+}
@@ -1,12 +1,58 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"net/http"
 
 	"github.com/coulterpeterson/floatnative/packages/api-go/internal/database"
 	"github.com/coulterpeterson/floatnative/packages/api-go/internal/models"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/services"
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
 )
 
+// fpPostColumns is the column list scanned by scanFPPost.
+const fpPostColumns = `id, title, creator_id, creator_name, channel_id, channel_title, channel_icon_url, thumbnail_url,
+		       has_video, video_count, video_duration, has_audio, audio_count, audio_duration,
+		       has_picture, picture_count, is_featured, has_gallery, gallery_count, release_date, created_at, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanFPPost(row rowScanner, p *models.FPPost) error {
+	return row.Scan(
+		&p.ID, &p.Title, &p.CreatorID, &p.CreatorName, &p.ChannelID, &p.ChannelTitle, &p.ChannelIconURL, &p.ThumbnailURL,
+		&p.HasVideo, &p.VideoCount, &p.VideoDuration, &p.HasAudio, &p.AudioCount, &p.AudioDuration,
+		&p.HasPicture, &p.PictureCount, &p.IsFeatured, &p.HasGallery, &p.GalleryCount, &p.ReleaseDate, &p.CreatedAt, &p.UpdatedAt,
+	)
+}
+
+// fetchPostsByIDs returns the known fp_posts for the given IDs, keyed by ID.
+// IDs without a matching post are simply absent from the map.
+func fetchPostsByIDs(ctx context.Context, ids []string) (map[string]models.FPPost, error) {
+	posts := make(map[string]models.FPPost)
+	if len(ids) == 0 {
+		return posts, nil
+	}
+
+	rows, err := database.Conn(ctx).Query(ctx, `SELECT `+fpPostColumns+` FROM fp_posts WHERE id = ANY($1)`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p models.FPPost
+		if err := scanFPPost(rows, &p); err != nil {
+			return nil, err
+		}
+		posts[p.ID] = p
+	}
+	return posts, rows.Err()
+}
+
 func SearchLTT(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query().Get("q")
 	if query == "" {
@@ -15,10 +61,8 @@ func SearchLTT(w http.ResponseWriter, r *http.Request) {
 	}
 
 	searchQuery := "%" + query + "%"
-	rows, err := database.Pool.Query(r.Context(), `
-		SELECT id, title, creator_id, creator_name, channel_id, channel_title, channel_icon_url, thumbnail_url,
-		       has_video, video_count, video_duration, has_audio, audio_count, audio_duration,
-		       has_picture, picture_count, is_featured, has_gallery, gallery_count, release_date, created_at, updated_at
+	rows, err := database.Conn(r.Context()).Query(r.Context(), `
+		SELECT `+fpPostColumns+`
 		FROM fp_posts
 		WHERE title ILIKE $1
 		ORDER BY release_date DESC
@@ -34,11 +78,7 @@ func SearchLTT(w http.ResponseWriter, r *http.Request) {
 	var posts []models.FPPost
 	for rows.Next() {
 		var p models.FPPost
-		if err := rows.Scan(
-			&p.ID, &p.Title, &p.CreatorID, &p.CreatorName, &p.ChannelID, &p.ChannelTitle, &p.ChannelIconURL, &p.ThumbnailURL,
-			&p.HasVideo, &p.VideoCount, &p.VideoDuration, &p.HasAudio, &p.AudioCount, &p.AudioDuration,
-			&p.HasPicture, &p.PictureCount, &p.IsFeatured, &p.HasGallery, &p.GalleryCount, &p.ReleaseDate, &p.CreatedAt, &p.UpdatedAt,
-		); err != nil {
+		if err := scanFPPost(rows, &p); err != nil {
 			continue // Skip bad rows? or error out. SKipping for robustness.
 		}
 		posts = append(posts, p)
@@ -50,3 +90,33 @@ func SearchLTT(w http.ResponseWriter, r *http.Request) {
 
 	respondJSON(w, http.StatusOK, posts)
 }
+
+// GetPostChapters returns the chapters listed in a post's description.
+// Posts without a chapter list return an empty list.
+func GetPostChapters(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	var text string
+	var duration int
+	err := database.Conn(r.Context()).QueryRow(r.Context(), `
+		SELECT text, video_duration FROM fp_posts WHERE id = $1
+	`, id).Scan(&text, &duration)
+	if errors.Is(err, pgx.ErrNoRows) {
+		respondError(w, http.StatusNotFound, "Not Found", "Post not found")
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to fetch post")
+		return
+	}
+
+	chapters := services.ParseChapters(text, duration)
+	if chapters == nil {
+		chapters = []models.Chapter{}
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"post_id":  id,
+		"chapters": chapters,
+		"count":    len(chapters),
+	})
+}
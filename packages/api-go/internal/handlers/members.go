@@ -0,0 +1,399 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/database"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/middleware"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/models"
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	RoleViewer = "viewer"
+	RoleEditor = "editor"
+	RoleOwner  = "owner"
+)
+
+var roleRank = map[string]int{
+	RoleViewer: 1,
+	RoleEditor: 2,
+	RoleOwner:  3,
+}
+
+const inviteTTL = 7 * 24 * time.Hour
+
+// playlistRole returns the user's role on a playlist. The playlist's creator is
+// always an owner. Returns pgx.ErrNoRows if the user has no access or the
+// playlist is in the trash.
+func playlistRole(ctx context.Context, playlistID, userID string) (string, error) {
+	return lookupPlaylistRole(ctx, playlistID, userID, false)
+}
+
+// lookupPlaylistRole is playlistRole for either live (deleted = false) or
+// trashed (deleted = true) playlists.
+func lookupPlaylistRole(ctx context.Context, playlistID, userID string, deleted bool) (string, error) {
+	var role string
+	err := database.Conn(ctx).QueryRow(ctx, `
+		SELECT CASE WHEN p.floatplane_user_id = $2 THEN 'owner' ELSE m.role END
+		FROM playlists p
+		LEFT JOIN playlist_members m ON m.playlist_id = p.id AND m.floatplane_user_id = $2
+		WHERE p.id = $1 AND (p.floatplane_user_id = $2 OR m.role IS NOT NULL)
+		  AND (p.deleted_at IS NOT NULL) = $3
+	`, playlistID, userID, deleted).Scan(&role)
+	return role, err
+}
+
+// requirePlaylistRole checks the user has at least minRole on the playlist and
+// writes the error response if not. Callers should return when ok is false.
+func requirePlaylistRole(w http.ResponseWriter, r *http.Request, playlistID string, user *models.User, minRole string) (string, bool) {
+	role, err := playlistRole(r.Context(), playlistID, user.FloatplaneUserID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) || isInvalidInput(err) {
+			respondError(w, http.StatusNotFound, "Not Found", "Playlist not found or access denied")
+		} else {
+			respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to check playlist access")
+		}
+		return "", false
+	}
+	if roleRank[role] < roleRank[minRole] {
+		respondError(w, http.StatusForbidden, "Forbidden", "Requires "+minRole+" access to this playlist")
+		return role, false
+	}
+	return role, true
+}
+
+// isInvalidInput reports whether err is Postgres rejecting a malformed value
+// (a class 22 data exception), such as a playlist ID that isn't a UUID.
+func isInvalidInput(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && strings.HasPrefix(pgErr.Code, "22")
+}
+
+// syncPlaylistItems keeps playlist_items in step with a playlist's video_ids:
+// new videos are attributed to userID and removed videos are forgotten.
+// Attribution is best effort, so failures are logged rather than returned.
+func syncPlaylistItems(ctx context.Context, playlistID, userID string, videoIDs []string) {
+	if videoIDs == nil {
+		videoIDs = []string{}
+	}
+	err := database.Savepoint(ctx, func(ctx context.Context) error {
+		db := database.Conn(ctx)
+		_, err := db.Exec(ctx, `
+			DELETE FROM playlist_items WHERE playlist_id = $1 AND NOT (video_id = ANY($2))
+		`, playlistID, videoIDs)
+		if err != nil {
+			return err
+		}
+		_, err = db.Exec(ctx, `
+			INSERT INTO playlist_items (playlist_id, video_id, added_by, added_at)
+			SELECT $1, unnest($2::text[]), $3, $4
+			ON CONFLICT DO NOTHING
+		`, playlistID, videoIDs, userID, time.Now())
+		return err
+	})
+	if err != nil {
+		log.Printf("Failed to sync items for playlist %s: %v", playlistID, err)
+	}
+}
+
+// GetPlaylistItems lists a playlist's videos in order with who added each one.
+func GetPlaylistItems(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+	id := chi.URLParam(r, "id")
+
+	if _, ok := requirePlaylistRole(w, r, id, user, RoleViewer); !ok {
+		return
+	}
+
+	rows, err := database.Conn(r.Context()).Query(r.Context(), `
+		SELECT p.id, v.video_id, COALESCE(i.added_by, p.floatplane_user_id), COALESCE(i.added_at, p.updated_at)
+		FROM playlists p
+		CROSS JOIN LATERAL unnest(p.video_ids) WITH ORDINALITY AS v(video_id, position)
+		LEFT JOIN playlist_items i ON i.playlist_id = p.id AND i.video_id = v.video_id
+		WHERE p.id = $1
+		ORDER BY v.position
+	`, id)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to fetch items")
+		return
+	}
+	defer rows.Close()
+
+	items := []models.PlaylistItem{}
+	for rows.Next() {
+		var item models.PlaylistItem
+		if err := rows.Scan(&item.PlaylistID, &item.VideoID, &item.AddedBy, &item.AddedAt); err != nil {
+			respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to scan item")
+			return
+		}
+		items = append(items, item)
+	}
+
+	videoIDs := make([]string, len(items))
+	for i, item := range items {
+		videoIDs[i] = item.VideoID
+	}
+	unknown := make(map[string]bool)
+	for _, vid := range unknownVideoIDs(r.Context(), videoIDs) {
+		unknown[vid] = true
+	}
+	for i := range items {
+		items[i].Unknown = unknown[items[i].VideoID]
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"items": items,
+		"count": len(items),
+	})
+}
+
+// GetPlaylistMembers lists everyone with access to a playlist, including its creator.
+func GetPlaylistMembers(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+	id := chi.URLParam(r, "id")
+
+	if _, ok := requirePlaylistRole(w, r, id, user, RoleViewer); !ok {
+		return
+	}
+
+	rows, err := database.Conn(r.Context()).Query(r.Context(), `
+		SELECT id, floatplane_user_id, 'owner', created_at FROM playlists WHERE id = $1
+		UNION ALL
+		SELECT playlist_id, floatplane_user_id, role, created_at FROM playlist_members WHERE playlist_id = $1
+		ORDER BY 4
+	`, id)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to fetch members")
+		return
+	}
+	defer rows.Close()
+
+	members := []models.PlaylistMember{}
+	for rows.Next() {
+		var m models.PlaylistMember
+		if err := rows.Scan(&m.PlaylistID, &m.FloatplaneUserID, &m.Role, &m.CreatedAt); err != nil {
+			respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to scan member")
+			return
+		}
+		members = append(members, m)
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"members": members,
+		"count":   len(members),
+	})
+}
+
+// CreatePlaylistInvite creates an invite code granting viewer or editor access.
+func CreatePlaylistInvite(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+	id := chi.URLParam(r, "id")
+
+	var req struct {
+		Role string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Invalid request body")
+		return
+	}
+	if req.Role != RoleViewer && req.Role != RoleEditor {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Role must be viewer or editor")
+		return
+	}
+
+	if _, ok := requirePlaylistRole(w, r, id, user, RoleOwner); !ok {
+		return
+	}
+
+	var isWatchLater bool
+	if err := database.Conn(r.Context()).QueryRow(r.Context(), `SELECT is_watch_later FROM playlists WHERE id = $1`, id).Scan(&isWatchLater); err != nil {
+		respondError(w, http.StatusNotFound, "Not Found", "Playlist not found")
+		return
+	}
+	if isWatchLater {
+		respondError(w, http.StatusForbidden, "Forbidden", "Cannot share Watch Later playlist with members")
+		return
+	}
+
+	now := time.Now()
+	var inv models.PlaylistInvite
+	err := database.Conn(r.Context()).QueryRow(r.Context(), `
+		INSERT INTO playlist_invites (code, playlist_id, role, created_by, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING code, playlist_id, role, created_by, created_at, expires_at
+	`, generateRandomKey(), id, req.Role, user.FloatplaneUserID, now, now.Add(inviteTTL)).Scan(
+		&inv.Code, &inv.PlaylistID, &inv.Role, &inv.CreatedBy, &inv.CreatedAt, &inv.ExpiresAt,
+	)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to create invite")
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, inv)
+}
+
+// RevokePlaylistInvite deletes an invite code so it can no longer be redeemed.
+// Members who already joined with it keep their access.
+func RevokePlaylistInvite(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+	id := chi.URLParam(r, "id")
+	code := chi.URLParam(r, "code")
+
+	if _, ok := requirePlaylistRole(w, r, id, user, RoleOwner); !ok {
+		return
+	}
+
+	commandTag, err := database.Conn(r.Context()).Exec(r.Context(), `
+		DELETE FROM playlist_invites WHERE playlist_id = $1 AND code = $2
+	`, id, code)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to revoke invite")
+		return
+	}
+	if commandTag.RowsAffected() == 0 {
+		respondError(w, http.StatusNotFound, "Not Found", "Invite not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// JoinPlaylist redeems an invite code. Existing members keep the higher of
+// their current role and the invite's role.
+func JoinPlaylist(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Missing code")
+		return
+	}
+
+	var inv models.PlaylistInvite
+	err := database.Conn(r.Context()).QueryRow(r.Context(), `
+		SELECT i.code, i.playlist_id, i.role, i.expires_at
+		FROM playlist_invites i JOIN playlists p ON p.id = i.playlist_id
+		WHERE i.code = $1 AND p.deleted_at IS NULL
+	`, req.Code).Scan(&inv.Code, &inv.PlaylistID, &inv.Role, &inv.ExpiresAt)
+	if err != nil || time.Now().After(inv.ExpiresAt) {
+		respondError(w, http.StatusNotFound, "Not Found", "Invite not found or expired")
+		return
+	}
+
+	if current, err := playlistRole(r.Context(), inv.PlaylistID, user.FloatplaneUserID); err == nil && roleRank[current] >= roleRank[inv.Role] {
+		respondJSON(w, http.StatusOK, map[string]string{"playlist_id": inv.PlaylistID, "role": current})
+		return
+	}
+
+	_, err = database.Conn(r.Context()).Exec(r.Context(), `
+		INSERT INTO playlist_members (playlist_id, floatplane_user_id, role, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (playlist_id, floatplane_user_id) DO UPDATE SET role = EXCLUDED.role
+	`, inv.PlaylistID, user.FloatplaneUserID, inv.Role, time.Now())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to join playlist")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"playlist_id": inv.PlaylistID, "role": inv.Role})
+}
+
+// UpdatePlaylistMember changes a member's role.
+func UpdatePlaylistMember(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+	id := chi.URLParam(r, "id")
+	memberID := chi.URLParam(r, "userId")
+
+	var req struct {
+		Role string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || roleRank[req.Role] == 0 {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Role must be viewer, editor or owner")
+		return
+	}
+
+	if _, ok := requirePlaylistRole(w, r, id, user, RoleOwner); !ok {
+		return
+	}
+
+	var m models.PlaylistMember
+	err := database.Conn(r.Context()).QueryRow(r.Context(), `
+		UPDATE playlist_members SET role = $1 WHERE playlist_id = $2 AND floatplane_user_id = $3
+		RETURNING playlist_id, floatplane_user_id, role, created_at
+	`, req.Role, id, memberID).Scan(&m.PlaylistID, &m.FloatplaneUserID, &m.Role, &m.CreatedAt)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Not Found", "Member not found")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, m)
+}
+
+// RemovePlaylistMember removes a member. Owners can remove anyone; other
+// members can only remove themselves (leave).
+func RemovePlaylistMember(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+	id := chi.URLParam(r, "id")
+	memberID := chi.URLParam(r, "userId")
+
+	minRole := RoleOwner
+	if memberID == user.FloatplaneUserID {
+		minRole = RoleViewer
+	}
+	if _, ok := requirePlaylistRole(w, r, id, user, minRole); !ok {
+		return
+	}
+
+	commandTag, err := database.Conn(r.Context()).Exec(r.Context(), `
+		DELETE FROM playlist_members WHERE playlist_id = $1 AND floatplane_user_id = $2
+	`, id, memberID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to remove member")
+		return
+	}
+	if commandTag.RowsAffected() == 0 {
+		respondError(w, http.StatusNotFound, "Not Found", "Member not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// playlistOperation is a playlist or Watch Later mutation that can be queued
+// through POST /sync or POST /batch. It is applied by calling the handler of
+// the equivalent REST endpoint, so it gets exactly the same validation.
+type playlistOperation struct {
+	method  string
+	handler http.HandlerFunc
+	target  bool // Operates on playlist_id
+}
+
+var playlistOperations = map[string]playlistOperation{
+	"create_playlist":     {http.MethodPost, CreatePlaylist, false},
+	"update_playlist":     {http.MethodPut, UpdatePlaylist, true},
+	"delete_playlist":     {http.MethodDelete, DeletePlaylist, true},
+	"restore_playlist":    {http.MethodPost, RestorePlaylist, true},
+	"add_video":           {http.MethodPatch, AddVideoToPlaylist, true},
+	"remove_video":        {http.MethodPatch, RemoveVideoFromPlaylist, true},
+	"watch_later_replace": {http.MethodPut, UpdateWatchLater, false},
+	"watch_later_add":     {http.MethodPatch, AddVideoToWatchLater, false},
+	"watch_later_remove":  {http.MethodPatch, RemoveVideoFromWatchLater, false},
+}
+
+// runPlaylistOperation calls op's handler with data as the request body and
+// returns the response status and body. ctx replaces the request context,
+// e.g. to run the operation inside a transaction.
+func runPlaylistOperation(ctx context.Context, r *http.Request, op playlistOperation, playlistID string, data json.RawMessage) (int, json.RawMessage) {
+	rctx := chi.NewRouteContext()
+	if op.target {
+		rctx.URLParams.Add("id", playlistID)
+	}
+	if len(data) == 0 {
+		data = []byte("{}")
+	}
+	sub, err := http.NewRequestWithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx), op.method, r.URL.Path, bytes.NewReader(data))
+	if err != nil {
+		return http.StatusInternalServerError, operationError(http.StatusInternalServerError, "Failed to apply operation")
+	}
+	rec := &responseBuffer{header: make(http.Header), code: http.StatusOK}
+	op.handler(rec, sub)
+	return rec.code, json.RawMessage(rec.body.Bytes())
+}
+
+// operationError is an error body in the same shape as respondError.
+func operationError(code int, message string) json.RawMessage {
+	b, _ := json.Marshal(map[string]string{"error": http.StatusText(code), "message": message})
+	return b
+}
+
+// responseBuffer captures the response of a handler called internally.
+type responseBuffer struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func (b *responseBuffer) Header() http.Header { return b.header }
+
+func (b *responseBuffer) Write(p []byte) (int, error) { return b.body.Write(p) }
+
+func (b *responseBuffer) WriteHeader(code int) { b.code = code }
@@ -1,26 +1,108 @@
 package handlers
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/coulterpeterson/floatnative/packages/api-go/internal/database"
 	"github.com/coulterpeterson/floatnative/packages/api-go/internal/middleware"
 	"github.com/coulterpeterson/floatnative/packages/api-go/internal/models"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/services"
 	"github.com/go-chi/chi/v5"
 )
 
+// WatchLaterName is the name of every user's Watch Later playlist. It is
+// reserved, so no other playlist can use it.
+const WatchLaterName = "Watch Later"
+
+// isReservedName reports whether name would be mistaken for Watch Later.
+func isReservedName(name string) bool {
+	return strings.EqualFold(strings.TrimSpace(name), WatchLaterName)
+}
+
 type GetPlaylistsResponse struct {
-	Playlists []models.Playlist `json:"playlists"`
-	Count     int               `json:"count"`
+	Playlists  []PlaylistListItem `json:"playlists"`
+	Count      int                `json:"count"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+}
+
+// PlaylistListItem is a playlist as returned by GetPlaylists. VideoIDs shadows
+// the embedded field so it can be left out when include_videos=false.
+type PlaylistListItem struct {
+	models.Playlist
+	VideoIDs *[]string `json:"video_ids,omitempty"`
+}
+
+const maxPlaylistPageSize = 200
+
+// playlistSorts maps the sort query parameter to its SQL expression and the
+// cast used to compare it against a cursor value.
+var playlistSorts = map[string]struct{ expr, cast string }{
+	"created_at": {"created_at", "timestamptz"},
+	"updated_at": {"updated_at", "timestamptz"},
+	"name":       {"lower(name)", "text"},
+	"item_count": {"cardinality(video_ids)", "int"},
+	"manual":     {"sort_order", "int"},
+}
+
+// playlistCursor is the position after the last playlist of a page.
+type playlistCursor struct {
+	Pinned int    `json:"p"`
+	Value  string `json:"v"`
+	ID     string `json:"id"`
+}
+
+func encodePlaylistCursor(c playlistCursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodePlaylistCursor(s string) (playlistCursor, error) {
+	var c playlistCursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+	err = json.Unmarshal(b, &c)
+	return c, err
 }
 
 type CreatePlaylistRequest struct {
-	Name     string   `json:"name"`
-	VideoIDs []string `json:"video_ids"`
+	Name       string             `json:"name"`
+	VideoIDs   []string           `json:"video_ids"`
+	SmartRules *models.SmartRules `json:"smart_rules"` // Set to create a smart playlist
+	PlaylistAttributes
+}
+
+// playlistColumns is the column list scanned by scanPlaylist.
+const playlistColumns = `id, floatplane_user_id, name, is_watch_later, video_ids, created_at, updated_at, is_smart, smart_rules, cardinality(video_ids), deleted_at, sync_version,
+	COALESCE(description, ''), COALESCE(cover_video_id, ''),
+	COALESCE((SELECT fp.thumbnail_url FROM fp_posts fp WHERE fp.id = cover_video_id), ''),
+	COALESCE(color, ''), COALESCE(emoji, ''), pinned, sort_order, remove_when_watched`
+
+// scanPlaylist scans playlistColumns into p, followed by any extra columns.
+func scanPlaylist(row rowScanner, p *models.Playlist, extra ...any) error {
+	dest := []any{&p.ID, &p.FloatplaneUserID, &p.Name, &p.IsWatchLater, &p.VideoIDs, &p.CreatedAt, &p.UpdatedAt, &p.IsSmart, &p.SmartRules, &p.ItemCount, &p.DeletedAt, &p.SyncVersion,
+		&p.Description, &p.CoverVideoID, &p.CoverThumbnailURL, &p.Color, &p.Emoji, &p.Pinned, &p.SortOrder, &p.RemoveWhenWatched}
+	return row.Scan(append(dest, extra...)...)
 }
 
+// GetPlaylists lists the playlists the user owns or is a member of.
+// Query parameters (all optional):
+//   - sort: created_at (default), updated_at, name, item_count or manual (sort_order)
+//   - order: asc or desc (default desc, or asc for name and manual)
+//   - q: case-insensitive name filter
+//   - limit: page size, enables pagination with next_cursor
+//   - cursor: next_cursor from the previous page
+//   - include_videos: false to omit video_ids and return item_count only
+//
+// Pinned playlists always come first.
 func GetPlaylists(w http.ResponseWriter, r *http.Request) {
 	// 1. Get user from context
 	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
@@ -29,44 +111,145 @@ func GetPlaylists(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 2. Query playlists
-	rows, err := database.Pool.Query(r.Context(), `
-		SELECT id, floatplane_user_id, name, is_watch_later, video_ids, created_at, updated_at
+	// 2. Parse listing options
+	query := r.URL.Query()
+	sortName := query.Get("sort")
+	if sortName == "" {
+		sortName = "created_at"
+	}
+	sort, ok := playlistSorts[sortName]
+	if !ok {
+		respondError(w, http.StatusBadRequest, "Bad Request", "sort must be name, created_at, updated_at, item_count or manual")
+		return
+	}
+
+	order := query.Get("order")
+	if order == "" {
+		order = "desc"
+		if sortName == "name" || sortName == "manual" {
+			order = "asc"
+		}
+	}
+	if order != "asc" && order != "desc" {
+		respondError(w, http.StatusBadRequest, "Bad Request", "order must be asc or desc")
+		return
+	}
+
+	limit := 0
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPlaylistPageSize {
+			respondError(w, http.StatusBadRequest, "Bad Request", fmt.Sprintf("limit must be between 1 and %d", maxPlaylistPageSize))
+			return
+		}
+		limit = n
+	}
+
+	includeVideos := query.Get("include_videos") != "false"
+
+	// pinKey sorts pinned playlists first in either direction, so it can lead
+	// the keyset comparison.
+	pinKey := "pinned::int"
+	if order == "asc" {
+		pinKey = "(NOT pinned)::int"
+	}
+
+	args := []interface{}{user.FloatplaneUserID}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	conds := []string{
+		`(floatplane_user_id = $1 OR id IN (SELECT playlist_id FROM playlist_members WHERE floatplane_user_id = $1))`,
+		`deleted_at IS NULL`,
+	}
+	if q := query.Get("q"); q != "" {
+		conds = append(conds, "name ILIKE "+arg("%"+q+"%"))
+	}
+	if c := query.Get("cursor"); c != "" {
+		cursor, err := decodePlaylistCursor(c)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Bad Request", "Invalid cursor")
+			return
+		}
+		cmp := "<"
+		if order == "asc" {
+			cmp = ">"
+		}
+		conds = append(conds, fmt.Sprintf("(%s, %s, id) %s (%s::int, %s::%s, %s::uuid)", pinKey, sort.expr, cmp, arg(cursor.Pinned), arg(cursor.Value), sort.cast, arg(cursor.ID)))
+	}
+
+	limitClause := ""
+	if limit > 0 {
+		limitClause = "LIMIT " + arg(limit+1) // One extra row tells us whether there's another page
+	}
+
+	// 3. Query playlists the user owns or is a member of
+	rows, err := database.Conn(r.Context()).Query(r.Context(), `
+		SELECT `+playlistColumns+`,
+		       CASE WHEN floatplane_user_id = $1 THEN 'owner'
+		            ELSE (SELECT role FROM playlist_members m WHERE m.playlist_id = playlists.id AND m.floatplane_user_id = $1)
+		       END,
+		       `+pinKey+`, `+sort.expr+`::text
 		FROM playlists
-		WHERE floatplane_user_id = $1
-		ORDER BY created_at DESC
-	`, user.FloatplaneUserID)
+		WHERE `+strings.Join(conds, " AND ")+`
+		ORDER BY `+pinKey+` `+order+`, `+sort.expr+` `+order+`, id `+order+`
+		`+limitClause, args...)
 	if err != nil {
+		if isInvalidInput(err) {
+			respondError(w, http.StatusBadRequest, "Bad Request", "Invalid cursor")
+			return
+		}
 		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to fetch playlists")
 		return
 	}
 	defer rows.Close()
 
 	var playlists []models.Playlist
+	var cursors []playlistCursor
 	for rows.Next() {
 		var p models.Playlist
-		if err := rows.Scan(
-			&p.ID,
-			&p.FloatplaneUserID,
-			&p.Name,
-			&p.IsWatchLater,
-			&p.VideoIDs,
-			&p.CreatedAt,
-			&p.UpdatedAt,
-		); err != nil {
+		var c playlistCursor
+		if err := scanPlaylist(rows, &p, &p.Role, &c.Pinned, &c.Value); err != nil {
 			respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to scan playlist")
 			return
 		}
 		playlists = append(playlists, p)
+		c.ID = p.ID
+		cursors = append(cursors, c)
+	}
+	rows.Close()
+
+	nextCursor := ""
+	if limit > 0 && len(playlists) > limit {
+		playlists = playlists[:limit]
+		nextCursor = encodePlaylistCursor(cursors[limit-1])
 	}
 
-	if playlists == nil {
-		playlists = []models.Playlist{}
+	// Smart playlists are resolved after the rows are drained so the
+	// connection is free for the fp_posts queries.
+	for i := range playlists {
+		if err := resolveSmartPlaylist(r.Context(), &playlists[i]); err != nil {
+			respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to resolve smart playlist")
+			return
+		}
+	}
+
+	items := make([]PlaylistListItem, 0, len(playlists))
+	for _, p := range playlists {
+		item := PlaylistListItem{Playlist: p}
+		if includeVideos {
+			videoIDs := p.VideoIDs
+			item.VideoIDs = &videoIDs
+		}
+		items = append(items, item)
 	}
 
 	respondJSON(w, http.StatusOK, GetPlaylistsResponse{
-		Playlists: playlists,
-		Count:     len(playlists),
+		Playlists:  items,
+		Count:      len(items),
+		NextCursor: nextCursor,
 	})
 }
 
@@ -83,35 +266,81 @@ func CreatePlaylist(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.Name == "" || len(req.Name) > 255 {
+	if req.Name == "" {
 		respondError(w, http.StatusBadRequest, "Bad Request", "Invalid name")
 		return
 	}
+	if !checkNameQuota(w, req.Name) || !checkDescriptionQuota(w, req.PlaylistAttributes) || !checkItemQuota(w, len(req.VideoIDs)) {
+		return
+	}
+
+	if isReservedName(req.Name) {
+		respondError(w, http.StatusBadRequest, "Bad Request", `"Watch Later" is a reserved name`)
+		return
+	}
 
 	if req.VideoIDs == nil {
 		req.VideoIDs = []string{} // Initialize as empty array
 	}
-	// TODO: Handle "Watch Later" reserved name check
+	if req.VideoIDs, ok = normalizeVideoIDs(w, req.VideoIDs); !ok {
+		return
+	}
+
+	if req.SmartRules != nil {
+		if len(req.VideoIDs) > 0 {
+			respondError(w, http.StatusBadRequest, "Bad Request", "Smart playlists cannot have video_ids")
+			return
+		}
+		if msg := validateSmartRules(req.SmartRules); msg != "" {
+			respondError(w, http.StatusBadRequest, "Bad Request", msg)
+			return
+		}
+	}
+	if msg := validatePlaylistAttributes(&req.PlaylistAttributes); msg != "" {
+		respondError(w, http.StatusBadRequest, "Bad Request", msg)
+		return
+	}
+
+	ctx := r.Context()
+	tx, err := database.Begin(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to start transaction")
+		return
+	}
+	defer tx.Rollback(ctx)
+	r = r.WithContext(database.WithTx(ctx, tx))
+
+	if !checkPlaylistQuota(w, r, user.FloatplaneUserID, 1) {
+		return
+	}
 
 	var p models.Playlist
-	err := database.Pool.QueryRow(r.Context(), `
-		INSERT INTO playlists (floatplane_user_id, name, video_ids, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $4)
-		RETURNING id, floatplane_user_id, name, is_watch_later, video_ids, created_at, updated_at
-	`, user.FloatplaneUserID, req.Name, req.VideoIDs, time.Now()).Scan(
-		&p.ID,
-		&p.FloatplaneUserID,
-		&p.Name,
-		&p.IsWatchLater,
-		&p.VideoIDs,
-		&p.CreatedAt,
-		&p.UpdatedAt,
-	)
+	applyPlaylistAttributes(&p, req.PlaylistAttributes)
+	err = scanPlaylist(database.Conn(r.Context()).QueryRow(r.Context(), `
+		INSERT INTO playlists (floatplane_user_id, name, video_ids, is_smart, smart_rules,
+		                       description, cover_video_id, color, emoji, pinned, sort_order, remove_when_watched, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, ''), NULLIF($8, ''), NULLIF($9, ''), $10, $11, $12, $13, $13)
+		RETURNING `+playlistColumns+`
+	`, user.FloatplaneUserID, req.Name, req.VideoIDs, req.SmartRules != nil, req.SmartRules,
+		p.Description, p.CoverVideoID, p.Color, p.Emoji, p.Pinned, p.SortOrder, p.RemoveWhenWatched, time.Now()), &p)
 
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to create playlist")
 		return
 	}
+	syncPlaylistItems(r.Context(), p.ID, user.FloatplaneUserID, p.VideoIDs)
+	recordRevision(r.Context(), &p, RevisionCreate)
+	p.Role = RoleOwner
+	if err := resolveSmartPlaylist(r.Context(), &p); err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to resolve smart playlist")
+		return
+	}
+	if err := tx.Commit(r.Context()); err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to create playlist")
+		return
+	}
+	// Checked after committing, as the check may ask Floatplane
+	p.UnknownVideoIDs = unknownVideoIDs(ctx, p.VideoIDs)
 
 	respondJSON(w, http.StatusCreated, p)
 }
@@ -132,25 +361,66 @@ func UpdatePlaylist(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		Name     *string   `json:"name"`
-		VideoIDs *[]string `json:"video_ids"`
+		Name       *string            `json:"name"`
+		VideoIDs   *[]string          `json:"video_ids"`
+		SmartRules *models.SmartRules `json:"smart_rules"`
+		PlaylistAttributes
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		respondError(w, http.StatusBadRequest, "Bad Request", "Invalid request body")
 		return
 	}
 
-	if req.Name == nil && req.VideoIDs == nil {
+	if req.Name == nil && req.VideoIDs == nil && req.SmartRules == nil && req.PlaylistAttributes.isEmpty() {
 		respondError(w, http.StatusBadRequest, "Bad Request", "No fields to update")
 		return
 	}
+	if msg := validatePlaylistAttributes(&req.PlaylistAttributes); msg != "" {
+		respondError(w, http.StatusBadRequest, "Bad Request", msg)
+		return
+	}
+	if req.Name != nil && !checkNameQuota(w, *req.Name) {
+		return
+	}
+	if req.VideoIDs != nil {
+		if !checkItemQuota(w, len(*req.VideoIDs)) {
+			return
+		}
+		videoIDs, ok := normalizeVideoIDs(w, *req.VideoIDs)
+		if !ok {
+			return
+		}
+		req.VideoIDs = &videoIDs
+	}
+	if !checkDescriptionQuota(w, req.PlaylistAttributes) {
+		return
+	}
 
-	// Fetch playlist to check ownership and watch later status
+	// The update, its items and its revision are written together
+	ctx := r.Context()
+	tx, err := database.Begin(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to start transaction")
+		return
+	}
+	defer tx.Rollback(ctx)
+	r = r.WithContext(database.WithTx(ctx, tx))
+	if err := services.LockPlaylistWrites(r.Context(), user.FloatplaneUserID); err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to update playlist")
+		return
+	}
+
+	role, ok := requirePlaylistRole(w, r, id, user, RoleEditor)
+	if !ok {
+		return
+	}
+
+	// Fetch playlist to check watch later status
 	var p models.Playlist
-	err := database.Pool.QueryRow(r.Context(), `
-		SELECT id, floatplane_user_id, name, is_watch_later, video_ids, created_at, updated_at
-		FROM playlists WHERE id = $1 AND floatplane_user_id = $2
-	`, id, user.FloatplaneUserID).Scan(&p.ID, &p.FloatplaneUserID, &p.Name, &p.IsWatchLater, &p.VideoIDs, &p.CreatedAt, &p.UpdatedAt)
+	err = scanPlaylist(database.Conn(r.Context()).QueryRow(r.Context(), `
+		SELECT `+playlistColumns+`
+		FROM playlists WHERE id = $1 FOR UPDATE
+	`, id), &p)
 
 	if err != nil {
 		respondError(w, http.StatusNotFound, "Not Found", "Playlist not found or access denied")
@@ -161,17 +431,26 @@ func UpdatePlaylist(w http.ResponseWriter, r *http.Request) {
 		respondError(w, http.StatusForbidden, "Forbidden", "Cannot rename Watch Later playlist")
 		return
 	}
+	if !p.IsWatchLater && req.Name != nil && isReservedName(*req.Name) {
+		respondError(w, http.StatusBadRequest, "Bad Request", `"Watch Later" is a reserved name`)
+		return
+	}
 
-	// Build update query dynamically
-	// updates := []string{"updated_at = $1"}
-	// args := []interface{}{time.Now()}
-	// argID := 2
-
-	if req.Name != nil {
-		// Cleaned up unused dynamic logic
+	if p.IsSmart && req.VideoIDs != nil {
+		respondError(w, http.StatusForbidden, "Forbidden", "Smart playlist items are read-only")
+		return
 	}
-	// ... logic to update ...
-	// Actually, simpler method:
+	if req.SmartRules != nil {
+		if !p.IsSmart {
+			respondError(w, http.StatusBadRequest, "Bad Request", "Not a smart playlist")
+			return
+		}
+		if msg := validateSmartRules(req.SmartRules); msg != "" {
+			respondError(w, http.StatusBadRequest, "Bad Request", msg)
+			return
+		}
+	}
+
 	newName := p.Name
 	if req.Name != nil {
 		newName = *req.Name
@@ -180,25 +459,58 @@ func UpdatePlaylist(w http.ResponseWriter, r *http.Request) {
 	if req.VideoIDs != nil {
 		newVideoIDs = *req.VideoIDs
 	}
+	newSmartRules := p.SmartRules
+	if req.SmartRules != nil {
+		newSmartRules = req.SmartRules
+	}
+	oldName, oldVideoIDs := p.Name, p.VideoIDs
+	applyPlaylistAttributes(&p, req.PlaylistAttributes)
 
-	err = database.Pool.QueryRow(r.Context(), `
+	err = scanPlaylist(database.Conn(r.Context()).QueryRow(r.Context(), `
 		UPDATE playlists
-		SET name = $1, video_ids = $2, updated_at = $3
-		WHERE id = $4 AND floatplane_user_id = $5
-		RETURNING id, floatplane_user_id, name, is_watch_later, video_ids, created_at, updated_at
-	`, newName, newVideoIDs, time.Now(), id, user.FloatplaneUserID).Scan(
-		&p.ID, &p.FloatplaneUserID, &p.Name, &p.IsWatchLater, &p.VideoIDs, &p.CreatedAt, &p.UpdatedAt,
-	)
+		SET name = $1, video_ids = $2, smart_rules = $3, updated_at = $4,
+		    description = NULLIF($6, ''), cover_video_id = NULLIF($7, ''), color = NULLIF($8, ''), emoji = NULLIF($9, ''),
+		    pinned = $10, sort_order = $11, remove_when_watched = $12
+		WHERE id = $5
+		RETURNING `+playlistColumns+`
+	`, newName, newVideoIDs, newSmartRules, time.Now(), id,
+		p.Description, p.CoverVideoID, p.Color, p.Emoji, p.Pinned, p.SortOrder, p.RemoveWhenWatched), &p)
 
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to update playlist")
 		return
 	}
+	if req.VideoIDs != nil {
+		syncPlaylistItems(r.Context(), p.ID, user.FloatplaneUserID, p.VideoIDs)
+	}
+	videoAction, videosChanged := classifyVideoChange(oldVideoIDs, p.VideoIDs)
+	switch {
+	case videosChanged && p.Name != oldName:
+		recordRevision(r.Context(), &p, RevisionReplace)
+	case videosChanged:
+		recordRevision(r.Context(), &p, videoAction)
+	case p.Name != oldName:
+		recordRevision(r.Context(), &p, RevisionRename)
+	default:
+		publishPlaylistEvent(r.Context(), user.FloatplaneUserID, &p, "update")
+	}
+	p.Role = role
+	if err := resolveSmartPlaylist(r.Context(), &p); err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to resolve smart playlist")
+		return
+	}
+	if err := tx.Commit(r.Context()); err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to update playlist")
+		return
+	}
+	if req.VideoIDs != nil {
+		p.UnknownVideoIDs = unknownVideoIDs(ctx, p.VideoIDs)
+	}
 
 	respondJSON(w, http.StatusOK, p)
 }
 
-// DeletePlaylist deletes a playlist
+// DeletePlaylist moves a playlist to the trash
 func DeletePlaylist(w http.ResponseWriter, r *http.Request) {
 	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
 	if !ok {
@@ -208,9 +520,13 @@ func DeletePlaylist(w http.ResponseWriter, r *http.Request) {
 
 	id := chi.URLParam(r, "id")
 
+	if _, ok := requirePlaylistRole(w, r, id, user, RoleOwner); !ok {
+		return
+	}
+
 	// Check if Watch Later
 	var isWatchLater bool
-	err := database.Pool.QueryRow(r.Context(), `SELECT is_watch_later FROM playlists WHERE id=$1 AND floatplane_user_id=$2`, id, user.FloatplaneUserID).Scan(&isWatchLater)
+	err := database.Conn(r.Context()).QueryRow(r.Context(), `SELECT is_watch_later FROM playlists WHERE id=$1`, id).Scan(&isWatchLater)
 	if err != nil {
 		respondError(w, http.StatusNotFound, "Not Found", "Playlist not found")
 		return
@@ -221,7 +537,7 @@ func DeletePlaylist(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	commandTag, err := database.Pool.Exec(r.Context(), `DELETE FROM playlists WHERE id=$1 AND floatplane_user_id=$2`, id, user.FloatplaneUserID)
+	commandTag, err := database.Conn(r.Context()).Exec(r.Context(), `UPDATE playlists SET deleted_at=$1, updated_at=$1 WHERE id=$2 AND deleted_at IS NULL`, time.Now(), id)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to delete playlist")
 		return
@@ -230,10 +546,108 @@ func DeletePlaylist(w http.ResponseWriter, r *http.Request) {
 		respondError(w, http.StatusNotFound, "Not Found", "Playlist not found")
 		return
 	}
+	publishEvent(r.Context(), user.FloatplaneUserID, services.EventPlaylistDeleted, map[string]string{"playlist_id": id})
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// TrashedPlaylist is a deleted playlist with the time it will be purged.
+type TrashedPlaylist struct {
+	models.Playlist
+	PurgeAt time.Time `json:"purge_at"`
+}
+
+// GetTrash lists deleted playlists the user owns, most recently deleted first.
+func GetTrash(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+
+	rows, err := database.Conn(r.Context()).Query(r.Context(), `
+		SELECT `+playlistColumns+`
+		FROM playlists
+		WHERE deleted_at IS NOT NULL
+		  AND (floatplane_user_id = $1 OR id IN (
+		      SELECT playlist_id FROM playlist_members WHERE floatplane_user_id = $1 AND role = 'owner'))
+		ORDER BY deleted_at DESC
+	`, user.FloatplaneUserID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to fetch trash")
+		return
+	}
+	defer rows.Close()
+
+	retention := services.TrashRetention()
+	trashed := []TrashedPlaylist{}
+	for rows.Next() {
+		var p models.Playlist
+		if err := scanPlaylist(rows, &p); err != nil {
+			respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to scan playlist")
+			return
+		}
+		p.Role = RoleOwner
+		trashed = append(trashed, TrashedPlaylist{Playlist: p, PurgeAt: p.DeletedAt.Add(retention)})
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"playlists": trashed,
+		"count":     len(trashed),
+	})
+}
+
+// RestorePlaylist moves a playlist out of the trash
+func RestorePlaylist(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+	id := chi.URLParam(r, "id")
+
+	role, err := lookupPlaylistRole(r.Context(), id, user.FloatplaneUserID, true)
+	if err != nil || role != RoleOwner {
+		respondError(w, http.StatusNotFound, "Not Found", "Playlist not found in trash")
+		return
+	}
+
+	tx, err := database.Begin(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to start transaction")
+		return
+	}
+	defer tx.Rollback(r.Context())
+	r = r.WithContext(database.WithTx(r.Context(), tx))
+
+	if !checkPlaylistQuota(w, r, user.FloatplaneUserID, 1) {
+		return
+	}
+
+	var p models.Playlist
+	err = scanPlaylist(database.Conn(r.Context()).QueryRow(r.Context(), `
+		UPDATE playlists SET deleted_at = NULL, updated_at = $1
+		WHERE id = $2 AND deleted_at IS NOT NULL
+		RETURNING `+playlistColumns+`
+	`, time.Now(), id), &p)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Not Found", "Playlist not found in trash")
+		return
+	}
+	recordRevision(r.Context(), &p, RevisionRestore)
+	p.Role = role
+	if err := resolveSmartPlaylist(r.Context(), &p); err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to resolve smart playlist")
+		return
+	}
+	if err := tx.Commit(r.Context()); err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to restore playlist")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, p)
+}
+
 // AddVideoToPlaylist adds a video ID to the playlist (idempotent)
 func AddVideoToPlaylist(w http.ResponseWriter, r *http.Request) {
 	modifyPlaylistVideos(w, r, "add")
@@ -259,18 +673,53 @@ func modifyPlaylistVideos(w http.ResponseWriter, r *http.Request, action string)
 		respondError(w, http.StatusBadRequest, "Bad Request", "Invalid video_id")
 		return
 	}
+	// Removal matches however the video was stored, so entries saved before
+	// validation, or under a looser mode, can still be removed
+	var removeIDs []string
+	if action == "remove" {
+		removeIDs = services.StoredVideoIDs(req.VideoID)
+	} else if req.VideoID, ok = services.NormalizeVideoID(req.VideoID); !ok {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Invalid video_id")
+		return
+	}
+
+	role, ok := requirePlaylistRole(w, r, id, user, RoleEditor)
+	if !ok {
+		return
+	}
+
+	// Lock the playlist so concurrent adds and removes don't overwrite each
+	// other's video_ids
+	ctx := r.Context()
+	tx, err := database.Begin(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to start transaction")
+		return
+	}
+	defer tx.Rollback(ctx)
+	r = r.WithContext(database.WithTx(ctx, tx))
+	if err := services.LockPlaylistWrites(r.Context(), user.FloatplaneUserID); err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to update playlist")
+		return
+	}
 
 	var p models.Playlist
-	err := database.Pool.QueryRow(r.Context(), `
-		SELECT id, video_ids FROM playlists WHERE id=$1 AND floatplane_user_id=$2
-	`, id, user.FloatplaneUserID).Scan(&p.ID, &p.VideoIDs)
+	err = database.Conn(r.Context()).QueryRow(r.Context(), `
+		SELECT id, video_ids, is_smart FROM playlists WHERE id=$1 FOR UPDATE
+	`, id).Scan(&p.ID, &p.VideoIDs, &p.IsSmart)
 
 	if err != nil {
 		respondError(w, http.StatusNotFound, "Not Found", "Playlist not found")
 		return
 	}
+	if p.IsSmart {
+		respondError(w, http.StatusForbidden, "Forbidden", "Smart playlist items are read-only")
+		return
+	}
 
 	// Modify video_ids logic
+	before := len(p.VideoIDs)
+	added := false
 	if action == "add" {
 		exists := false
 		for _, vid := range p.VideoIDs {
@@ -279,12 +728,16 @@ func modifyPlaylistVideos(w http.ResponseWriter, r *http.Request, action string)
 			}
 		}
 		if !exists {
+			if !checkItemQuota(w, len(p.VideoIDs)+1) {
+				return
+			}
 			p.VideoIDs = append(p.VideoIDs, req.VideoID)
+			added = true
 		}
 	} else if action == "remove" {
 		newIDs := []string{}
 		for _, vid := range p.VideoIDs {
-			if vid != req.VideoID {
+			if !slices.Contains(removeIDs, vid) {
 				newIDs = append(newIDs, vid)
 			}
 		}
@@ -292,16 +745,29 @@ func modifyPlaylistVideos(w http.ResponseWriter, r *http.Request, action string)
 	}
 
 	// Update DB
-	err = database.Pool.QueryRow(r.Context(), `
+	err = scanPlaylist(database.Conn(r.Context()).QueryRow(r.Context(), `
 		UPDATE playlists SET video_ids=$1, updated_at=$2 WHERE id=$3
-		RETURNING id, floatplane_user_id, name, is_watch_later, video_ids, created_at, updated_at
-	`, p.VideoIDs, time.Now(), id).Scan(
-		&p.ID, &p.FloatplaneUserID, &p.Name, &p.IsWatchLater, &p.VideoIDs, &p.CreatedAt, &p.UpdatedAt,
-	)
+		RETURNING `+playlistColumns+`
+	`, p.VideoIDs, time.Now(), id), &p)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to update playlist")
 		return
 	}
+	syncPlaylistItems(r.Context(), p.ID, user.FloatplaneUserID, p.VideoIDs)
+	if added {
+		recordVideoAdd(r.Context(), user.FloatplaneUserID, req.VideoID)
+	}
+	if len(p.VideoIDs) != before {
+		recordRevision(r.Context(), &p, action)
+	}
+	p.Role = role
+	if err := tx.Commit(r.Context()); err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to update playlist")
+		return
+	}
+	if action == "add" {
+		p.UnknownVideoIDs = unknownVideoIDs(ctx, []string{req.VideoID})
+	}
 
 	respondJSON(w, http.StatusOK, p)
 }
@@ -0,0 +1,320 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/database"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/middleware"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/models"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/services"
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	maxProgressLookup = 200
+	maxDeviceLength   = 100
+	// maxClockSkew is how far ahead of the server a client timestamp may be
+	// before it is clamped, so a device with a fast clock can't win forever.
+	maxClockSkew = 5 * time.Minute
+	// continueWatchingDoneFraction is how far into a video counts as finished
+	// for continue watching.
+	continueWatchingDoneFraction = 0.95
+	// progressEventStep is how far playback moves before other devices are
+	// told, so they aren't sent every heartbeat.
+	progressEventStep = 30.0
+)
+
+// progressColumns is the column list scanned by scanProgress.
+const progressColumns = `video_id, position_seconds, duration_seconds, COALESCE(device, ''), client_updated_at, updated_at, completed`
+
+func scanProgress(row rowScanner, p *models.WatchProgress) error {
+	return row.Scan(&p.VideoID, &p.PositionSeconds, &p.Duration, &p.Device, &p.ClientUpdatedAt, &p.UpdatedAt, &p.Completed)
+}
+
+// ProgressResponse is the result of PUT /progress/{videoId}. Applied is false
+// if a newer position from another device was kept instead. RemovedFrom lists
+// the playlists a finished video was removed from.
+type ProgressResponse struct {
+	models.WatchProgress
+	Applied     bool     `json:"applied"`
+	RemovedFrom []string `json:"removed_from,omitempty"`
+}
+
+// ContinueWatchingItem is a partially watched video with its post, if known.
+type ContinueWatchingItem struct {
+	models.WatchProgress
+	Post *models.FPPost `json:"post,omitempty"`
+}
+
+// UpdateProgress records the playback position in a video. Concurrent writes
+// from several devices are resolved by the client timestamp, last writer wins.
+// Finishing a video by the user's completion settings removes it from Watch
+// Later and from playlists flagged remove_when_watched.
+func UpdateProgress(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+	videoID, ok := services.NormalizeVideoID(chi.URLParam(r, "videoId"))
+	if !ok {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Invalid video ID")
+		return
+	}
+
+	var req struct {
+		PositionSeconds *float64   `json:"position_seconds"`
+		Duration        *float64   `json:"duration"`
+		Device          string     `json:"device"`
+		ClientUpdatedAt *time.Time `json:"client_updated_at"`
+		CreditsReached  bool       `json:"credits_reached"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Invalid request body")
+		return
+	}
+	if req.PositionSeconds == nil || *req.PositionSeconds < 0 {
+		respondError(w, http.StatusBadRequest, "Bad Request", "position_seconds must be zero or more")
+		return
+	}
+	if req.Duration != nil && *req.Duration <= 0 {
+		respondError(w, http.StatusBadRequest, "Bad Request", "duration must be positive")
+		return
+	}
+	if len(req.Device) > maxDeviceLength {
+		respondError(w, http.StatusBadRequest, "Bad Request", "device is too long")
+		return
+	}
+
+	now := time.Now()
+	clientUpdatedAt := now
+	if req.ClientUpdatedAt != nil && req.ClientUpdatedAt.Before(now.Add(maxClockSkew)) {
+		clientUpdatedAt = *req.ClientUpdatedAt
+	}
+	position := *req.PositionSeconds
+	if req.Duration != nil && position > *req.Duration {
+		position = *req.Duration
+	}
+	device := req.Device
+	if device == "" {
+		if session, ok := r.Context().Value(middleware.SessionContextKey).(*models.DeviceSession); ok {
+			device = session.DeviceInfo
+		}
+	}
+
+	ctx := r.Context()
+	tx, err := database.Pool.Begin(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to start transaction")
+		return
+	}
+	defer tx.Rollback(ctx)
+	r = r.WithContext(database.WithTx(ctx, tx))
+	db := database.Conn(r.Context())
+
+	var previous *models.WatchProgress
+	var p models.WatchProgress
+	err = scanProgress(db.QueryRow(r.Context(), `
+		SELECT `+progressColumns+` FROM watch_progress WHERE floatplane_user_id = $1 AND video_id = $2
+	`, user.FloatplaneUserID, videoID), &p)
+	if err == nil {
+		previous = &p
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to save progress")
+		return
+	}
+
+	settings, err := loadCompletionSettings(r.Context(), user.FloatplaneUserID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to fetch completion settings")
+		return
+	}
+	// Fall back to the last reported duration, then the post's
+	var duration *float64
+	err = db.QueryRow(r.Context(), `
+		SELECT COALESCE($3::float8,
+		       (SELECT duration_seconds FROM watch_progress WHERE floatplane_user_id = $1 AND video_id = $2),
+		       (SELECT NULLIF(video_duration, 0)::float8 FROM fp_posts WHERE id = $2))
+	`, user.FloatplaneUserID, videoID, req.Duration).Scan(&duration)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to save progress")
+		return
+	}
+	completed := settings.isComplete(position, duration, req.CreditsReached)
+
+	resp := ProgressResponse{Applied: true}
+	err = scanProgress(db.QueryRow(r.Context(), `
+		INSERT INTO watch_progress (floatplane_user_id, video_id, position_seconds, duration_seconds, device, client_updated_at, updated_at, completed)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8)
+		ON CONFLICT (floatplane_user_id, video_id) DO UPDATE
+		SET position_seconds = EXCLUDED.position_seconds,
+		    duration_seconds = COALESCE(EXCLUDED.duration_seconds, watch_progress.duration_seconds),
+		    device = EXCLUDED.device,
+		    client_updated_at = EXCLUDED.client_updated_at,
+		    updated_at = EXCLUDED.updated_at,
+		    completed = EXCLUDED.completed
+		WHERE watch_progress.client_updated_at <= EXCLUDED.client_updated_at
+		RETURNING `+progressColumns+`
+	`, user.FloatplaneUserID, videoID, position, req.Duration, device, clientUpdatedAt, now, completed), &resp.WatchProgress)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// A newer write won, so return it for the client to adopt
+		resp.Applied = false
+		err = scanProgress(db.QueryRow(r.Context(), `
+			SELECT `+progressColumns+` FROM watch_progress WHERE floatplane_user_id = $1 AND video_id = $2
+		`, user.FloatplaneUserID, videoID), &resp.WatchProgress)
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to save progress")
+		return
+	}
+	if resp.Applied && resp.Completed {
+		resp.RemovedFrom, err = removeWatchedVideo(r.Context(), user.FloatplaneUserID, videoID, settings)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to remove finished video")
+			return
+		}
+	}
+	if err := tx.Commit(r.Context()); err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to save progress")
+		return
+	}
+
+	if resp.Applied && progressEventDue(previous, &resp.WatchProgress) {
+		publishEvent(ctx, user.FloatplaneUserID, services.EventProgressUpdated, resp.WatchProgress)
+	}
+	respondJSON(w, http.StatusOK, resp)
+}
+
+// progressEventDue reports whether other devices should hear about a new
+// position: when a video is started, finished or picked up on another
+// device, and then every progressEventStep seconds of playback or on a seek.
+func progressEventDue(previous, current *models.WatchProgress) bool {
+	if previous == nil || previous.Completed != current.Completed || previous.Device != current.Device {
+		return true
+	}
+	return math.Floor(previous.PositionSeconds/progressEventStep) != math.Floor(current.PositionSeconds/progressEventStep)
+}
+
+// GetProgress returns the saved positions for up to 200 comma separated
+// video IDs. Videos without progress are left out.
+func GetProgress(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+
+	var ids []string
+	for _, id := range strings.Split(r.URL.Query().Get("ids"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Missing ids")
+		return
+	}
+	if len(ids) > maxProgressLookup {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Too many ids (max "+strconv.Itoa(maxProgressLookup)+")")
+		return
+	}
+
+	rows, err := database.Conn(r.Context()).Query(r.Context(), `
+		SELECT `+progressColumns+` FROM watch_progress
+		WHERE floatplane_user_id = $1 AND video_id = ANY($2)
+	`, user.FloatplaneUserID, ids)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to fetch progress")
+		return
+	}
+	defer rows.Close()
+
+	progress := []models.WatchProgress{}
+	for rows.Next() {
+		var p models.WatchProgress
+		if err := scanProgress(rows, &p); err != nil {
+			respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to scan progress")
+			return
+		}
+		progress = append(progress, p)
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"progress": progress,
+		"count":    len(progress),
+	})
+}
+
+// GetContinueWatching lists started but unfinished videos, most recently
+// watched first. A video is finished when completed by the user's settings,
+// or at 95% of its duration, taken from the progress report or else from
+// fp_posts.
+func GetContinueWatching(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+
+	limit := 20
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 100 {
+			respondError(w, http.StatusBadRequest, "Bad Request", "limit must be between 1 and 100")
+			return
+		}
+		limit = n
+	}
+
+	rows, err := database.Conn(r.Context()).Query(r.Context(), `
+		SELECT w.video_id, w.position_seconds, w.duration_seconds, COALESCE(w.device, ''), w.client_updated_at, w.updated_at, w.completed
+		FROM watch_progress w
+		LEFT JOIN fp_posts fp ON fp.id = w.video_id
+		WHERE w.floatplane_user_id = $1 AND w.position_seconds > 0 AND NOT w.completed
+		  AND (COALESCE(w.duration_seconds, NULLIF(fp.video_duration, 0)) IS NULL
+		       OR w.position_seconds < COALESCE(w.duration_seconds, NULLIF(fp.video_duration, 0)) * $2)
+		ORDER BY w.client_updated_at DESC
+		LIMIT $3
+	`, user.FloatplaneUserID, continueWatchingDoneFraction, limit)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to fetch continue watching")
+		return
+	}
+	defer rows.Close()
+
+	items := []ContinueWatchingItem{}
+	var ids []string
+	for rows.Next() {
+		var item ContinueWatchingItem
+		if err := scanProgress(rows, &item.WatchProgress); err != nil {
+			respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to scan progress")
+			return
+		}
+		items = append(items, item)
+		ids = append(ids, item.VideoID)
+	}
+	rows.Close()
+
+	posts, err := fetchPostsByIDs(r.Context(), ids)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to fetch posts")
+		return
+	}
+	for i := range items {
+		if post, ok := posts[items[i].VideoID]; ok {
+			items[i].Post = &post
+		}
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"items": items,
+		"count": len(items),
+	})
+}
@@ -0,0 +1,558 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/database"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/middleware"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/models"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/services"
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	maxQueueLength = 500
+	// queueSpacing is the gap left between positions when appending, so most
+	// inserts and moves fit between two items without touching the others.
+	queueSpacing = 1024.0
+	// minQueueGap is the smallest gap split before the queue is renumbered.
+	minQueueGap = 1e-6
+)
+
+var errQueueItemNotFound = errors.New("queue item not found")
+
+// QueueState is the user's Up Next queue. Version increases with every
+// change.
+type QueueState struct {
+	Items     []models.QueueItem `json:"items"`
+	Count     int                `json:"count"`
+	Current   *QueueCurrent      `json:"current"`
+	Version   int64              `json:"version"`
+	UpdatedAt *time.Time         `json:"updated_at,omitempty"`
+}
+
+// QueueCurrent is the video being played from the queue, with the saved
+// position so another device can resume it.
+type QueueCurrent struct {
+	VideoID         string   `json:"video_id"`
+	PositionSeconds *float64 `json:"position_seconds,omitempty"`
+}
+
+// queuePlacement says where new or moved items go: after an item, at the
+// front (next), or by default at the end.
+type queuePlacement struct {
+	AfterItemID *int64 `json:"after_item_id"`
+	Next        bool   `json:"next"`
+}
+
+// bumpQueueVersion increments the user's queue version, creating the queue
+// state if needed. Called first in every change, it also locks the state row
+// so concurrent changes to one queue run one at a time.
+func bumpQueueVersion(ctx context.Context, userID string) (int64, error) {
+	var version int64
+	err := database.Conn(ctx).QueryRow(ctx, `
+		INSERT INTO queue_state (floatplane_user_id, version, updated_at)
+		VALUES ($1, 1, $2)
+		ON CONFLICT (floatplane_user_id) DO UPDATE
+		SET version = queue_state.version + 1, updated_at = EXCLUDED.updated_at
+		RETURNING version
+	`, userID, time.Now()).Scan(&version)
+	return version, err
+}
+
+// queuePositions returns positions for n items placed as requested, skipping
+// the item being moved. Only when the gap is too small to split is the queue
+// renumbered.
+func queuePositions(ctx context.Context, userID string, place queuePlacement, moving int64, n int) ([]float64, error) {
+	db := database.Conn(ctx)
+	positions := make([]float64, n)
+
+	for attempt := 0; ; attempt++ {
+		var lo, hi *float64
+		var err error
+		switch {
+		case place.AfterItemID != nil:
+			var after float64
+			err = db.QueryRow(ctx, `
+				SELECT position FROM queue_items WHERE id = $1 AND floatplane_user_id = $2
+			`, *place.AfterItemID, userID).Scan(&after)
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil, errQueueItemNotFound
+			}
+			lo = &after
+			if err == nil {
+				err = db.QueryRow(ctx, `
+					SELECT MIN(position) FROM queue_items
+					WHERE floatplane_user_id = $1 AND position > $2 AND id <> $3
+				`, userID, after, moving).Scan(&hi)
+			}
+		case place.Next:
+			err = db.QueryRow(ctx, `
+				SELECT MIN(position) FROM queue_items WHERE floatplane_user_id = $1 AND id <> $2
+			`, userID, moving).Scan(&hi)
+		default:
+			err = db.QueryRow(ctx, `
+				SELECT MAX(position) FROM queue_items WHERE floatplane_user_id = $1 AND id <> $2
+			`, userID, moving).Scan(&lo)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case lo != nil && hi != nil:
+			step := (*hi - *lo) / float64(n+1)
+			if step >= minQueueGap || attempt > 0 {
+				for i := range positions {
+					positions[i] = *lo + step*float64(i+1)
+				}
+				return positions, nil
+			}
+			if err := renumberQueue(ctx, userID); err != nil {
+				return nil, err
+			}
+		case hi != nil:
+			for i := range positions {
+				positions[i] = *hi - queueSpacing*float64(n-i)
+			}
+			return positions, nil
+		default:
+			start := 0.0
+			if lo != nil {
+				start = *lo
+			}
+			for i := range positions {
+				positions[i] = start + queueSpacing*float64(i+1)
+			}
+			return positions, nil
+		}
+	}
+}
+
+// renumberQueue spreads the user's queue back out to even spacing.
+func renumberQueue(ctx context.Context, userID string) error {
+	_, err := database.Conn(ctx).Exec(ctx, `
+		UPDATE queue_items q SET position = o.n * $2
+		FROM (
+			SELECT id, row_number() OVER (ORDER BY position, id) AS n
+			FROM queue_items WHERE floatplane_user_id = $1
+		) o
+		WHERE q.id = o.id
+	`, userID, queueSpacing)
+	return err
+}
+
+// respondQueuePlacementError writes the response for a failed queuePositions.
+func respondQueuePlacementError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errQueueItemNotFound) {
+		respondError(w, http.StatusBadRequest, "Bad Request", "after_item_id is not in the queue")
+		return
+	}
+	respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to place queue items")
+}
+
+// GetQueue returns the user's Up Next queue. Pass ?version= with the last
+// version seen to get a 304 if nothing has changed.
+func GetQueue(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+
+	db := database.Conn(r.Context())
+	q := QueueState{Items: []models.QueueItem{}}
+	var currentID *string
+	var currentPosition *float64
+	err := db.QueryRow(r.Context(), `
+		SELECT s.version, s.updated_at, s.current_video_id, p.position_seconds
+		FROM queue_state s
+		LEFT JOIN watch_progress p ON p.floatplane_user_id = s.floatplane_user_id AND p.video_id = s.current_video_id
+		WHERE s.floatplane_user_id = $1
+	`, user.FloatplaneUserID).Scan(&q.Version, &q.UpdatedAt, &currentID, &currentPosition)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to fetch queue")
+		return
+	}
+	if v := r.URL.Query().Get("version"); v != "" && v == strconv.FormatInt(q.Version, 10) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if currentID != nil {
+		q.Current = &QueueCurrent{VideoID: *currentID, PositionSeconds: currentPosition}
+	}
+
+	rows, err := db.Query(r.Context(), `
+		SELECT id, video_id, added_at FROM queue_items
+		WHERE floatplane_user_id = $1
+		ORDER BY position, id
+	`, user.FloatplaneUserID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to fetch queue")
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var item models.QueueItem
+		if err := rows.Scan(&item.ID, &item.VideoID, &item.AddedAt); err != nil {
+			respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to scan queue item")
+			return
+		}
+		q.Items = append(q.Items, item)
+	}
+	q.Count = len(q.Items)
+
+	respondJSON(w, http.StatusOK, q)
+}
+
+// AddToQueue queues videos at the end, at the front with next, or after an
+// existing item. The same video may be queued more than once.
+func AddToQueue(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+
+	var req struct {
+		VideoIDs []string `json:"video_ids"`
+		queuePlacement
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.VideoIDs) == 0 {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Missing video_ids")
+		return
+	}
+	if req.VideoIDs, ok = normalizeEachVideoID(w, req.VideoIDs); !ok {
+		return
+	}
+
+	ctx := r.Context()
+	tx, err := database.Pool.Begin(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to start transaction")
+		return
+	}
+	defer tx.Rollback(ctx)
+	r = r.WithContext(database.WithTx(ctx, tx))
+	db := database.Conn(r.Context())
+
+	version, err := bumpQueueVersion(r.Context(), user.FloatplaneUserID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to update queue")
+		return
+	}
+	var count int
+	if err := db.QueryRow(r.Context(), `
+		SELECT COUNT(*) FROM queue_items WHERE floatplane_user_id = $1
+	`, user.FloatplaneUserID).Scan(&count); err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to update queue")
+		return
+	}
+	if count+len(req.VideoIDs) > maxQueueLength {
+		respondError(w, http.StatusBadRequest, "Bad Request", fmt.Sprintf("The queue can hold at most %d videos", maxQueueLength))
+		return
+	}
+
+	positions, err := queuePositions(r.Context(), user.FloatplaneUserID, req.queuePlacement, 0, len(req.VideoIDs))
+	if err != nil {
+		respondQueuePlacementError(w, err)
+		return
+	}
+	rows, err := db.Query(r.Context(), `
+		INSERT INTO queue_items (floatplane_user_id, video_id, position, added_at)
+		SELECT $1, v.video_id, v.position, $4
+		FROM unnest($2::text[], $3::float8[]) AS v(video_id, position)
+		RETURNING id, video_id, added_at
+	`, user.FloatplaneUserID, req.VideoIDs, positions, time.Now())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to update queue")
+		return
+	}
+	added := []models.QueueItem{}
+	for rows.Next() {
+		var item models.QueueItem
+		if err := rows.Scan(&item.ID, &item.VideoID, &item.AddedAt); err != nil {
+			rows.Close()
+			respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to scan queue item")
+			return
+		}
+		added = append(added, item)
+	}
+	rows.Close()
+
+	if err := tx.Commit(r.Context()); err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to update queue")
+		return
+	}
+
+	resp := map[string]interface{}{
+		"items":   added,
+		"version": version,
+	}
+	if unknown := unknownVideoIDs(ctx, req.VideoIDs); len(unknown) > 0 {
+		resp["unknown_video_ids"] = unknown
+	}
+	respondJSON(w, http.StatusCreated, resp)
+}
+
+// MoveQueueItem moves one item to the front, the end, or after another item.
+func MoveQueueItem(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "itemId"), 10, 64)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Not Found", "Queue item not found")
+		return
+	}
+
+	var req queuePlacement
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Invalid request body")
+		return
+	}
+	if req.AfterItemID != nil && *req.AfterItemID == id {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Cannot move an item after itself")
+		return
+	}
+
+	tx, err := database.Pool.Begin(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to start transaction")
+		return
+	}
+	defer tx.Rollback(r.Context())
+	r = r.WithContext(database.WithTx(r.Context(), tx))
+
+	version, err := bumpQueueVersion(r.Context(), user.FloatplaneUserID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to update queue")
+		return
+	}
+	positions, err := queuePositions(r.Context(), user.FloatplaneUserID, req, id, 1)
+	if err != nil {
+		respondQueuePlacementError(w, err)
+		return
+	}
+	var item models.QueueItem
+	err = database.Conn(r.Context()).QueryRow(r.Context(), `
+		UPDATE queue_items SET position = $3 WHERE id = $1 AND floatplane_user_id = $2
+		RETURNING id, video_id, added_at
+	`, id, user.FloatplaneUserID, positions[0]).Scan(&item.ID, &item.VideoID, &item.AddedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		respondError(w, http.StatusNotFound, "Not Found", "Queue item not found")
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to update queue")
+		return
+	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to update queue")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"item":    item,
+		"version": version,
+	})
+}
+
+// RemoveQueueItem removes one item from the queue.
+func RemoveQueueItem(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "itemId"), 10, 64)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Not Found", "Queue item not found")
+		return
+	}
+
+	tx, err := database.Pool.Begin(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to start transaction")
+		return
+	}
+	defer tx.Rollback(r.Context())
+	r = r.WithContext(database.WithTx(r.Context(), tx))
+
+	version, err := bumpQueueVersion(r.Context(), user.FloatplaneUserID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to update queue")
+		return
+	}
+	tag, err := database.Conn(r.Context()).Exec(r.Context(), `
+		DELETE FROM queue_items WHERE id = $1 AND floatplane_user_id = $2
+	`, id, user.FloatplaneUserID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to update queue")
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		respondError(w, http.StatusNotFound, "Not Found", "Queue item not found")
+		return
+	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to update queue")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{"version": version})
+}
+
+// PopQueue takes the first item off the queue and makes it the current video.
+func PopQueue(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+
+	tx, err := database.Pool.Begin(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to start transaction")
+		return
+	}
+	defer tx.Rollback(r.Context())
+	r = r.WithContext(database.WithTx(r.Context(), tx))
+	db := database.Conn(r.Context())
+
+	version, err := bumpQueueVersion(r.Context(), user.FloatplaneUserID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to update queue")
+		return
+	}
+	var item models.QueueItem
+	err = db.QueryRow(r.Context(), `
+		DELETE FROM queue_items WHERE id = (
+			SELECT id FROM queue_items WHERE floatplane_user_id = $1 ORDER BY position, id LIMIT 1
+		)
+		RETURNING id, video_id, added_at
+	`, user.FloatplaneUserID).Scan(&item.ID, &item.VideoID, &item.AddedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		respondError(w, http.StatusNotFound, "Not Found", "Queue is empty")
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to update queue")
+		return
+	}
+	if _, err := db.Exec(r.Context(), `
+		UPDATE queue_state SET current_video_id = $2 WHERE floatplane_user_id = $1
+	`, user.FloatplaneUserID, item.VideoID); err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to update queue")
+		return
+	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to update queue")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"item":    item,
+		"version": version,
+	})
+}
+
+// SetQueueCurrent sets or clears the current video. It needn't be queued.
+func SetQueueCurrent(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+
+	var req struct {
+		VideoID *string `json:"video_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Invalid request body")
+		return
+	}
+	if req.VideoID != nil {
+		id, ok := services.NormalizeVideoID(*req.VideoID)
+		if !ok {
+			respondError(w, http.StatusBadRequest, "Bad Request", "Invalid video_id")
+			return
+		}
+		req.VideoID = &id
+	}
+
+	var version int64
+	err := database.Conn(r.Context()).QueryRow(r.Context(), `
+		INSERT INTO queue_state (floatplane_user_id, current_video_id, version, updated_at)
+		VALUES ($1, $2, 1, $3)
+		ON CONFLICT (floatplane_user_id) DO UPDATE
+		SET current_video_id = EXCLUDED.current_video_id, version = queue_state.version + 1, updated_at = EXCLUDED.updated_at
+		RETURNING version
+	`, user.FloatplaneUserID, req.VideoID, time.Now()).Scan(&version)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to update queue")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"current_video_id": req.VideoID,
+		"version":          version,
+	})
+}
+
+// ClearQueue removes every item and the current video.
+func ClearQueue(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+
+	tx, err := database.Pool.Begin(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to start transaction")
+		return
+	}
+	defer tx.Rollback(r.Context())
+	r = r.WithContext(database.WithTx(r.Context(), tx))
+	db := database.Conn(r.Context())
+
+	version, err := bumpQueueVersion(r.Context(), user.FloatplaneUserID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to update queue")
+		return
+	}
+	tag, err := db.Exec(r.Context(), `DELETE FROM queue_items WHERE floatplane_user_id = $1`, user.FloatplaneUserID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to clear queue")
+		return
+	}
+	if _, err := db.Exec(r.Context(), `
+		UPDATE queue_state SET current_video_id = NULL WHERE floatplane_user_id = $1
+	`, user.FloatplaneUserID); err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to clear queue")
+		return
+	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to clear queue")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"deleted": tag.RowsAffected(),
+		"version": version,
+	})
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/database"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/middleware"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/models"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/services"
+)
+
+const defaultRecommendationLimit = 20
+
+// GetRecommendations returns videos the user hasn't saved that other users
+// save alongside theirs, best first. They are computed hourly; anything
+// saved since is left out.
+//
+// Query parameters:
+//   - limit: max recommendations (default 20, max 50)
+func GetRecommendations(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+
+	limit := defaultRecommendationLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > services.MaxRecommendations {
+			respondError(w, http.StatusBadRequest, "Bad Request", fmt.Sprintf("limit must be between 1 and %d", services.MaxRecommendations))
+			return
+		}
+		limit = n
+	}
+
+	rows, err := database.Conn(r.Context()).Query(r.Context(), `
+		SELECT rec.video_id, rec.score, rec.because_video_id, rec.computed_at,
+		       COALESCE(fp.title, ''), COALESCE(fp.thumbnail_url, ''), COALESCE(fp.channel_title, ''),
+		       COALESCE(because.title, '')
+		FROM recommendations rec
+		LEFT JOIN fp_posts fp ON fp.id = rec.video_id
+		LEFT JOIN fp_posts because ON because.id = rec.because_video_id
+		WHERE rec.floatplane_user_id = $1
+		  AND NOT EXISTS (
+			SELECT 1 FROM playlists p
+			WHERE p.floatplane_user_id = $1 AND p.deleted_at IS NULL AND rec.video_id = ANY(p.video_ids)
+		  )
+		ORDER BY rec.score DESC, rec.video_id
+		LIMIT $2
+	`, user.FloatplaneUserID, limit)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to fetch recommendations")
+		return
+	}
+	defer rows.Close()
+
+	recommendations := []models.Recommendation{}
+	var computedAt *time.Time
+	for rows.Next() {
+		var rec models.Recommendation
+		var at time.Time
+		if err := rows.Scan(&rec.VideoID, &rec.Score, &rec.BecauseVideoID, &at,
+			&rec.Title, &rec.ThumbnailURL, &rec.ChannelTitle, &rec.BecauseTitle); err != nil {
+			respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to scan recommendation")
+			return
+		}
+		computedAt = &at
+		recommendations = append(recommendations, rec)
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"recommendations": recommendations,
+		"count":           len(recommendations),
+		"computed_at":     computedAt,
+	})
+}
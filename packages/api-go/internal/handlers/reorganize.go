@@ -0,0 +1,428 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/database"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/middleware"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/models"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/services"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	maxMergeSources   = 50
+	maxSplitPlaylists = 100
+)
+
+// DuplicatePlaylist copies a playlist the user can view into a new playlist
+// they own. Smart playlists are copied with their rules.
+func DuplicatePlaylist(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+	id := chi.URLParam(r, "id")
+
+	var req struct {
+		Name *string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Invalid request body")
+		return
+	}
+	if req.Name != nil && *req.Name == "" {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Invalid name")
+		return
+	}
+	if req.Name != nil && isReservedName(*req.Name) {
+		respondError(w, http.StatusBadRequest, "Bad Request", `"Watch Later" is a reserved name`)
+		return
+	}
+	if req.Name != nil && !checkNameQuota(w, *req.Name) {
+		return
+	}
+
+	tx, err := database.Pool.Begin(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to start transaction")
+		return
+	}
+	defer tx.Rollback(r.Context())
+	r = r.WithContext(database.WithTx(r.Context(), tx))
+
+	if _, ok := requirePlaylistRole(w, r, id, user, RoleViewer); !ok {
+		return
+	}
+	var src models.Playlist
+	if err := scanPlaylist(database.Conn(r.Context()).QueryRow(r.Context(), `
+		SELECT `+playlistColumns+` FROM playlists WHERE id = $1
+	`, id), &src); err != nil {
+		respondError(w, http.StatusNotFound, "Not Found", "Playlist not found")
+		return
+	}
+
+	p := models.Playlist{
+		Name:         truncateName(src.Name + " (copy)"),
+		VideoIDs:     src.VideoIDs,
+		IsSmart:      src.IsSmart,
+		SmartRules:   src.SmartRules,
+		Description:  src.Description,
+		CoverVideoID: src.CoverVideoID,
+		Color:        src.Color,
+		Emoji:        src.Emoji,
+	}
+	if req.Name != nil {
+		p.Name = *req.Name
+	}
+	if !checkPlaylistQuota(w, r, user.FloatplaneUserID, 1) || !checkItemQuota(w, len(p.VideoIDs)) {
+		return
+	}
+	if err := insertPlaylist(r.Context(), user.FloatplaneUserID, &p); err != nil {
+		log.Printf("Failed to duplicate playlist %s: %v", id, err)
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to duplicate playlist")
+		return
+	}
+	if err := resolveSmartPlaylist(r.Context(), &p); err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to resolve smart playlist")
+		return
+	}
+	if err := tx.Commit(r.Context()); err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to duplicate playlist")
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, p)
+}
+
+// MergePlaylists appends the videos of the source playlists to the target in
+// order, skipping ones it already has. With delete_sources the sources are
+// moved to the trash, which needs owner access to each of them.
+func MergePlaylists(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+
+	var req struct {
+		TargetID      string   `json:"target_id"`
+		SourceIDs     []string `json:"source_ids"`
+		DeleteSources bool     `json:"delete_sources"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Invalid request body")
+		return
+	}
+	if req.TargetID == "" {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Missing target_id")
+		return
+	}
+	if len(req.SourceIDs) == 0 || len(req.SourceIDs) > maxMergeSources {
+		respondError(w, http.StatusBadRequest, "Bad Request", fmt.Sprintf("source_ids must contain between 1 and %d playlists", maxMergeSources))
+		return
+	}
+	seen := map[string]bool{req.TargetID: true}
+	for _, sourceID := range req.SourceIDs {
+		if seen[sourceID] {
+			respondError(w, http.StatusBadRequest, "Bad Request", "source_ids must be distinct and not include the target")
+			return
+		}
+		seen[sourceID] = true
+	}
+
+	tx, err := database.Pool.Begin(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to start transaction")
+		return
+	}
+	defer tx.Rollback(r.Context())
+	r = r.WithContext(database.WithTx(r.Context(), tx))
+	if err := services.LockPlaylistWrites(r.Context(), user.FloatplaneUserID); err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to merge playlists")
+		return
+	}
+
+	role, ok := requirePlaylistRole(w, r, req.TargetID, user, RoleEditor)
+	if !ok {
+		return
+	}
+	var target models.Playlist
+	if err := scanPlaylist(database.Conn(r.Context()).QueryRow(r.Context(), `
+		SELECT `+playlistColumns+` FROM playlists WHERE id = $1 FOR UPDATE
+	`, req.TargetID), &target); err != nil {
+		respondError(w, http.StatusNotFound, "Not Found", "Playlist not found")
+		return
+	}
+	if target.IsSmart {
+		respondError(w, http.StatusForbidden, "Forbidden", "Smart playlist items are read-only")
+		return
+	}
+
+	sourceRole := RoleViewer
+	if req.DeleteSources {
+		sourceRole = RoleOwner
+	}
+	merged := append([]string{}, target.VideoIDs...)
+	inTarget := make(map[string]bool, len(merged))
+	for _, vid := range merged {
+		inTarget[vid] = true
+	}
+	for _, sourceID := range req.SourceIDs {
+		if _, ok := requirePlaylistRole(w, r, sourceID, user, sourceRole); !ok {
+			return
+		}
+		var src models.Playlist
+		if err := scanPlaylist(database.Conn(r.Context()).QueryRow(r.Context(), `
+			SELECT `+playlistColumns+` FROM playlists WHERE id = $1 FOR UPDATE
+		`, sourceID), &src); err != nil {
+			respondError(w, http.StatusNotFound, "Not Found", "Playlist not found")
+			return
+		}
+		if req.DeleteSources && src.IsWatchLater {
+			respondError(w, http.StatusForbidden, "Forbidden", "Cannot delete Watch Later playlist")
+			return
+		}
+		if err := resolveSmartPlaylist(r.Context(), &src); err != nil {
+			respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to resolve smart playlist")
+			return
+		}
+		for _, vid := range src.VideoIDs {
+			if !inTarget[vid] {
+				inTarget[vid] = true
+				merged = append(merged, vid)
+			}
+		}
+	}
+	added := len(merged) - len(target.VideoIDs)
+	if added > 0 && !checkItemQuota(w, len(merged)) {
+		return
+	}
+
+	now := time.Now()
+	if err := scanPlaylist(database.Conn(r.Context()).QueryRow(r.Context(), `
+		UPDATE playlists SET video_ids = $1, updated_at = $2 WHERE id = $3
+		RETURNING `+playlistColumns+`
+	`, merged, now, req.TargetID), &target); err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to merge playlists")
+		return
+	}
+	syncPlaylistItems(r.Context(), target.ID, user.FloatplaneUserID, target.VideoIDs)
+	if added > 0 {
+		recordRevision(r.Context(), &target, RevisionMerge)
+	}
+
+	if req.DeleteSources {
+		if _, err := database.Conn(r.Context()).Exec(r.Context(), `
+			UPDATE playlists SET deleted_at = $1, updated_at = $1 WHERE id = ANY($2) AND deleted_at IS NULL
+		`, now, req.SourceIDs); err != nil {
+			respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to delete source playlists")
+			return
+		}
+		for _, sourceID := range req.SourceIDs {
+			publishEvent(r.Context(), user.FloatplaneUserID, services.EventPlaylistDeleted, map[string]string{"playlist_id": sourceID})
+		}
+	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to merge playlists")
+		return
+	}
+	target.Role = role
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"playlist":        target,
+		"added":           added,
+		"deleted_sources": req.DeleteSources,
+	})
+}
+
+// SplitPlaylist creates one playlist per channel or per release year of the
+// videos in a playlist, in order of first appearance. Videos that aren't in
+// fp_posts go into an "Other" playlist. The source is kept unless
+// delete_source is set, which needs owner access.
+func SplitPlaylist(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+	id := chi.URLParam(r, "id")
+
+	var req struct {
+		By           string `json:"by"`
+		DeleteSource bool   `json:"delete_source"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Invalid request body")
+		return
+	}
+	if req.By != "channel" && req.By != "year" {
+		respondError(w, http.StatusBadRequest, "Bad Request", "by must be channel or year")
+		return
+	}
+
+	tx, err := database.Pool.Begin(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to start transaction")
+		return
+	}
+	defer tx.Rollback(r.Context())
+	r = r.WithContext(database.WithTx(r.Context(), tx))
+	if err := services.LockPlaylistWrites(r.Context(), user.FloatplaneUserID); err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to split playlist")
+		return
+	}
+
+	minRole := RoleViewer
+	if req.DeleteSource {
+		minRole = RoleOwner
+	}
+	if _, ok := requirePlaylistRole(w, r, id, user, minRole); !ok {
+		return
+	}
+	var src models.Playlist
+	if err := scanPlaylist(database.Conn(r.Context()).QueryRow(r.Context(), `
+		SELECT `+playlistColumns+` FROM playlists WHERE id = $1 FOR UPDATE
+	`, id), &src); err != nil {
+		respondError(w, http.StatusNotFound, "Not Found", "Playlist not found")
+		return
+	}
+	if req.DeleteSource && src.IsWatchLater {
+		respondError(w, http.StatusForbidden, "Forbidden", "Cannot delete Watch Later playlist")
+		return
+	}
+	if err := resolveSmartPlaylist(r.Context(), &src); err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to resolve smart playlist")
+		return
+	}
+	if len(src.VideoIDs) == 0 {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Playlist has no videos to split")
+		return
+	}
+
+	posts, err := fetchPostsByIDs(r.Context(), src.VideoIDs)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to fetch posts")
+		return
+	}
+
+	// Group in order of first appearance
+	type group struct {
+		label    string
+		videoIDs []string
+	}
+	var groups []*group
+	byKey := make(map[string]*group)
+	for _, vid := range src.VideoIDs {
+		key, label := "", "Other"
+		if post, ok := posts[vid]; ok {
+			switch req.By {
+			case "channel":
+				if post.ChannelID != "" {
+					key, label = post.ChannelID, post.ChannelTitle
+					if label == "" {
+						label = post.ChannelID
+					}
+				}
+			case "year":
+				if !post.ReleaseDate.IsZero() {
+					key = strconv.Itoa(post.ReleaseDate.Year())
+					label = key
+				}
+			}
+		}
+		g, ok := byKey[key]
+		if !ok {
+			g = &group{label: label}
+			byKey[key] = g
+			groups = append(groups, g)
+		}
+		g.videoIDs = append(g.videoIDs, vid)
+	}
+	if len(groups) > maxSplitPlaylists {
+		respondError(w, http.StatusBadRequest, "Bad Request", fmt.Sprintf("Splitting would create more than %d playlists", maxSplitPlaylists))
+		return
+	}
+	if !checkPlaylistQuota(w, r, user.FloatplaneUserID, len(groups)) || !checkItemQuota(w, len(src.VideoIDs)) {
+		return
+	}
+
+	created := make([]models.Playlist, 0, len(groups))
+	for _, g := range groups {
+		p := models.Playlist{
+			Name:     truncateName(src.Name + " - " + g.label),
+			VideoIDs: g.videoIDs,
+			Color:    src.Color,
+			Emoji:    src.Emoji,
+		}
+		if err := insertPlaylist(r.Context(), user.FloatplaneUserID, &p); err != nil {
+			log.Printf("Failed to split playlist %s: %v", id, err)
+			respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to split playlist")
+			return
+		}
+		created = append(created, p)
+	}
+
+	if req.DeleteSource {
+		now := time.Now()
+		if _, err := database.Conn(r.Context()).Exec(r.Context(), `
+			UPDATE playlists SET deleted_at = $1, updated_at = $1 WHERE id = $2 AND deleted_at IS NULL
+		`, now, id); err != nil {
+			respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to delete source playlist")
+			return
+		}
+		publishEvent(r.Context(), user.FloatplaneUserID, services.EventPlaylistDeleted, map[string]string{"playlist_id": id})
+	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to split playlist")
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, map[string]interface{}{
+		"playlists": created,
+		"count":     len(created),
+	})
+}
+
+// insertPlaylist creates p for userID and scans the stored row back into it,
+// attributing its items to userID and recording a create revision.
+func insertPlaylist(ctx context.Context, userID string, p *models.Playlist) error {
+	if p.VideoIDs == nil {
+		p.VideoIDs = []string{}
+	}
+	err := scanPlaylist(database.Conn(ctx).QueryRow(ctx, `
+		INSERT INTO playlists (floatplane_user_id, name, video_ids, is_smart, smart_rules,
+		                       description, cover_video_id, color, emoji, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, ''), NULLIF($8, ''), NULLIF($9, ''), $10, $10)
+		RETURNING `+playlistColumns+`
+	`, userID, p.Name, p.VideoIDs, p.IsSmart, p.SmartRules,
+		p.Description, p.CoverVideoID, p.Color, p.Emoji, time.Now()), p)
+	if err != nil {
+		return err
+	}
+	syncPlaylistItems(ctx, p.ID, userID, p.VideoIDs)
+	recordRevision(ctx, p, RevisionCreate)
+	p.Role = RoleOwner
+	return nil
+}
+
+// truncateName shortens a generated playlist name to the name length quota.
+func truncateName(name string) string {
+	limit := services.UserLimits().MaxNameLength
+	if utf8.RuneCountInString(name) <= limit {
+		return name
+	}
+	return string([]rune(name)[:limit])
+}
@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/database"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/middleware"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/models"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/services"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	maxRevisionsPerPlaylist = 200
+	defaultHistoryPageSize  = 50
+)
+
+// Revision actions
+const (
+	RevisionCreate  = "create"
+	RevisionAdd     = "add"
+	RevisionRemove  = "remove"
+	RevisionReorder = "reorder"
+	RevisionRename  = "rename"
+	RevisionReplace = "replace"
+	RevisionRevert  = "revert"
+	RevisionRestore = "restore"
+	RevisionMerge   = "merge"
+	RevisionExpire  = "expire" // Written by the Watch Later expiry job
+)
+
+// recordRevision snapshots p as the result of action, attributed to the user
+// and device in ctx. Older revisions beyond maxRevisionsPerPlaylist are
+// dropped. History is best effort, so failures are logged rather than returned.
+func recordRevision(ctx context.Context, p *models.Playlist, action string) {
+	userID := p.FloatplaneUserID
+	if user, ok := ctx.Value(middleware.UserContextKey).(*models.User); ok {
+		userID = user.FloatplaneUserID
+	}
+	var sessionID, deviceInfo *string
+	if session, ok := ctx.Value(middleware.SessionContextKey).(*models.DeviceSession); ok {
+		sessionID, deviceInfo = &session.ID, &session.DeviceInfo
+	}
+
+	videoIDs := p.VideoIDs
+	if videoIDs == nil {
+		videoIDs = []string{}
+	}
+
+	err := database.Savepoint(ctx, func(ctx context.Context) error {
+		db := database.Conn(ctx)
+		_, err := db.Exec(ctx, `
+			INSERT INTO playlist_revisions (playlist_id, floatplane_user_id, device_session_id, device_info, action, name, video_ids, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		`, p.ID, userID, sessionID, deviceInfo, action, p.Name, videoIDs, time.Now())
+		if err != nil {
+			return err
+		}
+		_, err = db.Exec(ctx, `
+			DELETE FROM playlist_revisions
+			WHERE playlist_id = $1 AND id NOT IN (
+				SELECT id FROM playlist_revisions WHERE playlist_id = $1 ORDER BY id DESC LIMIT $2
+			)
+		`, p.ID, maxRevisionsPerPlaylist)
+		return err
+	})
+	if err != nil {
+		log.Printf("Failed to record %s revision for playlist %s: %v", action, p.ID, err)
+	}
+	publishPlaylistEvent(ctx, userID, p, action)
+}
+
+// classifyVideoChange describes replacing before with after as a reorder
+// (same videos, different order) or a replace. ok is false if nothing changed.
+func classifyVideoChange(before, after []string) (action string, ok bool) {
+	if len(before) == len(after) {
+		same := true
+		for i := range before {
+			if before[i] != after[i] {
+				same = false
+				break
+			}
+		}
+		if same {
+			return "", false
+		}
+
+		counts := make(map[string]int, len(before))
+		for _, vid := range before {
+			counts[vid]++
+		}
+		for _, vid := range after {
+			counts[vid]--
+		}
+		reordered := true
+		for _, n := range counts {
+			if n != 0 {
+				reordered = false
+				break
+			}
+		}
+		if reordered {
+			return RevisionReorder, true
+		}
+	}
+	return RevisionReplace, true
+}
+
+// GetPlaylistHistory lists a playlist's revisions, newest first.
+// Pass ?before=<revision id> to page back and ?limit= to set the page size.
+func GetPlaylistHistory(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+	id := chi.URLParam(r, "id")
+
+	limit := defaultHistoryPageSize
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxRevisionsPerPlaylist {
+			respondError(w, http.StatusBadRequest, "Bad Request", "Invalid limit")
+			return
+		}
+		limit = n
+	}
+	var before int64
+	if v := r.URL.Query().Get("before"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Bad Request", "Invalid before")
+			return
+		}
+		before = n
+	}
+
+	if _, ok := requirePlaylistRole(w, r, id, user, RoleViewer); !ok {
+		return
+	}
+
+	rows, err := database.Conn(r.Context()).Query(r.Context(), `
+		SELECT id, playlist_id, floatplane_user_id, COALESCE(device_session_id, ''), COALESCE(device_info, ''),
+		       action, name, video_ids, created_at
+		FROM playlist_revisions
+		WHERE playlist_id = $1 AND ($2 = 0 OR id < $2)
+		ORDER BY id DESC
+		LIMIT $3
+	`, id, before, limit)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to fetch history")
+		return
+	}
+	defer rows.Close()
+
+	revisions := []models.PlaylistRevision{}
+	for rows.Next() {
+		var rev models.PlaylistRevision
+		if err := rows.Scan(&rev.ID, &rev.PlaylistID, &rev.FloatplaneUserID, &rev.DeviceSessionID, &rev.DeviceInfo,
+			&rev.Action, &rev.Name, &rev.VideoIDs, &rev.CreatedAt); err != nil {
+			respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to scan revision")
+			return
+		}
+		revisions = append(revisions, rev)
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"revisions": revisions,
+		"count":     len(revisions),
+	})
+}
+
+// RevertPlaylist restores a playlist's name and videos to a past revision.
+// The revert itself is recorded as a new revision, so it can be undone too.
+func RevertPlaylist(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+	id := chi.URLParam(r, "id")
+
+	var req struct {
+		RevisionID int64 `json:"revision_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RevisionID == 0 {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Missing revision_id")
+		return
+	}
+
+	tx, err := database.Begin(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to start transaction")
+		return
+	}
+	defer tx.Rollback(r.Context())
+	r = r.WithContext(database.WithTx(r.Context(), tx))
+	if err := services.LockPlaylistWrites(r.Context(), user.FloatplaneUserID); err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to revert playlist")
+		return
+	}
+
+	role, ok := requirePlaylistRole(w, r, id, user, RoleEditor)
+	if !ok {
+		return
+	}
+
+	var rev models.PlaylistRevision
+	err = database.Conn(r.Context()).QueryRow(r.Context(), `
+		SELECT name, video_ids FROM playlist_revisions WHERE id = $1 AND playlist_id = $2
+	`, req.RevisionID, id).Scan(&rev.Name, &rev.VideoIDs)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Not Found", "Revision not found")
+		return
+	}
+	if !checkItemQuota(w, len(rev.VideoIDs)) {
+		return
+	}
+
+	// Watch Later keeps its name and smart playlists keep their rules; only
+	// the parts that can be edited are reverted.
+	var p models.Playlist
+	err = scanPlaylist(database.Conn(r.Context()).QueryRow(r.Context(), `
+		UPDATE playlists
+		SET name = CASE WHEN is_watch_later THEN name ELSE $1 END,
+		    video_ids = CASE WHEN is_smart THEN video_ids ELSE $2 END,
+		    updated_at = $3
+		WHERE id = $4
+		RETURNING `+playlistColumns+`
+	`, rev.Name, rev.VideoIDs, time.Now(), id), &p)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to revert playlist")
+		return
+	}
+	syncPlaylistItems(r.Context(), p.ID, user.FloatplaneUserID, p.VideoIDs)
+	recordRevision(r.Context(), &p, RevisionRevert)
+	p.Role = role
+	if err := resolveSmartPlaylist(r.Context(), &p); err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to resolve smart playlist")
+		return
+	}
+	if err := tx.Commit(r.Context()); err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to revert playlist")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, p)
+}
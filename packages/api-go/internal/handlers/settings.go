@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/database"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/middleware"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/models"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/services"
+)
+
+// Where an effective setting comes from
+const (
+	SettingSourceDefault = "default"
+	SettingSourceAccount = "account"
+	SettingSourceDevice  = "device"
+)
+
+// SettingsView is a user's effective settings for one device: the device's
+// overrides on top of the account settings on top of the defaults.
+type SettingsView struct {
+	Device    string                 `json:"device,omitempty"`
+	Settings  map[string]interface{} `json:"settings"`
+	Sources   map[string]string      `json:"sources"`
+	ChangedAt map[string]time.Time   `json:"changed_at"`
+}
+
+// settingsScope is one stored layer of settings. Device "" is the account.
+type settingsScope struct {
+	Values    map[string]json.RawMessage
+	ChangedAt map[string]time.Time
+}
+
+// settingsDevice returns the ?device= the request applies to, or "" for the
+// account settings.
+func settingsDevice(w http.ResponseWriter, r *http.Request) (string, bool) {
+	device := strings.TrimSpace(r.URL.Query().Get("device"))
+	if len(device) > maxDeviceLength {
+		respondError(w, http.StatusBadRequest, "Bad Request", "device is too long")
+		return "", false
+	}
+	return device, true
+}
+
+// loadSettingsScopes returns the user's stored layers for the account and
+// device, keyed by device. Missing layers are left out.
+func loadSettingsScopes(ctx context.Context, userID, device string, forUpdate bool) (map[string]settingsScope, error) {
+	query := `
+		SELECT device, settings, changed_at FROM user_settings
+		WHERE floatplane_user_id = $1 AND device IN ('', $2)
+	`
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+	rows, err := database.Conn(ctx).Query(ctx, query, userID, device)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	scopes := make(map[string]settingsScope)
+	for rows.Next() {
+		var d string
+		var s settingsScope
+		if err := rows.Scan(&d, &s.Values, &s.ChangedAt); err != nil {
+			return nil, err
+		}
+		scopes[d] = s
+	}
+	return scopes, rows.Err()
+}
+
+// buildSettingsView layers the stored scopes over the defaults. Stored values
+// the schema no longer accepts fall through to the next layer.
+func buildSettingsView(scopes map[string]settingsScope, device string) SettingsView {
+	view := SettingsView{
+		Device:    device,
+		Settings:  make(map[string]interface{}),
+		Sources:   make(map[string]string),
+		ChangedAt: make(map[string]time.Time),
+	}
+	layers := []struct{ device, source string }{{"", SettingSourceAccount}}
+	if device != "" {
+		layers = append(layers, struct{ device, source string }{device, SettingSourceDevice})
+	}
+
+	for _, key := range services.SettingKeys() {
+		view.Settings[key] = services.SettingsSchema[key].Default
+		view.Sources[key] = SettingSourceDefault
+		for _, layer := range layers {
+			raw, ok := scopes[layer.device].Values[key]
+			if !ok {
+				continue
+			}
+			v, err := services.ValidateSetting(key, raw)
+			if err != nil {
+				continue
+			}
+			view.Settings[key] = v
+			view.Sources[key] = layer.source
+			if at, ok := scopes[layer.device].ChangedAt[key]; ok {
+				view.ChangedAt[key] = at
+			}
+		}
+	}
+	return view
+}
+
+// GetSettings returns the user's effective settings. With ?device= the
+// device's overrides apply; a device without any inherits the account's.
+func GetSettings(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+	device, ok := settingsDevice(w, r)
+	if !ok {
+		return
+	}
+
+	scopes, err := loadSettingsScopes(r.Context(), user.FloatplaneUserID, device, false)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to fetch settings")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, buildSettingsView(scopes, device))
+}
+
+// GetSettingsSchema lists the settings the server accepts, with their
+// defaults and limits.
+func GetSettingsSchema(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, map[string]interface{}{"settings": services.SettingsSchema})
+}
+
+// ReplaceSettings replaces every value in the account settings, or with
+// ?device= the device's overrides.
+func ReplaceSettings(w http.ResponseWriter, r *http.Request) {
+	saveSettings(w, r, false)
+}
+
+// PatchSettings changes only the values sent. null removes a value, so it
+// falls back to the account setting or the default.
+func PatchSettings(w http.ResponseWriter, r *http.Request) {
+	saveSettings(w, r, true)
+}
+
+// saveSettings handles both PUT and PATCH. Only values that actually change
+// get a new timestamp.
+func saveSettings(w http.ResponseWriter, r *http.Request, patch bool) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+	device, ok := settingsDevice(w, r)
+	if !ok {
+		return
+	}
+
+	var req map[string]json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req == nil {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Body must be an object of settings")
+		return
+	}
+	updates := make(map[string]json.RawMessage, len(req))
+	for key, raw := range req {
+		if bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+			if _, known := services.SettingsSchema[key]; !known {
+				respondError(w, http.StatusBadRequest, "Bad Request", "unknown setting \""+key+"\"")
+				return
+			}
+			updates[key] = nil
+			continue
+		}
+		v, err := services.ValidateSetting(key, raw)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Bad Request", err.Error())
+			return
+		}
+		updates[key], _ = json.Marshal(v)
+	}
+
+	tx, err := database.Pool.Begin(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to start transaction")
+		return
+	}
+	defer tx.Rollback(r.Context())
+	r = r.WithContext(database.WithTx(r.Context(), tx))
+
+	scopes, err := loadSettingsScopes(r.Context(), user.FloatplaneUserID, device, true)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to fetch settings")
+		return
+	}
+	current := scopes[device]
+
+	now := time.Now()
+	next := settingsScope{Values: make(map[string]json.RawMessage), ChangedAt: make(map[string]time.Time)}
+	if patch {
+		for key, raw := range current.Values {
+			next.Values[key] = raw
+			if at, ok := current.ChangedAt[key]; ok {
+				next.ChangedAt[key] = at
+			}
+		}
+	}
+	for key, raw := range updates {
+		if raw == nil {
+			delete(next.Values, key)
+			delete(next.ChangedAt, key)
+			continue
+		}
+		next.Values[key] = raw
+		next.ChangedAt[key] = now
+		if old, ok := current.Values[key]; ok {
+			if v, err := services.ValidateSetting(key, old); err == nil {
+				if canonical, _ := json.Marshal(v); bytes.Equal(canonical, raw) {
+					if at, ok := current.ChangedAt[key]; ok {
+						next.ChangedAt[key] = at
+					}
+				}
+			}
+		}
+	}
+
+	_, err = database.Conn(r.Context()).Exec(r.Context(), `
+		INSERT INTO user_settings (floatplane_user_id, device, settings, changed_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (floatplane_user_id, device) DO UPDATE
+		SET settings = EXCLUDED.settings, changed_at = EXCLUDED.changed_at, updated_at = EXCLUDED.updated_at
+	`, user.FloatplaneUserID, device, next.Values, next.ChangedAt, now)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to save settings")
+		return
+	}
+	if err := tx.Commit(r.Context()); err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to save settings")
+		return
+	}
+
+	scopes[device] = next
+	respondJSON(w, http.StatusOK, buildSettingsView(scopes, device))
+}
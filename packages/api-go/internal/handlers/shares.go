@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/database"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/middleware"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/models"
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+// SharedPlaylistResponse is the public view of a shared playlist.
+// It intentionally omits the owner's Floatplane user ID.
+type SharedPlaylistResponse struct {
+	Name      string          `json:"name"`
+	VideoIDs  []string        `json:"video_ids"`
+	Videos    []models.FPPost `json:"videos"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// CreatePlaylistShare returns the active share link for a playlist, creating one if needed.
+func CreatePlaylistShare(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+	id := chi.URLParam(r, "id")
+
+	if _, ok := requirePlaylistRole(w, r, id, user, RoleOwner); !ok {
+		return
+	}
+
+	// Only one link can be active per playlist (idx_playlist_shares_active),
+	// so a concurrent request that created one first makes this insert a no-op.
+	var share models.PlaylistShare
+	err := database.Conn(r.Context()).QueryRow(r.Context(), `
+		INSERT INTO playlist_shares (token, playlist_id, created_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (playlist_id) WHERE revoked_at IS NULL DO NOTHING
+		RETURNING token, playlist_id, created_at
+	`, generateRandomKey(), id, time.Now()).Scan(&share.Token, &share.PlaylistID, &share.CreatedAt)
+	if err == nil {
+		respondJSON(w, http.StatusCreated, share)
+		return
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to create share")
+		return
+	}
+
+	err = database.Conn(r.Context()).QueryRow(r.Context(), `
+		SELECT token, playlist_id, created_at FROM playlist_shares
+		WHERE playlist_id = $1 AND revoked_at IS NULL
+	`, id).Scan(&share.Token, &share.PlaylistID, &share.CreatedAt)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to fetch share")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, share)
+}
+
+// RevokePlaylistShare revokes every active share link for a playlist.
+func RevokePlaylistShare(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+	id := chi.URLParam(r, "id")
+
+	if _, ok := requirePlaylistRole(w, r, id, user, RoleOwner); !ok {
+		return
+	}
+
+	_, err := database.Conn(r.Context()).Exec(r.Context(), `
+		UPDATE playlist_shares SET revoked_at = $1 WHERE playlist_id = $2 AND revoked_at IS NULL
+	`, time.Now(), id)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to revoke share")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetSharedPlaylist returns a shared playlist with post metadata. Public.
+func GetSharedPlaylist(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+
+	var p models.Playlist
+	err := database.Conn(r.Context()).QueryRow(r.Context(), `
+		SELECT p.name, p.video_ids, p.updated_at, p.is_smart, p.smart_rules
+		FROM playlist_shares s JOIN playlists p ON p.id = s.playlist_id
+		WHERE s.token = $1 AND s.revoked_at IS NULL AND p.deleted_at IS NULL
+	`, token).Scan(&p.Name, &p.VideoIDs, &p.UpdatedAt, &p.IsSmart, &p.SmartRules)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Not Found", "Shared playlist not found")
+		return
+	}
+	if err := resolveSmartPlaylist(r.Context(), &p); err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to resolve smart playlist")
+		return
+	}
+
+	posts, err := fetchPostsByIDs(r.Context(), p.VideoIDs)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to fetch posts")
+		return
+	}
+
+	videos := []models.FPPost{}
+	for _, vid := range p.VideoIDs {
+		if post, ok := posts[vid]; ok {
+			videos = append(videos, post)
+		}
+	}
+
+	respondJSON(w, http.StatusOK, SharedPlaylistResponse{
+		Name:      p.Name,
+		VideoIDs:  p.VideoIDs,
+		Videos:    videos,
+		UpdatedAt: p.UpdatedAt,
+	})
+}
+
+// ImportSharedPlaylist copies a shared playlist into the caller's playlists.
+func ImportSharedPlaylist(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+	token := chi.URLParam(r, "token")
+
+	// Body is optional; it only allows overriding the copy's name.
+	var req struct {
+		Name *string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Invalid request body")
+		return
+	}
+
+	var src models.Playlist
+	err := database.Conn(r.Context()).QueryRow(r.Context(), `
+		SELECT p.name, p.video_ids, p.is_smart, p.smart_rules
+		FROM playlist_shares s JOIN playlists p ON p.id = s.playlist_id
+		WHERE s.token = $1 AND s.revoked_at IS NULL AND p.deleted_at IS NULL
+	`, token).Scan(&src.Name, &src.VideoIDs, &src.IsSmart, &src.SmartRules)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Not Found", "Shared playlist not found")
+		return
+	}
+	// Smart playlists are imported as a snapshot of their current videos
+	if err := resolveSmartPlaylist(r.Context(), &src); err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to resolve smart playlist")
+		return
+	}
+
+	name := src.Name
+	if isReservedName(name) {
+		name = truncateName(name + " (copy)")
+	}
+	if req.Name != nil {
+		name = *req.Name
+	}
+	if name == "" {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Invalid name")
+		return
+	}
+	if isReservedName(name) {
+		respondError(w, http.StatusBadRequest, "Bad Request", `"Watch Later" is a reserved name`)
+		return
+	}
+	if !checkNameQuota(w, name) || !checkItemQuota(w, len(src.VideoIDs)) {
+		return
+	}
+
+	tx, err := database.Begin(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to start transaction")
+		return
+	}
+	defer tx.Rollback(r.Context())
+	r = r.WithContext(database.WithTx(r.Context(), tx))
+
+	if !checkPlaylistQuota(w, r, user.FloatplaneUserID, 1) {
+		return
+	}
+
+	var p models.Playlist
+	err = scanPlaylist(database.Conn(r.Context()).QueryRow(r.Context(), `
+		INSERT INTO playlists (floatplane_user_id, name, video_ids, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $4)
+		RETURNING `+playlistColumns+`
+	`, user.FloatplaneUserID, name, src.VideoIDs, time.Now()), &p)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to import playlist")
+		return
+	}
+	syncPlaylistItems(r.Context(), p.ID, user.FloatplaneUserID, p.VideoIDs)
+	recordRevision(r.Context(), &p, RevisionCreate)
+	if err := tx.Commit(r.Context()); err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to import playlist")
+		return
+	}
+	p.Role = RoleOwner
+
+	respondJSON(w, http.StatusCreated, p)
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/database"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/models"
+)
+
+const (
+	defaultSmartLimit  = 100
+	maxSmartLimit      = 500
+	maxSmartListValues = 20
+)
+
+// validateSmartRules returns a message describing the first problem with rules,
+// or "" if they are valid. It also applies the default limit.
+func validateSmartRules(rules *models.SmartRules) string {
+	if rules.Limit == 0 {
+		rules.Limit = defaultSmartLimit
+	}
+	if rules.Limit < 0 || rules.Limit > maxSmartLimit {
+		return fmt.Sprintf("limit must be between 1 and %d", maxSmartLimit)
+	}
+	if len(rules.CreatorIDs) > maxSmartListValues || len(rules.ChannelIDs) > maxSmartListValues || len(rules.TitleKeywords) > maxSmartListValues {
+		return fmt.Sprintf("At most %d creator_ids, channel_ids or title_keywords are allowed", maxSmartListValues)
+	}
+	if (rules.MinDuration != nil && *rules.MinDuration < 0) || (rules.MaxDuration != nil && *rules.MaxDuration < 0) {
+		return "Durations must not be negative"
+	}
+	if rules.MinDuration != nil && rules.MaxDuration != nil && *rules.MinDuration > *rules.MaxDuration {
+		return "min_duration must not exceed max_duration"
+	}
+	if rules.ReleasedWithinDays != nil && *rules.ReleasedWithinDays <= 0 {
+		return "released_within_days must be positive"
+	}
+	for _, kw := range rules.TitleKeywords {
+		if strings.TrimSpace(kw) == "" {
+			return "title_keywords must not be empty"
+		}
+	}
+	return ""
+}
+
+// resolveSmartPlaylist fills p.VideoIDs from fp_posts if p is a smart playlist.
+func resolveSmartPlaylist(ctx context.Context, p *models.Playlist) error {
+	if !p.IsSmart || p.SmartRules == nil {
+		return nil
+	}
+	ids, err := smartPlaylistVideoIDs(ctx, p.SmartRules)
+	if err != nil {
+		return err
+	}
+	p.VideoIDs = ids
+	p.ItemCount = len(ids)
+	return nil
+}
+
+// smartPlaylistVideoIDs evaluates rules against fp_posts, newest first.
+func smartPlaylistVideoIDs(ctx context.Context, rules *models.SmartRules) ([]string, error) {
+	var conds []string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if len(rules.CreatorIDs) > 0 {
+		conds = append(conds, "creator_id = ANY("+arg(rules.CreatorIDs)+")")
+	}
+	if len(rules.ChannelIDs) > 0 {
+		conds = append(conds, "channel_id = ANY("+arg(rules.ChannelIDs)+")")
+	}
+	for _, kw := range rules.TitleKeywords {
+		conds = append(conds, "title ILIKE "+arg("%"+strings.TrimSpace(kw)+"%"))
+	}
+	if rules.MinDuration != nil {
+		conds = append(conds, "video_duration >= "+arg(*rules.MinDuration))
+	}
+	if rules.MaxDuration != nil {
+		conds = append(conds, "video_duration <= "+arg(*rules.MaxDuration))
+	}
+	if rules.ReleasedWithinDays != nil {
+		conds = append(conds, "release_date >= "+arg(time.Now().AddDate(0, 0, -*rules.ReleasedWithinDays)))
+	}
+	if rules.ReleasedAfter != nil {
+		conds = append(conds, "release_date >= "+arg(*rules.ReleasedAfter))
+	}
+	if rules.ReleasedBefore != nil {
+		conds = append(conds, "release_date < "+arg(*rules.ReleasedBefore))
+	}
+	if rules.HasVideo != nil {
+		conds = append(conds, "has_video = "+arg(*rules.HasVideo))
+	}
+	if rules.HasAudio != nil {
+		conds = append(conds, "has_audio = "+arg(*rules.HasAudio))
+	}
+
+	where := ""
+	if len(conds) > 0 {
+		where = "WHERE " + strings.Join(conds, " AND ")
+	}
+	limit := rules.Limit
+	if limit <= 0 || limit > maxSmartLimit {
+		limit = defaultSmartLimit
+	}
+
+	rows, err := database.Conn(ctx).Query(ctx, `
+		SELECT id FROM fp_posts `+where+`
+		ORDER BY release_date DESC NULLS LAST
+		LIMIT `+arg(limit), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,474 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/database"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/middleware"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	defaultSyncPageSize = 500
+	maxSyncPageSize     = 1000
+	maxSyncOperations   = 500
+	maxSyncOpIDLength   = 100
+)
+
+// SyncTombstone tells a client to drop a playlist. Reason is trashed (it can
+// still be restored), deleted (purged from the trash) or removed (the user is
+// no longer a member).
+type SyncTombstone struct {
+	PlaylistID  string    `json:"playlist_id"`
+	Reason      string    `json:"reason"`
+	SyncVersion int64     `json:"sync_version"`
+	DeletedAt   time.Time `json:"deleted_at"`
+}
+
+type SyncResponse struct {
+	Playlists []models.Playlist `json:"playlists"`
+	Deleted   []SyncTombstone   `json:"deleted"`
+	Cursor    string            `json:"cursor"`
+	HasMore   bool              `json:"has_more"`
+}
+
+// SyncOperation is one queued offline mutation. Data is the request body of
+// the equivalent REST call. PlaylistID may be the client_id of a playlist
+// created earlier in the same queue.
+type SyncOperation struct {
+	OpID        string          `json:"op_id"`
+	Type        string          `json:"type"`
+	PlaylistID  string          `json:"playlist_id,omitempty"`
+	ClientID    string          `json:"client_id,omitempty"`    // create_playlist only
+	BaseVersion int64           `json:"base_version,omitempty"` // Reject as a conflict if the playlist changed since
+	Data        json.RawMessage `json:"data,omitempty"`
+}
+
+// SyncOperationResult is the outcome of a SyncOperation. Status is applied,
+// conflict, rejected or failed; Result is the REST response body, or the
+// current playlist on a conflict.
+type SyncOperationResult struct {
+	OpID       string          `json:"op_id"`
+	Status     string          `json:"status"`
+	Code       int             `json:"code"`
+	PlaylistID string          `json:"playlist_id,omitempty"`
+	ClientID   string          `json:"client_id,omitempty"`
+	Result     json.RawMessage `json:"result,omitempty"`
+}
+
+// syncChange is a playlist or tombstone at a syncCursor.
+type syncChange struct {
+	cursor    syncCursor
+	playlist  *models.Playlist
+	tombstone *SyncTombstone
+}
+
+// syncCursor is a position in a user's changes. Versions are handed out when
+// a row is written rather than when it commits, so changes are ordered by the
+// transaction that wrote them, then by version, and GET /sync only returns
+// those from transactions older than any still running. A change committed
+// later always comes after the cursor returned. See
+// migrations/000021_sync_order.up.sql.
+type syncCursor struct {
+	xid     int64
+	version int64
+}
+
+func (c syncCursor) before(d syncCursor) bool {
+	return c.xid < d.xid || (c.xid == d.xid && c.version < d.version)
+}
+
+func (c syncCursor) String() string {
+	return fmt.Sprintf("%d.%d", c.xid, c.version)
+}
+
+// parseSyncCursor parses a cursor returned by GET /sync. Plain versions from
+// before cursors held a transaction start a full sync.
+func parseSyncCursor(s string) (syncCursor, bool) {
+	xid, version, found := strings.Cut(s, ".")
+	if !found {
+		n, err := strconv.ParseInt(s, 10, 64)
+		return syncCursor{}, err == nil && n >= 0
+	}
+	var c syncCursor
+	var err1, err2 error
+	c.xid, err1 = strconv.ParseInt(xid, 10, 64)
+	c.version, err2 = strconv.ParseInt(version, 10, 64)
+	return c, err1 == nil && err2 == nil && c.xid >= 0 && c.version >= 0
+}
+
+// GetSync returns the playlists that changed since the ?since= cursor, and
+// tombstones for the ones the user can no longer see. Omit since for a full
+// sync. Pages hold up to ?limit= changes; keep calling with the returned
+// cursor while has_more is true.
+func GetSync(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+
+	var since syncCursor
+	if v := r.URL.Query().Get("since"); v != "" {
+		var ok bool
+		if since, ok = parseSyncCursor(v); !ok {
+			respondError(w, http.StatusBadRequest, "Bad Request", "Invalid since cursor")
+			return
+		}
+	}
+	limit := defaultSyncPageSize
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxSyncPageSize {
+			respondError(w, http.StatusBadRequest, "Bad Request", fmt.Sprintf("limit must be between 1 and %d", maxSyncPageSize))
+			return
+		}
+		limit = n
+	}
+
+	var settled int64
+	if err := database.Conn(r.Context()).QueryRow(r.Context(), `
+		SELECT pg_snapshot_xmin(pg_current_snapshot())::text::bigint
+	`).Scan(&settled); err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to fetch playlists")
+		return
+	}
+
+	var changes []syncChange
+	rows, err := database.Conn(r.Context()).Query(r.Context(), `
+		SELECT `+playlistColumns+`,
+		       CASE WHEN floatplane_user_id = $1 THEN 'owner'
+		            ELSE (SELECT role FROM playlist_members m WHERE m.playlist_id = playlists.id AND m.floatplane_user_id = $1)
+		       END,
+		       sync_xid::text::bigint
+		FROM playlists
+		WHERE (floatplane_user_id = $1 OR id IN (SELECT playlist_id FROM playlist_members WHERE floatplane_user_id = $1))
+		  AND (sync_xid, sync_version) > ($2::bigint::text::xid8, $3)
+		  AND sync_xid < $4::bigint::text::xid8
+		ORDER BY sync_xid, sync_version
+		LIMIT $5
+	`, user.FloatplaneUserID, since.xid, since.version, settled, limit+1)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to fetch playlists")
+		return
+	}
+	for rows.Next() {
+		var p models.Playlist
+		var xid int64
+		if err := scanPlaylist(rows, &p, &p.Role, &xid); err != nil {
+			rows.Close()
+			respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to scan playlist")
+			return
+		}
+		cursor := syncCursor{xid: xid, version: p.SyncVersion}
+		change := syncChange{cursor: cursor, playlist: &p}
+		if p.DeletedAt != nil {
+			change = syncChange{cursor: cursor, tombstone: &SyncTombstone{
+				PlaylistID: p.ID, Reason: "trashed", SyncVersion: p.SyncVersion, DeletedAt: *p.DeletedAt,
+			}}
+		}
+		changes = append(changes, change)
+	}
+	rows.Close()
+
+	rows, err = database.Conn(r.Context()).Query(r.Context(), `
+		SELECT playlist_id, reason, sync_version, created_at, sync_xid::text::bigint
+		FROM sync_tombstones
+		WHERE floatplane_user_id = $1
+		  AND (sync_xid, sync_version) > ($2::bigint::text::xid8, $3)
+		  AND sync_xid < $4::bigint::text::xid8
+		ORDER BY sync_xid, sync_version
+		LIMIT $5
+	`, user.FloatplaneUserID, since.xid, since.version, settled, limit+1)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to fetch tombstones")
+		return
+	}
+	var tombstones []syncChange
+	for rows.Next() {
+		var t SyncTombstone
+		var xid int64
+		if err := rows.Scan(&t.PlaylistID, &t.Reason, &t.SyncVersion, &t.DeletedAt, &xid); err != nil {
+			rows.Close()
+			respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to scan tombstone")
+			return
+		}
+		tombstones = append(tombstones, syncChange{cursor: syncCursor{xid: xid, version: t.SyncVersion}, tombstone: &t})
+	}
+	rows.Close()
+
+	page, hasMore := mergeSyncChanges(changes, tombstones, limit)
+
+	// Past the last page, move on to where the next change must be
+	next := since
+	if hasMore {
+		next = page[len(page)-1].cursor
+	} else if settledCursor := (syncCursor{xid: settled}); next.before(settledCursor) {
+		next = settledCursor
+	}
+	resp := SyncResponse{
+		Playlists: []models.Playlist{},
+		Deleted:   []SyncTombstone{},
+		Cursor:    next.String(),
+		HasMore:   hasMore,
+	}
+
+	// Only the latest change to each playlist matters, e.g. a member who was
+	// removed and then re-invited within the same page.
+	latest := make(map[string]int, len(page))
+	for i, c := range page {
+		latest[c.playlistID()] = i
+	}
+	for i, c := range page {
+		if latest[c.playlistID()] != i {
+			continue
+		}
+		if c.playlist != nil {
+			if err := resolveSmartPlaylist(r.Context(), c.playlist); err != nil {
+				respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to resolve smart playlist")
+				return
+			}
+			resp.Playlists = append(resp.Playlists, *c.playlist)
+		} else {
+			resp.Deleted = append(resp.Deleted, *c.tombstone)
+		}
+	}
+
+	respondJSON(w, http.StatusOK, resp)
+}
+
+func (c syncChange) playlistID() string {
+	if c.playlist != nil {
+		return c.playlist.ID
+	}
+	return c.tombstone.PlaylistID
+}
+
+// mergeSyncChanges merges two lists sorted by cursor and returns the first
+// limit changes, and whether there are more after them.
+func mergeSyncChanges(a, b []syncChange, limit int) ([]syncChange, bool) {
+	merged := make([]syncChange, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		if j >= len(b) || (i < len(a) && a[i].cursor.before(b[j].cursor)) {
+			merged = append(merged, a[i])
+			i++
+		} else {
+			merged = append(merged, b[j])
+			j++
+		}
+	}
+	if len(merged) > limit {
+		return merged[:limit], true
+	}
+	return merged, false
+}
+
+// PostSync applies a queue of offline mutations in order and reports the
+// outcome of each. Every operation needs a unique op_id: results are stored,
+// so resending a queue after a dropped connection returns the original
+// results instead of applying the operations again.
+func PostSync(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+
+	var req struct {
+		Operations []SyncOperation `json:"operations"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Invalid request body")
+		return
+	}
+	if len(req.Operations) > maxSyncOperations {
+		respondError(w, http.StatusBadRequest, "Bad Request", fmt.Sprintf("At most %d operations are allowed", maxSyncOperations))
+		return
+	}
+	for _, op := range req.Operations {
+		if op.OpID == "" || len(op.OpID) > maxSyncOpIDLength {
+			respondError(w, http.StatusBadRequest, "Bad Request", fmt.Sprintf("Every operation needs an op_id of at most %d characters", maxSyncOpIDLength))
+			return
+		}
+	}
+
+	clientIDs := make(map[string]string) // client_id -> playlist ID
+	results := make([]SyncOperationResult, 0, len(req.Operations))
+	for _, op := range req.Operations {
+		result, err := runSyncOperation(r, user, op, clientIDs)
+		if err != nil {
+			log.Printf("Failed to apply sync operation %s: %v", op.OpID, err)
+			respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to apply operation")
+			return
+		}
+		if result.ClientID != "" && result.PlaylistID != "" {
+			clientIDs[result.ClientID] = result.PlaylistID
+		}
+		results = append(results, *result)
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"results": results,
+	})
+}
+
+// runSyncOperation applies op and stores its result, or returns the stored
+// result if it was applied before. The op_id is reserved in the transaction
+// that applies the operation, so a retry sent while the first attempt is
+// still running waits for it and gets its result instead of applying op
+// again. Failures (5xx) aren't stored, so a retry applies op afresh.
+func runSyncOperation(r *http.Request, user *models.User, op SyncOperation, clientIDs map[string]string) (*SyncOperationResult, error) {
+	ctx := r.Context()
+	tx, err := database.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO sync_operations (floatplane_user_id, op_id, result, created_at)
+		VALUES ($1, $2, '{}', $3)
+		ON CONFLICT DO NOTHING
+	`, user.FloatplaneUserID, op.OpID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		tx.Rollback(ctx)
+		return storedSyncResult(ctx, user.FloatplaneUserID, op.OpID)
+	}
+
+	// A savepoint undoes a rejected operation's writes, and recovers the
+	// transaction if one of its queries failed, while keeping the reservation
+	sp, err := tx.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := applySyncOperation(r.WithContext(database.WithTx(ctx, sp)), user, op, clientIDs)
+	if result.Code >= 500 {
+		return &result, nil
+	}
+	if result.Code < 300 {
+		err = sp.Commit(ctx)
+	} else {
+		err = sp.Rollback(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE sync_operations SET result = $3 WHERE floatplane_user_id = $1 AND op_id = $2
+	`, user.FloatplaneUserID, op.OpID, result)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// applySyncOperation runs op through the handler for the equivalent REST call.
+func applySyncOperation(r *http.Request, user *models.User, op SyncOperation, clientIDs map[string]string) SyncOperationResult {
+	result := SyncOperationResult{OpID: op.OpID}
+	reject := func(code int, message string) SyncOperationResult {
+		result.Code = code
+		result.Status = syncStatus(code)
+		result.Result = operationError(code, message)
+		return result
+	}
+
+	spec, ok := playlistOperations[op.Type]
+	if !ok {
+		return reject(http.StatusBadRequest, "Unknown operation type "+op.Type)
+	}
+
+	if spec.target {
+		id := op.PlaylistID
+		if mapped, ok := clientIDs[id]; ok {
+			id = mapped
+		}
+		if id == "" {
+			return reject(http.StatusBadRequest, "Missing playlist_id")
+		}
+		result.PlaylistID = id
+
+		if op.BaseVersion > 0 {
+			current, err := currentPlaylist(r.Context(), id, user.FloatplaneUserID)
+			if err != nil && !errors.Is(err, pgx.ErrNoRows) && !isInvalidInput(err) {
+				return reject(http.StatusInternalServerError, "Failed to check playlist version")
+			}
+			// A playlist the user can't see is left for the handler to reject
+			if err == nil && current.SyncVersion > op.BaseVersion {
+				result.Code = http.StatusConflict
+				result.Status = syncStatus(result.Code)
+				result.Result, _ = json.Marshal(current)
+				return result
+			}
+		}
+	}
+
+	result.Code, result.Result = runPlaylistOperation(r.Context(), r, spec, result.PlaylistID, op.Data)
+	result.Status = syncStatus(result.Code)
+	if op.Type == "create_playlist" && result.Status == "applied" {
+		var created struct {
+			ID string `json:"id"`
+		}
+		json.Unmarshal(result.Result, &created)
+		result.PlaylistID = created.ID
+		result.ClientID = op.ClientID
+	}
+	return result
+}
+
+// currentPlaylist loads a live playlist the user has access to.
+func currentPlaylist(ctx context.Context, id, userID string) (*models.Playlist, error) {
+	role, err := playlistRole(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	var p models.Playlist
+	if err := scanPlaylist(database.Conn(ctx).QueryRow(ctx, `SELECT `+playlistColumns+` FROM playlists WHERE id = $1`, id), &p); err != nil {
+		return nil, err
+	}
+	p.Role = role
+	if err := resolveSmartPlaylist(ctx, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func syncStatus(code int) string {
+	switch {
+	case code < 300:
+		return "applied"
+	case code == http.StatusConflict:
+		return "conflict"
+	case code < 500:
+		return "rejected"
+	}
+	return "failed"
+}
+
+// storedSyncResult returns the stored result of an operation.
+func storedSyncResult(ctx context.Context, userID, opID string) (*SyncOperationResult, error) {
+	var result SyncOperationResult
+	err := database.Conn(ctx).QueryRow(ctx, `
+		SELECT result FROM sync_operations WHERE floatplane_user_id = $1 AND op_id = $2
+	`, userID, opID).Scan(&result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/database"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/models"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/services"
+)
+
+const defaultTrendingLimit = 20
+
+// recordVideoAdd notes that the user added a video to Watch Later or a
+// playlist, for trending. Only single adds are recorded, so imports and
+// reorganising don't count. Failures are logged rather than returned.
+func recordVideoAdd(ctx context.Context, userID, videoID string) {
+	err := database.Savepoint(ctx, func(ctx context.Context) error {
+		_, err := database.Conn(ctx).Exec(ctx, `
+			INSERT INTO video_adds (floatplane_user_id, video_id, added_at) VALUES ($1, $2, $3)
+		`, userID, videoID, time.Now())
+		return err
+	})
+	if err != nil {
+		log.Printf("Failed to record add of %s: %v", videoID, err)
+	}
+}
+
+// GetTrending returns the posts added to Watch Later and playlists by the
+// most users in a window. It is computed hourly from counts alone.
+//
+// Query parameters:
+//   - window: 24h (default) or 7d
+//   - limit: max posts (default 20, max 100)
+func GetTrending(w http.ResponseWriter, r *http.Request) {
+	window := r.URL.Query().Get("window")
+	if window == "" {
+		window = services.TrendingWindowDay
+	}
+	if _, ok := services.TrendingWindows[window]; !ok {
+		respondError(w, http.StatusBadRequest, "Bad Request", fmt.Sprintf("window must be %s or %s", services.TrendingWindowDay, services.TrendingWindowWeek))
+		return
+	}
+
+	limit := defaultTrendingLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > services.MaxTrending {
+			respondError(w, http.StatusBadRequest, "Bad Request", fmt.Sprintf("limit must be between 1 and %d", services.MaxTrending))
+			return
+		}
+		limit = n
+	}
+
+	rows, err := database.Conn(r.Context()).Query(r.Context(), `
+		SELECT video_id, rank, users, computed_at FROM trending
+		WHERE time_window = $1
+		ORDER BY rank
+		LIMIT $2
+	`, window, limit)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to fetch trending posts")
+		return
+	}
+	var ranked []models.TrendingPost
+	var ids []string
+	var computedAt *time.Time
+	for rows.Next() {
+		var p models.TrendingPost
+		var at time.Time
+		if err := rows.Scan(&p.ID, &p.Rank, &p.SavedBy, &at); err != nil {
+			rows.Close()
+			respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to scan trending post")
+			return
+		}
+		computedAt = &at
+		ranked = append(ranked, p)
+		ids = append(ids, p.ID)
+	}
+	rows.Close()
+	if rows.Err() != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to fetch trending posts")
+		return
+	}
+
+	known, err := fetchPostsByIDs(r.Context(), ids)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to fetch posts")
+		return
+	}
+	posts := []models.TrendingPost{}
+	for _, p := range ranked {
+		post, ok := known[p.ID]
+		if !ok {
+			continue // Gone from fp_posts since the last run
+		}
+		p.FPPost = post
+		posts = append(posts, p)
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"window":      window,
+		"posts":       posts,
+		"count":       len(posts),
+		"computed_at": computedAt,
+	})
+}
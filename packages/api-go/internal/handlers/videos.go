@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/services"
+)
+
+// normalizeVideoIDs resolves client supplied video IDs and URLs to post IDs,
+// writing a 400 for the first one the validation mode rejects. A post given
+// more than once, say as a URL and as an ID, is kept at its first position.
+func normalizeVideoIDs(w http.ResponseWriter, ids []string) ([]string, bool) {
+	normalized, ok := normalizeEachVideoID(w, ids)
+	if !ok {
+		return nil, false
+	}
+	deduped := normalized[:0]
+	seen := make(map[string]bool, len(normalized))
+	for _, id := range normalized {
+		if !seen[id] {
+			seen[id] = true
+			deduped = append(deduped, id)
+		}
+	}
+	return deduped, true
+}
+
+// normalizeEachVideoID is like normalizeVideoIDs but keeps repeats, for
+// lists such as the queue where a video may appear more than once.
+func normalizeEachVideoID(w http.ResponseWriter, ids []string) ([]string, bool) {
+	normalized := make([]string, len(ids))
+	for i, raw := range ids {
+		id, ok := services.NormalizeVideoID(raw)
+		if !ok {
+			respondError(w, http.StatusBadRequest, "Bad Request", "Invalid video_id: "+raw)
+			return nil, false
+		}
+		normalized[i] = id
+	}
+	return normalized, true
+}
+
+// unknownVideoIDs returns the videos in ids that don't seem to exist, so the
+// response can flag them. Lookup failures are logged and flag nothing.
+func unknownVideoIDs(ctx context.Context, ids []string) []string {
+	unknown, err := services.UnknownVideoIDs(ctx, ids)
+	if err != nil {
+		log.Printf("Failed to check video IDs: %v", err)
+		return nil
+	}
+	return unknown
+}
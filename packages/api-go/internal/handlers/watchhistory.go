@@ -0,0 +1,362 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/database"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/middleware"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/models"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/services"
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	defaultWatchHistoryPageSize = 50
+	maxWatchHistoryPageSize     = 200
+	maxWatchHistoryRetention    = 3650
+)
+
+// WatchHistorySettings are a user's watch history privacy controls.
+type WatchHistorySettings struct {
+	Paused        bool       `json:"paused"`
+	RetentionDays *int       `json:"retention_days"` // nil keeps history forever
+	UpdatedAt     *time.Time `json:"updated_at,omitempty"`
+}
+
+// watchHistoryCursor is the position after the last entry of a page.
+type watchHistoryCursor struct {
+	WatchedAt time.Time `json:"t"`
+	ID        int64     `json:"id"`
+}
+
+func encodeWatchHistoryCursor(c watchHistoryCursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeWatchHistoryCursor(s string) (watchHistoryCursor, error) {
+	var c watchHistoryCursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+	err = json.Unmarshal(b, &c)
+	return c, err
+}
+
+// parseHistoryTime accepts an RFC 3339 timestamp or a YYYY-MM-DD date (UTC).
+func parseHistoryTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, s)
+}
+
+// RecordWatchHistory adds an entry to the user's watch history. Nothing is
+// recorded while history is paused.
+func RecordWatchHistory(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+
+	var req struct {
+		VideoID        string     `json:"video_id"`
+		WatchedAt      *time.Time `json:"watched_at"`
+		WatchedSeconds float64    `json:"watched_seconds"`
+		Device         string     `json:"device"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Invalid request body")
+		return
+	}
+	videoID, ok := services.NormalizeVideoID(req.VideoID)
+	if !ok {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Invalid video_id")
+		return
+	}
+	if req.WatchedSeconds < 0 {
+		respondError(w, http.StatusBadRequest, "Bad Request", "watched_seconds must be zero or more")
+		return
+	}
+	if len(req.Device) > maxDeviceLength {
+		respondError(w, http.StatusBadRequest, "Bad Request", "device is too long")
+		return
+	}
+	now := time.Now()
+	watchedAt := now
+	if req.WatchedAt != nil && req.WatchedAt.Before(now.Add(maxClockSkew)) {
+		watchedAt = *req.WatchedAt
+	}
+	device := req.Device
+	if device == "" {
+		if session, ok := r.Context().Value(middleware.SessionContextKey).(*models.DeviceSession); ok {
+			device = session.DeviceInfo
+		}
+	}
+
+	db := database.Conn(r.Context())
+	var paused bool
+	err := db.QueryRow(r.Context(), `
+		SELECT paused FROM watch_history_settings WHERE floatplane_user_id = $1
+	`, user.FloatplaneUserID).Scan(&paused)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to check history settings")
+		return
+	}
+	if paused {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	entry := models.WatchHistoryEntry{VideoID: videoID, WatchedAt: watchedAt, WatchedSeconds: req.WatchedSeconds, Device: device}
+	err = db.QueryRow(r.Context(), `
+		INSERT INTO watch_history (floatplane_user_id, video_id, watched_at, watched_seconds, device)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''))
+		RETURNING id
+	`, user.FloatplaneUserID, videoID, watchedAt, req.WatchedSeconds, device).Scan(&entry.ID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to record history")
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, entry)
+}
+
+// GetWatchHistory lists the user's watch history, most recent first, with the
+// post for each entry where known.
+//
+// Query parameters:
+//   - limit: page size (default 50, max 200)
+//   - cursor: next_cursor from the previous page
+func GetWatchHistory(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+
+	limit := defaultWatchHistoryPageSize
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxWatchHistoryPageSize {
+			respondError(w, http.StatusBadRequest, "Bad Request", fmt.Sprintf("limit must be between 1 and %d", maxWatchHistoryPageSize))
+			return
+		}
+		limit = n
+	}
+	var cursor *watchHistoryCursor
+	if v := r.URL.Query().Get("cursor"); v != "" {
+		c, err := decodeWatchHistoryCursor(v)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Bad Request", "Invalid cursor")
+			return
+		}
+		cursor = &c
+	}
+
+	args := []any{user.FloatplaneUserID, limit + 1}
+	cond := ""
+	if cursor != nil {
+		cond = "AND (watched_at, id) < ($3, $4)"
+		args = append(args, cursor.WatchedAt, cursor.ID)
+	}
+	rows, err := database.Conn(r.Context()).Query(r.Context(), `
+		SELECT id, video_id, watched_at, watched_seconds, COALESCE(device, '')
+		FROM watch_history
+		WHERE floatplane_user_id = $1 `+cond+`
+		ORDER BY watched_at DESC, id DESC
+		LIMIT $2
+	`, args...)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to fetch history")
+		return
+	}
+	defer rows.Close()
+
+	entries := []models.WatchHistoryEntry{}
+	for rows.Next() {
+		var e models.WatchHistoryEntry
+		if err := rows.Scan(&e.ID, &e.VideoID, &e.WatchedAt, &e.WatchedSeconds, &e.Device); err != nil {
+			respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to scan history")
+			return
+		}
+		entries = append(entries, e)
+	}
+	rows.Close()
+
+	nextCursor := ""
+	if len(entries) > limit {
+		entries = entries[:limit]
+		last := entries[limit-1]
+		nextCursor = encodeWatchHistoryCursor(watchHistoryCursor{WatchedAt: last.WatchedAt, ID: last.ID})
+	}
+
+	ids := make([]string, len(entries))
+	for i, e := range entries {
+		ids[i] = e.VideoID
+	}
+	posts, err := fetchPostsByIDs(r.Context(), ids)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to fetch posts")
+		return
+	}
+	for i := range entries {
+		if post, ok := posts[entries[i].VideoID]; ok {
+			entries[i].Post = &post
+		}
+	}
+
+	resp := map[string]interface{}{
+		"entries": entries,
+		"count":   len(entries),
+	}
+	if nextCursor != "" {
+		resp["next_cursor"] = nextCursor
+	}
+	respondJSON(w, http.StatusOK, resp)
+}
+
+// DeleteWatchHistoryEntry deletes a single history entry.
+func DeleteWatchHistoryEntry(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Not Found", "History entry not found")
+		return
+	}
+
+	tag, err := database.Conn(r.Context()).Exec(r.Context(), `
+		DELETE FROM watch_history WHERE id = $1 AND floatplane_user_id = $2
+	`, id, user.FloatplaneUserID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to delete history entry")
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		respondError(w, http.StatusNotFound, "Not Found", "History entry not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteWatchHistory deletes the entries watched in [from, to). Either bound
+// may be left out, but clearing everything needs ?all=true.
+func DeleteWatchHistory(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+
+	query := r.URL.Query()
+	var from, to *time.Time
+	for _, bound := range []struct {
+		name string
+		dest **time.Time
+	}{{"from", &from}, {"to", &to}} {
+		if v := query.Get(bound.name); v != "" {
+			t, err := parseHistoryTime(v)
+			if err != nil {
+				respondError(w, http.StatusBadRequest, "Bad Request", "Invalid "+bound.name)
+				return
+			}
+			*bound.dest = &t
+		}
+	}
+	if from == nil && to == nil && query.Get("all") != "true" {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Pass from, to or all=true")
+		return
+	}
+
+	tag, err := database.Conn(r.Context()).Exec(r.Context(), `
+		DELETE FROM watch_history
+		WHERE floatplane_user_id = $1
+		  AND ($2::timestamptz IS NULL OR watched_at >= $2)
+		  AND ($3::timestamptz IS NULL OR watched_at < $3)
+	`, user.FloatplaneUserID, from, to)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to delete history")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{"deleted": tag.RowsAffected()})
+}
+
+// GetWatchHistorySettings returns the user's history privacy controls.
+func GetWatchHistorySettings(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+
+	var s WatchHistorySettings
+	err := database.Conn(r.Context()).QueryRow(r.Context(), `
+		SELECT paused, retention_days, updated_at FROM watch_history_settings WHERE floatplane_user_id = $1
+	`, user.FloatplaneUserID).Scan(&s.Paused, &s.RetentionDays, &s.UpdatedAt)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to fetch history settings")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, s)
+}
+
+// UpdateWatchHistorySettings replaces the user's history privacy controls.
+// Lowering the retention deletes older entries straight away.
+func UpdateWatchHistorySettings(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "User not found")
+		return
+	}
+
+	var req WatchHistorySettings
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Invalid request body")
+		return
+	}
+	if req.RetentionDays != nil && (*req.RetentionDays < 1 || *req.RetentionDays > maxWatchHistoryRetention) {
+		respondError(w, http.StatusBadRequest, "Bad Request", fmt.Sprintf("retention_days must be between 1 and %d", maxWatchHistoryRetention))
+		return
+	}
+
+	db := database.Conn(r.Context())
+	var s WatchHistorySettings
+	err := db.QueryRow(r.Context(), `
+		INSERT INTO watch_history_settings (floatplane_user_id, paused, retention_days, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (floatplane_user_id) DO UPDATE
+		SET paused = EXCLUDED.paused, retention_days = EXCLUDED.retention_days, updated_at = EXCLUDED.updated_at
+		RETURNING paused, retention_days, updated_at
+	`, user.FloatplaneUserID, req.Paused, req.RetentionDays, time.Now()).Scan(&s.Paused, &s.RetentionDays, &s.UpdatedAt)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to save history settings")
+		return
+	}
+	if s.RetentionDays != nil {
+		_, err = db.Exec(r.Context(), `
+			DELETE FROM watch_history WHERE floatplane_user_id = $1 AND watched_at < $2
+		`, user.FloatplaneUserID, time.Now().AddDate(0, 0, -*s.RetentionDays))
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to apply retention")
+			return
+		}
+	}
+
+	respondJSON(w, http.StatusOK, s)
+}
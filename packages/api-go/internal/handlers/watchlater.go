@@ -1,15 +1,31 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"slices"
 	"time"
 
 	"github.com/coulterpeterson/floatnative/packages/api-go/internal/database"
 	"github.com/coulterpeterson/floatnative/packages/api-go/internal/middleware"
 	"github.com/coulterpeterson/floatnative/packages/api-go/internal/models"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/services"
+	"github.com/jackc/pgx/v5"
 )
 
+// ensureWatchLater creates the user's Watch Later playlist if they don't have
+// one yet. The unique index on Watch Later makes concurrent calls safe.
+func ensureWatchLater(ctx context.Context, userID string) error {
+	_, err := database.Conn(ctx).Exec(ctx, `
+		INSERT INTO playlists (floatplane_user_id, name, is_watch_later, video_ids, created_at, updated_at)
+		VALUES ($1, $2, true, '{}', $3, $3)
+		ON CONFLICT (floatplane_user_id) WHERE is_watch_later DO NOTHING
+	`, userID, WatchLaterName, time.Now())
+	return err
+}
+
 // GetWatchLater gets or creates the Watch Later playlist
 func GetWatchLater(w http.ResponseWriter, r *http.Request) {
 	user, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
@@ -19,27 +35,22 @@ func GetWatchLater(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var p models.Playlist
-	err := database.Pool.QueryRow(r.Context(), `
-		SELECT id, floatplane_user_id, name, is_watch_later, video_ids, created_at, updated_at
-		FROM playlists WHERE floatplane_user_id = $1 AND is_watch_later = true
-	`, user.FloatplaneUserID).Scan(&p.ID, &p.FloatplaneUserID, &p.Name, &p.IsWatchLater, &p.VideoIDs, &p.CreatedAt, &p.UpdatedAt)
-
-	if err != nil {
-		// Not found? Create it
-		// If DB error is actually "no rows", we create.
-		// For simplicity assuming no rows. In prod check err type.
-		err = database.Pool.QueryRow(r.Context(), `
-			INSERT INTO playlists (floatplane_user_id, name, is_watch_later, video_ids, created_at, updated_at)
-			VALUES ($1, 'Watch Later', true, '{}', $2, $2)
-			RETURNING id, floatplane_user_id, name, is_watch_later, video_ids, created_at, updated_at
-		`, user.FloatplaneUserID, time.Now()).Scan(
-			&p.ID, &p.FloatplaneUserID, &p.Name, &p.IsWatchLater, &p.VideoIDs, &p.CreatedAt, &p.UpdatedAt,
-		)
-		if err != nil {
-			respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to create Watch Later playlist")
-			return
+	fetch := func() error {
+		return database.Conn(r.Context()).QueryRow(r.Context(), `
+			SELECT id, floatplane_user_id, name, is_watch_later, video_ids, created_at, updated_at
+			FROM playlists WHERE floatplane_user_id = $1 AND is_watch_later = true
+		`, user.FloatplaneUserID).Scan(&p.ID, &p.FloatplaneUserID, &p.Name, &p.IsWatchLater, &p.VideoIDs, &p.CreatedAt, &p.UpdatedAt)
+	}
+	err := fetch()
+	if errors.Is(err, pgx.ErrNoRows) {
+		if err = ensureWatchLater(r.Context(), user.FloatplaneUserID); err == nil {
+			err = fetch()
 		}
 	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to fetch Watch Later playlist")
+		return
+	}
 
 	// Response format for GetWatchLater is simplified per README:
 	// { "id": "uuid", "video_ids": ["string"], "updated_at": "ISO 8601" }
@@ -66,43 +77,55 @@ func UpdateWatchLater(w http.ResponseWriter, r *http.Request) {
 		respondError(w, http.StatusBadRequest, "Bad Request", "Missing video_ids")
 		return
 	}
+	if !checkItemQuota(w, len(req.VideoIDs)) {
+		return
+	}
+	if req.VideoIDs, ok = normalizeVideoIDs(w, req.VideoIDs); !ok {
+		return
+	}
 
-	// Ensure exists first (lazy create if not exists? README says "automatically create... except REMOVE")
-	// UpdateWatchLater is PUT /watch-later -> Replace. So we should create if not exists.
-	// We can use UPSERT logic or just ensure it exists.
-	// Let's reuse GetOrCreate logic inline or helper.
-	
-	// Just do an UPDATE. If 0 rows affected, INSERT.
-	// Postgres ON CONFLICT requires a constraint. We have unique index on ID, but not (user, is_watch_later).
-	// But app logic enforces one per user.
-	// Let's try update.
-	var p models.Playlist
-	err := database.Pool.QueryRow(r.Context(), `
-		UPDATE playlists 
-		SET video_ids = $1, updated_at = $2
-		WHERE floatplane_user_id = $3 AND is_watch_later = true
-		RETURNING id, video_ids, updated_at
-	`, req.VideoIDs, time.Now(), user.FloatplaneUserID).Scan(&p.ID, &p.VideoIDs, &p.UpdatedAt)
+	ctx := r.Context()
+	tx, err := database.Begin(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to start transaction")
+		return
+	}
+	defer tx.Rollback(ctx)
+	r = r.WithContext(database.WithTx(ctx, tx))
+	if err := services.LockPlaylistWrites(r.Context(), user.FloatplaneUserID); err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to update Watch Later")
+		return
+	}
 
+	// Replace, creating Watch Later if the user doesn't have one yet
+	var p models.Playlist
+	err = database.Conn(r.Context()).QueryRow(r.Context(), `
+		INSERT INTO playlists (floatplane_user_id, name, is_watch_later, video_ids, created_at, updated_at)
+		VALUES ($1, $2, true, $3, $4, $4)
+		ON CONFLICT (floatplane_user_id) WHERE is_watch_later
+		DO UPDATE SET video_ids = EXCLUDED.video_ids, updated_at = EXCLUDED.updated_at
+		RETURNING id, name, video_ids, updated_at
+	`, user.FloatplaneUserID, WatchLaterName, req.VideoIDs, time.Now()).Scan(&p.ID, &p.Name, &p.VideoIDs, &p.UpdatedAt)
 	if err != nil {
-		// Assume no rows -> Insert
-		err = database.Pool.QueryRow(r.Context(), `
-			INSERT INTO playlists (floatplane_user_id, name, is_watch_later, video_ids, created_at, updated_at)
-			VALUES ($1, 'Watch Later', true, $2, $3, $3)
-			RETURNING id, video_ids, updated_at
-		`, user.FloatplaneUserID, req.VideoIDs, time.Now()).Scan(&p.ID, &p.VideoIDs, &p.UpdatedAt)
-		
-		if err != nil {
-			respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to update Watch Later")
-			return
-		}
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to update Watch Later")
+		return
+	}
+	syncPlaylistItems(r.Context(), p.ID, user.FloatplaneUserID, p.VideoIDs)
+	recordRevision(r.Context(), &p, RevisionReplace)
+	if err := tx.Commit(r.Context()); err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to update Watch Later")
+		return
 	}
 
-	respondJSON(w, http.StatusOK, map[string]interface{}{
+	resp := map[string]interface{}{
 		"id":         p.ID,
 		"video_ids":  p.VideoIDs,
 		"updated_at": p.UpdatedAt,
-	})
+	}
+	if unknown := unknownVideoIDs(ctx, p.VideoIDs); len(unknown) > 0 {
+		resp["unknown_video_ids"] = unknown
+	}
+	respondJSON(w, http.StatusOK, resp)
 }
 
 func AddVideoToWatchLater(w http.ResponseWriter, r *http.Request) {
@@ -127,31 +150,57 @@ func modifyWatchLater(w http.ResponseWriter, r *http.Request, action string) {
 		respondError(w, http.StatusBadRequest, "Bad Request", "Invalid video_id")
 		return
 	}
+	// Removal matches however the video was stored, so entries saved before
+	// validation, or under a looser mode, can still be removed
+	var removeIDs []string
+	if action == "remove" {
+		removeIDs = services.StoredVideoIDs(req.VideoID)
+	} else if req.VideoID, ok = services.NormalizeVideoID(req.VideoID); !ok {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Invalid video_id")
+		return
+	}
+
+	// Lock the list so concurrent adds and removes don't overwrite each
+	// other's video_ids
+	ctx := r.Context()
+	tx, err := database.Begin(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to start transaction")
+		return
+	}
+	defer tx.Rollback(ctx)
+	r = r.WithContext(database.WithTx(ctx, tx))
+	if err := services.LockPlaylistWrites(r.Context(), user.FloatplaneUserID); err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to update Watch Later")
+		return
+	}
 
 	// Get current list
 	var p models.Playlist
-	err := database.Pool.QueryRow(r.Context(), `
-		SELECT id, video_ids FROM playlists WHERE floatplane_user_id = $1 AND is_watch_later = true
-	`, user.FloatplaneUserID).Scan(&p.ID, &p.VideoIDs)
-
-	if err != nil {
+	fetch := func() error {
+		return database.Conn(r.Context()).QueryRow(r.Context(), `
+			SELECT id, video_ids FROM playlists WHERE floatplane_user_id = $1 AND is_watch_later = true FOR UPDATE
+		`, user.FloatplaneUserID).Scan(&p.ID, &p.VideoIDs)
+	}
+	err = fetch()
+	if errors.Is(err, pgx.ErrNoRows) {
 		if action == "remove" {
 			respondError(w, http.StatusNotFound, "Not Found", "Watch Later playlist doesn't exist yet")
 			return
 		}
-		// logic for Add: Create empty if not exists
-		err = database.Pool.QueryRow(r.Context(), `
-			INSERT INTO playlists (floatplane_user_id, name, is_watch_later, video_ids, created_at, updated_at)
-			VALUES ($1, 'Watch Later', true, '{}', $2, $2)
-			RETURNING id, video_ids
-		`, user.FloatplaneUserID, time.Now()).Scan(&p.ID, &p.VideoIDs)
-		if err != nil {
-			respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to create Watch Later")
-			return
+		// Add creates an empty Watch Later first
+		if err = ensureWatchLater(r.Context(), user.FloatplaneUserID); err == nil {
+			err = fetch()
 		}
 	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to fetch Watch Later")
+		return
+	}
 
 	// Modify
+	before := len(p.VideoIDs)
+	added := false
 	if action == "add" {
 		exists := false
 		for _, vid := range p.VideoIDs {
@@ -160,12 +209,16 @@ func modifyWatchLater(w http.ResponseWriter, r *http.Request, action string) {
 			}
 		}
 		if !exists {
+			if !checkItemQuota(w, len(p.VideoIDs)+1) {
+				return
+			}
 			p.VideoIDs = append(p.VideoIDs, req.VideoID)
+			added = true
 		}
 	} else if action == "remove" {
 		newIDs := []string{}
 		for _, vid := range p.VideoIDs {
-			if vid != req.VideoID {
+			if !slices.Contains(removeIDs, vid) {
 				newIDs = append(newIDs, vid)
 			}
 		}
@@ -174,19 +227,36 @@ func modifyWatchLater(w http.ResponseWriter, r *http.Request, action string) {
 
 	// Update
 	var updated models.Playlist
-	err = database.Pool.QueryRow(r.Context(), `
+	err = database.Conn(r.Context()).QueryRow(r.Context(), `
 		UPDATE playlists SET video_ids=$1, updated_at=$2 WHERE id=$3
-		RETURNING id, video_ids, updated_at
-	`, p.VideoIDs, time.Now(), p.ID).Scan(&updated.ID, &updated.VideoIDs, &updated.UpdatedAt)
+		RETURNING id, name, video_ids, updated_at
+	`, p.VideoIDs, time.Now(), p.ID).Scan(&updated.ID, &updated.Name, &updated.VideoIDs, &updated.UpdatedAt)
 
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to update Watch Later")
 		return
 	}
+	syncPlaylistItems(r.Context(), updated.ID, user.FloatplaneUserID, updated.VideoIDs)
+	if added {
+		recordVideoAdd(r.Context(), user.FloatplaneUserID, req.VideoID)
+	}
+	if len(updated.VideoIDs) != before {
+		recordRevision(r.Context(), &updated, action)
+	}
 
-	respondJSON(w, http.StatusOK, map[string]interface{}{
+	resp := map[string]interface{}{
 		"id":         updated.ID,
 		"video_ids":  updated.VideoIDs,
 		"updated_at": updated.UpdatedAt,
-	})
+	}
+	if err := tx.Commit(r.Context()); err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to update Watch Later")
+		return
+	}
+	if action == "add" {
+		if unknown := unknownVideoIDs(ctx, []string{req.VideoID}); len(unknown) > 0 {
+			resp["unknown_video_ids"] = unknown
+		}
+	}
+	respondJSON(w, http.StatusOK, resp)
 }
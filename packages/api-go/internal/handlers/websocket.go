@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/models"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// wsMaxClientMessage limits what a client may send; it only needs control
+	// frames.
+	wsMaxClientMessage = 4096
+	wsWriteTimeout     = 10 * time.Second
+)
+
+// wsUpgrader accepts any origin: streams are authenticated by the
+// Authorization header, which a browser never sends on its own.
+var wsUpgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+	Error: func(w http.ResponseWriter, r *http.Request, status int, reason error) {
+		respondError(w, status, http.StatusText(status), reason.Error())
+	},
+}
+
+// wsConn is an upgraded WebSocket connection. Events and pings are written
+// by the stream alone; the read loop only answers pings, which the library
+// allows concurrently.
+type wsConn struct {
+	conn *websocket.Conn
+	once sync.Once
+}
+
+// upgradeWebSocket completes the opening handshake and takes over the
+// connection. If it fails, the error response has already been written.
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*wsConn, bool) {
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return nil, false
+	}
+	conn.SetReadLimit(wsMaxClientMessage)
+	return &wsConn{conn: conn}, true
+}
+
+func (c *wsConn) send(eventType string, e *models.UserEvent) error {
+	var msg []byte
+	if e == nil {
+		msg, _ = json.Marshal(map[string]string{"type": eventType})
+	} else {
+		msg, _ = json.Marshal(e)
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	return c.conn.WriteMessage(websocket.TextMessage, msg)
+}
+
+func (c *wsConn) ping() error {
+	return c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout))
+}
+
+// close sends a close frame, if the connection is still up, and closes it.
+func (c *wsConn) close() {
+	c.once.Do(func() {
+		msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+		c.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(wsWriteTimeout))
+		c.conn.Close()
+	})
+}
+
+// readLoop reads until the connection ends, then calls done. Pings and close
+// frames are answered by the library; messages from the client are ignored.
+func (c *wsConn) readLoop(done func()) {
+	defer done()
+	for {
+		if _, _, err := c.conn.NextReader(); err != nil {
+			return
+		}
+	}
+}
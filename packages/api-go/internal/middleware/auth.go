@@ -15,6 +15,9 @@ type contextKey string
 
 const UserContextKey contextKey = "user"
 
+// SessionContextKey holds the *models.DeviceSession the request was authenticated with.
+const SessionContextKey contextKey = "session"
+
 type ErrorResponse struct {
 	Error   string `json:"error"`
 	Message string `json:"message"`
@@ -40,12 +43,12 @@ func AuthMiddleware(next http.Handler) http.Handler {
 		
 		// 1. Find device session
 		var session models.DeviceSession
-		// Note: We don't select 'id' here because models.DeviceSession has it but scan order matters.
 		// Querying specific columns matches Scan order
 		err := database.Pool.QueryRow(ctx, `
-			SELECT floatplane_user_id, api_key, dpop_jkt, device_info, created_at, last_accessed_at 
+			SELECT id, floatplane_user_id, api_key, dpop_jkt, COALESCE(device_info, ''), created_at, last_accessed_at
 			FROM device_sessions WHERE api_key = $1
 		`, apiKey).Scan(
+			&session.ID,
 			&session.FloatplaneUserID,
 			&session.APIKey,
 			&session.DPoPJKT,
@@ -81,8 +84,9 @@ func AuthMiddleware(next http.Handler) http.Handler {
 		// We'll do it sync for simplicity
 		_, _ = database.Pool.Exec(ctx, "UPDATE device_sessions SET last_accessed_at = $1 WHERE api_key = $2", time.Now(), apiKey)
 
-		// 4. Set user in context
+		// 4. Set user and session in context
 		ctx = context.WithValue(ctx, UserContextKey, &user)
+		ctx = context.WithValue(ctx, SessionContextKey, &session)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 )
 
@@ -15,13 +16,94 @@ type User struct {
 // Playlist represents a user created playlist.
 // VideoIDs is stored as a string array (TEXT[]) in Postgres.
 type Playlist struct {
-	ID               string    `json:"id" db:"id"`
+	ID               string      `json:"id" db:"id"`
+	FloatplaneUserID string      `json:"floatplane_user_id" db:"floatplane_user_id"`
+	Name             string      `json:"name" db:"name"`
+	IsWatchLater     bool        `json:"is_watch_later" db:"is_watch_later"`
+	VideoIDs         []string    `json:"video_ids" db:"video_ids"`
+	CreatedAt        time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time   `json:"updated_at" db:"updated_at"`
+	IsSmart          bool        `json:"is_smart" db:"is_smart"`
+	SmartRules       *SmartRules `json:"smart_rules,omitempty" db:"smart_rules"`
+	ItemCount        int         `json:"item_count" db:"-"`
+	DeletedAt        *time.Time  `json:"deleted_at,omitempty" db:"deleted_at"` // Set while in the trash
+	Role             string      `json:"role,omitempty" db:"-"`                // Caller's role: viewer, editor or owner
+	SyncVersion      int64       `json:"sync_version" db:"sync_version"`       // Bumped on every change, see GET /sync
+	UnknownVideoIDs  []string    `json:"unknown_video_ids,omitempty" db:"-"`   // Written videos that couldn't be found
+
+	Description       string `json:"description,omitempty" db:"description"`
+	CoverVideoID      string `json:"cover_video_id,omitempty" db:"cover_video_id"`
+	CoverThumbnailURL string `json:"cover_thumbnail_url,omitempty" db:"-"` // From fp_posts
+	Color             string `json:"color,omitempty" db:"color"`           // #rrggbb
+	Emoji             string `json:"emoji,omitempty" db:"emoji"`
+	Pinned            bool   `json:"pinned" db:"pinned"`
+	SortOrder         int    `json:"sort_order" db:"sort_order"`
+	RemoveWhenWatched bool   `json:"remove_when_watched" db:"remove_when_watched"` // Drop videos once the owner finishes them
+}
+
+// SmartRules is the rule set of a smart playlist, stored as JSONB.
+// All set criteria must match. Durations are in seconds.
+type SmartRules struct {
+	CreatorIDs         []string   `json:"creator_ids,omitempty"`
+	ChannelIDs         []string   `json:"channel_ids,omitempty"`
+	TitleKeywords      []string   `json:"title_keywords,omitempty"`
+	MinDuration        *int       `json:"min_duration,omitempty"`
+	MaxDuration        *int       `json:"max_duration,omitempty"`
+	ReleasedWithinDays *int       `json:"released_within_days,omitempty"`
+	ReleasedAfter      *time.Time `json:"released_after,omitempty"`
+	ReleasedBefore     *time.Time `json:"released_before,omitempty"`
+	HasVideo           *bool      `json:"has_video,omitempty"`
+	HasAudio           *bool      `json:"has_audio,omitempty"`
+	Limit              int        `json:"limit,omitempty"` // Defaults to 100
+}
+
+// PlaylistMember grants a user access to a playlist they don't own.
+type PlaylistMember struct {
+	PlaylistID       string    `json:"playlist_id" db:"playlist_id"`
 	FloatplaneUserID string    `json:"floatplane_user_id" db:"floatplane_user_id"`
+	Role             string    `json:"role" db:"role"` // viewer, editor, owner
+	CreatedAt        time.Time `json:"created_at" db:"created_at"`
+}
+
+// PlaylistInvite is a code that lets another user join a playlist.
+type PlaylistInvite struct {
+	Code       string    `json:"code" db:"code"`
+	PlaylistID string    `json:"playlist_id" db:"playlist_id"`
+	Role       string    `json:"role" db:"role"` // viewer, editor
+	CreatedBy  string    `json:"created_by" db:"created_by"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at" db:"expires_at"`
+}
+
+// PlaylistItem records who added a video to a playlist and when.
+type PlaylistItem struct {
+	PlaylistID string    `json:"playlist_id" db:"playlist_id"`
+	VideoID    string    `json:"video_id" db:"video_id"`
+	AddedBy    string    `json:"added_by" db:"added_by"`
+	AddedAt    time.Time `json:"added_at" db:"added_at"`
+	Unknown    bool      `json:"unknown,omitempty" db:"-"` // Not found, see VIDEO_ID_VALIDATION
+}
+
+// PlaylistRevision is a snapshot of a playlist taken after a mutation.
+type PlaylistRevision struct {
+	ID               int64     `json:"id" db:"id"`
+	PlaylistID       string    `json:"playlist_id" db:"playlist_id"`
+	FloatplaneUserID string    `json:"floatplane_user_id" db:"floatplane_user_id"`
+	DeviceSessionID  string    `json:"device_session_id,omitempty" db:"device_session_id"`
+	DeviceInfo       string    `json:"device_info,omitempty" db:"device_info"`
+	Action           string    `json:"action" db:"action"` // create, add, remove, reorder, rename, replace, revert, restore, merge, expire
 	Name             string    `json:"name" db:"name"`
-	IsWatchLater     bool      `json:"is_watch_later" db:"is_watch_later"`
 	VideoIDs         []string  `json:"video_ids" db:"video_ids"`
 	CreatedAt        time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt        time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// PlaylistShare represents a read-only public link to a playlist.
+// A share is active until RevokedAt is set.
+type PlaylistShare struct {
+	Token      string     `json:"token" db:"token"`
+	PlaylistID string     `json:"playlist_id" db:"playlist_id"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
 }
 
 // FPPost represents a Floatplane post/video.
@@ -45,11 +127,94 @@ type FPPost struct {
 	IsFeatured      bool      `json:"is_featured" db:"is_featured"`
 	HasGallery      bool      `json:"has_gallery" db:"has_gallery"`
 	GalleryCount    int       `json:"gallery_count" db:"gallery_count"`
+	Text            string    `json:"-" db:"text"` // Description, only read for chapters
 	ReleaseDate     time.Time `json:"release_date" db:"release_date"`
 	CreatedAt       time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt       time.Time `json:"updated_at" db:"updated_at"`
 }
 
+// TrendingPost is a post many users added to Watch Later or a playlist
+// recently.
+type TrendingPost struct {
+	FPPost
+	Rank    int `json:"rank" db:"rank"`
+	SavedBy int `json:"saved_by" db:"users"` // Distinct users who added it in the window
+}
+
+// WatchProgress is a user's playback position in a video.
+type WatchProgress struct {
+	VideoID         string    `json:"video_id" db:"video_id"`
+	PositionSeconds float64   `json:"position_seconds" db:"position_seconds"`
+	Duration        *float64  `json:"duration,omitempty" db:"duration_seconds"` // Seconds
+	Device          string    `json:"device,omitempty" db:"device"`
+	ClientUpdatedAt time.Time `json:"client_updated_at" db:"client_updated_at"` // Orders writes, see PUT /progress
+	Completed       bool      `json:"completed" db:"completed"`                 // Finished by the user's completion setting
+	UpdatedAt       time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// WatchHistoryEntry records one viewing of a post.
+type WatchHistoryEntry struct {
+	ID             int64     `json:"id" db:"id"`
+	VideoID        string    `json:"video_id" db:"video_id"`
+	WatchedAt      time.Time `json:"watched_at" db:"watched_at"`
+	WatchedSeconds float64   `json:"watched_seconds" db:"watched_seconds"`
+	Device         string    `json:"device,omitempty" db:"device"`
+	Post           *FPPost   `json:"post,omitempty" db:"-"` // From fp_posts, if known
+}
+
+// Bookmark is a saved moment in a post, with an optional note.
+type Bookmark struct {
+	ID               int64     `json:"id" db:"id"`
+	VideoID          string    `json:"video_id" db:"video_id"`
+	TimestampSeconds float64   `json:"timestamp_seconds" db:"timestamp_seconds"`
+	Label            string    `json:"label" db:"label"`
+	Note             string    `json:"note" db:"note"`
+	CreatedAt        time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at" db:"updated_at"`
+	Title            string    `json:"title,omitempty" db:"-"`         // From fp_posts, if known
+	ThumbnailURL     string    `json:"thumbnail_url,omitempty" db:"-"` // From fp_posts, if known
+}
+
+// Chapter is a section of a post, parsed from the timestamps in its
+// description. EndSeconds is omitted for the last chapter if the post's
+// duration isn't known.
+type Chapter struct {
+	StartSeconds int    `json:"start_seconds"`
+	EndSeconds   int    `json:"end_seconds,omitempty"`
+	Title        string `json:"title"`
+}
+
+// Recommendation is a video suggested from what other users save alongside
+// the user's own videos.
+type Recommendation struct {
+	VideoID        string  `json:"video_id" db:"video_id"`
+	Score          float64 `json:"score" db:"score"`
+	BecauseVideoID string  `json:"because_video_id" db:"because_video_id"` // The saved video it is most like
+	Title          string  `json:"title,omitempty" db:"-"`                 // From fp_posts, if known
+	ThumbnailURL   string  `json:"thumbnail_url,omitempty" db:"-"`         // From fp_posts, if known
+	ChannelTitle   string  `json:"channel_title,omitempty" db:"-"`         // From fp_posts, if known
+	BecauseTitle   string  `json:"because_title,omitempty" db:"-"`         // From fp_posts, if known
+}
+
+// QueueItem is a video in the user's Up Next queue. The same video can be
+// queued more than once, so items are addressed by ID.
+type QueueItem struct {
+	ID      int64     `json:"id" db:"id"`
+	VideoID string    `json:"video_id" db:"video_id"`
+	AddedAt time.Time `json:"added_at" db:"added_at"`
+}
+
+// UserEvent is a change pushed to a user's other devices.
+type UserEvent struct {
+	ID               int64           `json:"id" db:"id"`
+	FloatplaneUserID string          `json:"-" db:"floatplane_user_id"`
+	Type             string          `json:"type" db:"type"`
+	Data             json.RawMessage `json:"data" db:"data"`
+	OriginSessionID  string          `json:"-" db:"origin_session_id"`
+	CreatedAt        time.Time       `json:"created_at" db:"created_at"`
+	XID              int64           `json:"-" db:"xid"` // Transaction that published it
+}
+
 // QRSession represents a QR code login session.
 type QRSession struct {
 	ID               string     `json:"id" db:"id"`
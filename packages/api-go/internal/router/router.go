@@ -38,21 +38,96 @@ func New() *chi.Mux {
 	router.Group(func(r chi.Router) {
 		r.Use(appMiddleware.AuthMiddleware)
 		r.Post("/auth/logout", handlers.Logout)
+		r.Get("/account/limits", handlers.GetAccountLimits)
 		r.Get("/playlists", handlers.GetPlaylists)
 		r.Post("/playlists", handlers.CreatePlaylist)
 		r.Put("/playlists/{id}", handlers.UpdatePlaylist)
 		r.Delete("/playlists/{id}", handlers.DeletePlaylist)
+		r.Get("/playlists/trash", handlers.GetTrash)
+		r.Post("/playlists/{id}/restore", handlers.RestorePlaylist)
+		r.Get("/playlists/{id}/history", handlers.GetPlaylistHistory)
+		r.Post("/playlists/{id}/revert", handlers.RevertPlaylist)
 		r.Patch("/playlists/{id}/add", handlers.AddVideoToPlaylist)
 		r.Patch("/playlists/{id}/remove", handlers.RemoveVideoFromPlaylist)
+		r.Post("/playlists/{id}/share", handlers.CreatePlaylistShare)
+		r.Delete("/playlists/{id}/share", handlers.RevokePlaylistShare)
+		r.Get("/playlists/{id}/items", handlers.GetPlaylistItems)
+		r.Get("/playlists/{id}/export", handlers.ExportPlaylist)
+		r.Post("/playlists/import", handlers.ImportPlaylist)
+		r.Post("/playlists/merge", handlers.MergePlaylists)
+		r.Post("/playlists/{id}/duplicate", handlers.DuplicatePlaylist)
+		r.Post("/playlists/{id}/split", handlers.SplitPlaylist)
+
+		// Delta Sync Routes
+		r.Get("/sync", handlers.GetSync)
+		r.Post("/sync", handlers.PostSync)
+		r.Post("/batch", handlers.PostBatch)
+
+		// Collaborative Playlist Routes
+		r.Post("/playlists/join", handlers.JoinPlaylist)
+		r.Get("/playlists/{id}/members", handlers.GetPlaylistMembers)
+		r.Post("/playlists/{id}/invites", handlers.CreatePlaylistInvite)
+		r.Delete("/playlists/{id}/invites/{code}", handlers.RevokePlaylistInvite)
+		r.Patch("/playlists/{id}/members/{userId}", handlers.UpdatePlaylistMember)
+		r.Delete("/playlists/{id}/members/{userId}", handlers.RemovePlaylistMember)
+		r.Post("/shared/{token}/import", handlers.ImportSharedPlaylist)
 
 		// Watch Later Routes
 		r.Get("/watch-later", handlers.GetWatchLater)
 		r.Put("/watch-later", handlers.UpdateWatchLater)
 		r.Patch("/watch-later/add", handlers.AddVideoToWatchLater)
 		r.Patch("/watch-later/remove", handlers.RemoveVideoFromWatchLater)
+		r.Get("/watch-later/expiry", handlers.GetWatchLaterExpiry)
+		r.Put("/watch-later/expiry", handlers.UpdateWatchLaterExpiry)
+		r.Get("/watch-later/expired", handlers.GetExpiredWatchLater)
+		r.Post("/watch-later/expired/restore", handlers.RestoreExpiredWatchLater)
+
+		// Settings Routes
+		r.Get("/settings", handlers.GetSettings)
+		r.Put("/settings", handlers.ReplaceSettings)
+		r.Patch("/settings", handlers.PatchSettings)
+		r.Get("/settings/schema", handlers.GetSettingsSchema)
+
+		// Bookmark Routes
+		r.Get("/bookmarks", handlers.GetBookmarks)
+		r.Post("/bookmarks", handlers.CreateBookmark)
+		r.Patch("/bookmarks/{id}", handlers.UpdateBookmark)
+		r.Delete("/bookmarks/{id}", handlers.DeleteBookmark)
+
+		// Recommendation Routes
+		r.Get("/recommendations", handlers.GetRecommendations)
+
+		// Up Next Queue Routes
+		r.Get("/queue", handlers.GetQueue)
+		r.Delete("/queue", handlers.ClearQueue)
+		r.Post("/queue/items", handlers.AddToQueue)
+		r.Patch("/queue/items/{itemId}", handlers.MoveQueueItem)
+		r.Delete("/queue/items/{itemId}", handlers.RemoveQueueItem)
+		r.Post("/queue/pop", handlers.PopQueue)
+		r.Put("/queue/current", handlers.SetQueueCurrent)
+
+		// Watch Progress Routes
+		r.Put("/progress/{videoId}", handlers.UpdateProgress)
+		r.Get("/progress", handlers.GetProgress)
+		r.Get("/continue-watching", handlers.GetContinueWatching)
+		r.Get("/completion-settings", handlers.GetCompletionSettings)
+		r.Put("/completion-settings", handlers.UpdateCompletionSettings)
+
+		// Watch History Routes
+		r.Get("/history", handlers.GetWatchHistory)
+		r.Post("/history", handlers.RecordWatchHistory)
+		r.Delete("/history", handlers.DeleteWatchHistory)
+		r.Get("/history/settings", handlers.GetWatchHistorySettings)
+		r.Put("/history/settings", handlers.UpdateWatchHistorySettings)
+		r.Delete("/history/{id}", handlers.DeleteWatchHistoryEntry)
+
+		// Event Stream Routes
+		r.Get("/events", handlers.StreamEvents)
 
 		// LTT Routes
 		r.Get("/ltt/search", handlers.SearchLTT)
+		r.Get("/posts/{id}/chapters", handlers.GetPostChapters)
+		r.Get("/trending", handlers.GetTrending)
 
 	})
 
@@ -61,5 +136,8 @@ func New() *chi.Mux {
 	router.Get("/auth/qr/poll/{id}", handlers.PollQR)
 	router.Post("/auth/login", handlers.Login)
 
+	// Shared Playlists (Public)
+	router.Get("/shared/{token}", handlers.GetSharedPlaylist)
+
 	return router
 }
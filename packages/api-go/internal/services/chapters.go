@@ -0,0 +1,114 @@
+package services
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/models"
+)
+
+// minChapters is the fewest timestamps that count as a chapter list. A lone
+// timestamp is usually a reference ("skip to 4:20"), not a chapter.
+const minChapters = 2
+
+var (
+	// htmlBreak matches tags that end a line in post text.
+	htmlBreak = regexp.MustCompile(`(?i)<br\s*/?>|</?(p|div|li|ul|ol|h[1-6])(\s[^>]*)?>`)
+	htmlTag   = regexp.MustCompile(`<[^>]*>`)
+	// chapterLine matches "0:00 Intro", "00:00:00 - Intro", "[1:23] Intro"
+	// and the like.
+	chapterLine = regexp.MustCompile(`^[\[(]?(\d{1,3}(?::\d{1,2}){1,2})[\])]?(?:\s*[-–—:|.]\s*|\s+)(.+)$`)
+	// chapterHeader matches "Timestamps:", "Chapters", "TIMESTAMPS (approx.)".
+	chapterHeader = regexp.MustCompile(`(?i)^(time\s*stamps|chapters)\b.{0,40}$`)
+)
+
+// ParseChapters returns the chapters listed in a post's description, which
+// may be HTML. If a "Timestamps:" or "Chapters:" header is present, the
+// list under it is used; otherwise the first run of timestamped lines is.
+// Entries that don't move forward in time are skipped. Each chapter ends
+// where the next starts, and the last at duration if it is known. Returns
+// nil if there is no chapter list.
+func ParseChapters(text string, duration int) []models.Chapter {
+	lines := postTextLines(text)
+	for i, line := range lines {
+		if chapterHeader.MatchString(line) {
+			lines = lines[i+1:]
+			break
+		}
+	}
+
+	var chapters []models.Chapter
+	for _, line := range lines {
+		if line == "" {
+			continue
+		}
+		start, title, ok := parseChapterLine(line)
+		if !ok {
+			if len(chapters) > 0 {
+				break // End of the list
+			}
+			continue
+		}
+		if len(chapters) > 0 && start <= chapters[len(chapters)-1].StartSeconds {
+			continue
+		}
+		chapters = append(chapters, models.Chapter{StartSeconds: start, Title: title})
+	}
+	if len(chapters) < minChapters {
+		return nil
+	}
+
+	for i := range chapters[:len(chapters)-1] {
+		chapters[i].EndSeconds = chapters[i+1].StartSeconds
+	}
+	if last := &chapters[len(chapters)-1]; duration > last.StartSeconds {
+		last.EndSeconds = duration
+	}
+	return chapters
+}
+
+// postTextLines converts post text to trimmed plain text lines.
+func postTextLines(text string) []string {
+	text = htmlBreak.ReplaceAllString(text, "\n")
+	text = html.UnescapeString(htmlTag.ReplaceAllString(text, ""))
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		// Non-breaking spaces are common in pasted timestamp lists
+		lines[i] = strings.TrimSpace(strings.ReplaceAll(line, "\u00a0", " "))
+	}
+	return lines
+}
+
+// parseChapterLine splits a line into its start time and title.
+func parseChapterLine(line string) (int, string, bool) {
+	m := chapterLine.FindStringSubmatch(line)
+	if m == nil {
+		return 0, "", false
+	}
+	start, ok := parseTimestamp(m[1])
+	title := strings.TrimSpace(strings.TrimLeft(m[2], "-–—:| "))
+	if !ok || title == "" {
+		return 0, "", false
+	}
+	return start, title, true
+}
+
+// parseTimestamp converts m:ss or h:mm:ss to seconds. Seconds, and minutes
+// when there are hours, must be two digits and below 60.
+func parseTimestamp(s string) (int, bool) {
+	parts := strings.Split(s, ":")
+	seconds := 0
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return 0, false
+		}
+		if i > 0 && (len(part) != 2 || n >= 60) {
+			return 0, false
+		}
+		seconds = seconds*60 + n
+	}
+	return seconds, true
+}
@@ -0,0 +1,379 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/database"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Event types pushed to a user's devices
+const (
+	EventPlaylistChanged   = "playlist.changed"
+	EventPlaylistDeleted   = "playlist.deleted"
+	EventWatchLaterChanged = "watch_later.changed"
+	EventProgressUpdated   = "progress.updated"
+	EventSessionRevoked    = "session.revoked"
+)
+
+const (
+	// EventRetention is how long events can be replayed after a reconnect.
+	EventRetention = 24 * time.Hour
+	// maxEventReplay caps a replay; further behind, the device must resync.
+	maxEventReplay = 1000
+	// eventBuffer is how many events a slow connection may fall behind before
+	// it is dropped. It can reconnect with Last-Event-ID.
+	eventBuffer = 64
+	// eventHoldInterval is how often held back events are retried, see poll.
+	eventHoldInterval = 200 * time.Millisecond
+
+	eventsChannel = "user_events"
+)
+
+// PublishEvent stores an event for the user's devices and notifies every API
+// instance. Inside a transaction the notification is sent on commit, so
+// devices never hear about a change that was rolled back.
+func PublishEvent(ctx context.Context, userID, originSessionID, eventType string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = database.Conn(ctx).Exec(ctx, `
+		WITH e AS (
+			INSERT INTO user_events (floatplane_user_id, type, data, origin_session_id, created_at)
+			VALUES ($1, $2, $3, NULLIF($4, ''), $5)
+			RETURNING id, floatplane_user_id
+		)
+		SELECT pg_notify($6, json_build_object('id', id, 'user', floatplane_user_id)::text) FROM e
+	`, userID, eventType, payload, originSessionID, time.Now(), eventsChannel)
+	return err
+}
+
+// EventCursor is a position in the event stream. Event IDs are handed out
+// when an event is inserted rather than when it commits, so events are
+// ordered by the transaction that published them, then by ID, and are only
+// handed out once every transaction that could still commit an earlier one
+// has ended. A device that has seen an event has then seen all before it.
+type EventCursor struct {
+	XID int64
+	ID  int64
+}
+
+// CursorOf returns the position of e.
+func CursorOf(e models.UserEvent) EventCursor {
+	return EventCursor{XID: e.XID, ID: e.ID}
+}
+
+// Before reports whether c comes before d.
+func (c EventCursor) Before(d EventCursor) bool {
+	return c.XID < d.XID || (c.XID == d.XID && c.ID < d.ID)
+}
+
+// EventCursorAt returns the position of the user's event with the given ID.
+// ok is false if there is no such event, e.g. because it was purged.
+func EventCursorAt(ctx context.Context, userID string, id int64) (cursor EventCursor, ok bool, err error) {
+	err = database.Conn(ctx).QueryRow(ctx, `
+		SELECT xid::text::bigint FROM user_events WHERE id = $1 AND floatplane_user_id = $2
+	`, id, userID).Scan(&cursor.XID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return cursor, false, nil
+	}
+	cursor.ID = id
+	return cursor, err == nil, err
+}
+
+// settledXID returns the oldest transaction still running. Every event
+// published by an older transaction has been committed or rolled back, and
+// any published later will come after it.
+func settledXID(ctx context.Context) (int64, error) {
+	var xid int64
+	err := database.Conn(ctx).QueryRow(ctx, `SELECT pg_snapshot_xmin(pg_current_snapshot())::text::bigint`).Scan(&xid)
+	return xid, err
+}
+
+// EventsSince returns the user's events after the cursor, oldest first. ok
+// is false if some can't be replayed because there are too many, in which
+// case the device should refetch everything.
+func EventsSince(ctx context.Context, userID string, after EventCursor) (events []models.UserEvent, ok bool, err error) {
+	settled, err := settledXID(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	rows, err := database.Conn(ctx).Query(ctx, `
+		SELECT `+eventColumns+` FROM user_events
+		WHERE floatplane_user_id = $1
+		  AND (xid, id) > ($2::bigint::text::xid8, $3)
+		  AND xid < $4::bigint::text::xid8
+		ORDER BY xid, id
+		LIMIT $5
+	`, userID, after.XID, after.ID, settled, maxEventReplay+1)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var e models.UserEvent
+		if err := scanEvent(rows, &e); err != nil {
+			return nil, false, err
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+	if len(events) > maxEventReplay {
+		return nil, false, nil
+	}
+	return events, true, nil
+}
+
+// PurgeEvents deletes events that are too old to replay.
+func PurgeEvents() error {
+	_, err := database.Pool.Exec(context.Background(), `
+		DELETE FROM user_events WHERE created_at < $1
+	`, time.Now().Add(-EventRetention))
+	return err
+}
+
+const eventColumns = `id, floatplane_user_id, type, data, COALESCE(origin_session_id, ''), created_at, xid::text::bigint`
+
+type eventScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanEvent(row eventScanner, e *models.UserEvent) error {
+	return row.Scan(&e.ID, &e.FloatplaneUserID, &e.Type, &e.Data, &e.OriginSessionID, &e.CreatedAt, &e.XID)
+}
+
+// EventSubscription receives a user's events for one connected device.
+type EventSubscription struct {
+	C         <-chan models.UserEvent
+	Done      <-chan struct{} // Closed when the hub drops the subscription
+	ch        chan models.UserEvent
+	done      chan struct{}
+	once      sync.Once
+	userID    string
+	sessionID string
+}
+
+// Wants reports whether the event should go to this device. Devices don't
+// get their own changes back, except being told their session was revoked.
+func (s *EventSubscription) Wants(e models.UserEvent) bool {
+	return s.sessionID == "" || e.OriginSessionID != s.sessionID || s.revokes(e)
+}
+
+// revokes reports whether e revokes this subscription's session.
+func (s *EventSubscription) revokes(e models.UserEvent) bool {
+	if e.Type != EventSessionRevoked || s.sessionID == "" {
+		return false
+	}
+	var data struct {
+		SessionID string `json:"session_id"`
+	}
+	return json.Unmarshal(e.Data, &data) == nil && data.SessionID == s.sessionID
+}
+
+func (s *EventSubscription) close() {
+	s.once.Do(func() { close(s.done) })
+}
+
+// EventHub fans events out to the devices connected to this instance.
+type EventHub struct {
+	mu        sync.Mutex
+	subs      map[string]map[*EventSubscription]struct{}
+	last      EventCursor // Everything before it has been delivered
+	listening atomic.Bool
+}
+
+// Events is the hub for this API instance.
+var Events = &EventHub{subs: make(map[string]map[*EventSubscription]struct{})}
+
+// Subscribe starts receiving the user's events for a device. Call
+// Unsubscribe when the connection ends.
+func (h *EventHub) Subscribe(userID, sessionID string) *EventSubscription {
+	s := &EventSubscription{
+		ch:        make(chan models.UserEvent, eventBuffer),
+		done:      make(chan struct{}),
+		userID:    userID,
+		sessionID: sessionID,
+	}
+	s.C, s.Done = s.ch, s.done
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subs[userID] == nil {
+		h.subs[userID] = make(map[*EventSubscription]struct{})
+	}
+	h.subs[userID][s] = struct{}{}
+	return s
+}
+
+// Unsubscribe stops delivering events to s.
+func (h *EventHub) Unsubscribe(s *EventSubscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.subs[s.userID], s)
+	if len(h.subs[s.userID]) == 0 {
+		delete(h.subs, s.userID)
+	}
+	s.close()
+}
+
+// Listening reports whether the hub is currently receiving notifications.
+func (h *EventHub) Listening() bool {
+	return h.listening.Load()
+}
+
+func (h *EventHub) subscribed(userID string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs[userID]) > 0
+}
+
+// deliver hands e to the user's subscriptions without blocking. A
+// subscription that is full, or whose session e revokes, is dropped.
+func (h *EventHub) deliver(e models.UserEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.subs[e.FloatplaneUserID] {
+		if !s.Wants(e) {
+			continue
+		}
+		select {
+		case s.ch <- e:
+		default:
+			s.close()
+		}
+		if s.revokes(e) {
+			s.close()
+		}
+	}
+}
+
+// ListenEvents receives events from every API instance and delivers them to
+// this instance's subscriptions until ctx is done, reconnecting on failure.
+func ListenEvents(ctx context.Context) {
+	for {
+		err := Events.listen(ctx)
+		Events.listening.Store(false)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Event listener stopped, reconnecting: %v", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+func (h *EventHub) listen(ctx context.Context) error {
+	pooled, err := database.Pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// LISTEN ties up the connection, so take it out of the pool
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+eventsChannel); err != nil {
+		return err
+	}
+	h.listening.Store(true)
+
+	// Polling straight away catches up on events published while the
+	// listener was reconnecting.
+	poll := true
+	var held bool
+	for {
+		if poll {
+			if held, err = h.poll(ctx); err != nil {
+				log.Printf("Failed to deliver events: %v", err)
+			}
+		}
+		// Retry held back events until they can go out
+		waitCtx, cancel := ctx, context.CancelFunc(func() {})
+		if held {
+			waitCtx, cancel = context.WithTimeout(ctx, eventHoldInterval)
+		}
+		n, err := conn.WaitForNotification(waitCtx)
+		cancel()
+		if err != nil {
+			if held && ctx.Err() == nil && pgconn.Timeout(err) {
+				poll = true
+				continue
+			}
+			return err
+		}
+		var note struct {
+			User string `json:"user"`
+		}
+		poll = held || (json.Unmarshal([]byte(n.Payload), &note) == nil && h.subscribed(note.User))
+	}
+}
+
+// poll delivers the events published since the last poll to this instance's
+// subscriptions, in order. Events from a transaction that is still running,
+// or newer than one that is, are held back until it ends, as it could still
+// commit an earlier event; held reports whether any were.
+func (h *EventHub) poll(ctx context.Context) (held bool, err error) {
+	settled, err := settledXID(ctx)
+	if err != nil {
+		return false, err
+	}
+	h.mu.Lock()
+	last := h.last
+	userIDs := make([]string, 0, len(h.subs))
+	for userID := range h.subs {
+		userIDs = append(userIDs, userID)
+	}
+	h.mu.Unlock()
+
+	next := EventCursor{XID: settled}
+	if last.XID != 0 && len(userIDs) > 0 {
+		rows, err := database.Pool.Query(ctx, `
+			SELECT `+eventColumns+` FROM user_events
+			WHERE (xid, id) > ($1::bigint::text::xid8, $2) AND floatplane_user_id = ANY($3)
+			ORDER BY xid, id
+		`, last.XID, last.ID, userIDs)
+		if err != nil {
+			return false, err
+		}
+		var events []models.UserEvent
+		for rows.Next() {
+			var e models.UserEvent
+			if err := scanEvent(rows, &e); err != nil {
+				rows.Close()
+				return false, err
+			}
+			if e.XID >= settled {
+				held = true
+				break
+			}
+			events = append(events, e)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return false, err
+		}
+		for _, e := range events {
+			h.deliver(e)
+		}
+	}
+
+	h.mu.Lock()
+	if h.last.Before(next) {
+		h.last = next
+	}
+	h.mu.Unlock()
+	return held, nil
+}
@@ -56,6 +56,7 @@ func UpdateLTTPosts() error {
 			IsFeatured:     post.Metadata.IsFeatured,
 			HasGallery:     post.Metadata.HasGallery,
 			GalleryCount:   post.Metadata.GalleryCount,
+			Text:           post.Text,
 			ReleaseDate:    releaseDate,
 			UpdatedAt:      time.Now(),
 		}
@@ -65,18 +66,20 @@ func UpdateLTTPosts() error {
 			INSERT INTO fp_posts (
 				id, title, creator_id, creator_name, channel_id, channel_title, channel_icon_url, thumbnail_url,
 				has_video, video_count, video_duration, has_audio, audio_count, audio_duration,
-				has_picture, picture_count, is_featured, has_gallery, gallery_count, release_date, updated_at
+				has_picture, picture_count, is_featured, has_gallery, gallery_count, release_date, updated_at, text
 			) VALUES (
-				$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21
+				$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22
 			)
 			ON CONFLICT (id) DO UPDATE SET
 				title = EXCLUDED.title,
 				thumbnail_url = EXCLUDED.thumbnail_url,
 				video_count = EXCLUDED.video_count,
+				video_duration = EXCLUDED.video_duration,
+				text = EXCLUDED.text,
 				updated_at = EXCLUDED.updated_at
 		`, fpPost.ID, fpPost.Title, fpPost.CreatorID, fpPost.CreatorName, fpPost.ChannelID, fpPost.ChannelTitle, fpPost.ChannelIconURL, fpPost.ThumbnailURL,
 			fpPost.HasVideo, fpPost.VideoCount, fpPost.VideoDuration, fpPost.HasAudio, fpPost.AudioCount, fpPost.AudioDuration,
-			fpPost.HasPicture, fpPost.PictureCount, fpPost.IsFeatured, fpPost.HasGallery, fpPost.GalleryCount, fpPost.ReleaseDate, fpPost.UpdatedAt,
+			fpPost.HasPicture, fpPost.PictureCount, fpPost.IsFeatured, fpPost.HasGallery, fpPost.GalleryCount, fpPost.ReleaseDate, fpPost.UpdatedAt, fpPost.Text,
 		)
 
 		if err != nil {
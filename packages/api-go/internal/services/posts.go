@@ -0,0 +1,40 @@
+package services
+
+import (
+	"regexp"
+	"strings"
+)
+
+// PostURLBase is the public web URL prefix for a Floatplane post.
+const PostURLBase = "https://www.floatplane.com/post/"
+
+// Floatplane post IDs are always 10 alphanumeric characters.
+var (
+	postURLPattern = regexp.MustCompile(`^(?:https?://)?(?:www\.|beta\.)?floatplane\.com/post/([A-Za-z0-9]{10})(?:[/?#].*)?$`)
+	postIDPattern  = regexp.MustCompile(`^[A-Za-z0-9]{10}$`)
+)
+
+// PostURL returns the floatplane.com URL for a post ID.
+func PostURL(id string) string {
+	return PostURLBase + id
+}
+
+// ExtractPostID returns the post ID from a floatplane.com post URL or a bare ID.
+func ExtractPostID(s string) (string, bool) {
+	s = strings.TrimSpace(s)
+	if m := postURLPattern.FindStringSubmatch(s); m != nil {
+		return m[1], true
+	}
+	if postIDPattern.MatchString(s) {
+		return s, true
+	}
+	return "", false
+}
+
+// ExtractPostIDFromURL is like ExtractPostID but only accepts URLs.
+func ExtractPostIDFromURL(s string) (string, bool) {
+	if m := postURLPattern.FindStringSubmatch(strings.TrimSpace(s)); m != nil {
+		return m[1], true
+	}
+	return "", false
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"sync/atomic"
+)
+
+// Limits are the per-user quotas enforced on playlist and bookmark writes.
+type Limits struct {
+	MaxPlaylists         int `json:"max_playlists"`          // Owned playlists, not counting Watch Later or the trash
+	MaxItemsPerPlaylist  int `json:"max_items_per_playlist"` // Including Watch Later
+	MaxNameLength        int `json:"max_name_length"`        // Characters
+	MaxDescriptionLength int `json:"max_description_length"` // Characters
+	MaxNoteLength        int `json:"max_note_length"`        // Characters, per bookmark note
+}
+
+const (
+	defaultMaxPlaylists         = 500
+	defaultMaxItemsPerPlaylist  = 5000
+	defaultMaxNameLength        = 255
+	defaultMaxDescriptionLength = 1000
+	defaultMaxNoteLength        = 5000
+)
+
+// limits caches the quotas, as they are read on every write.
+var limits atomic.Pointer[Limits]
+
+// UserLimits returns the configured quotas. Each can be overridden with an
+// environment variable, read the first time the quotas are needed.
+func UserLimits() Limits {
+	if l := limits.Load(); l != nil {
+		return *l
+	}
+	return ReloadLimits()
+}
+
+// ReloadLimits reads the quotas from the environment again and returns them.
+func ReloadLimits() Limits {
+	l := Limits{
+		MaxPlaylists:         positiveIntEnv("MAX_PLAYLISTS_PER_USER", defaultMaxPlaylists),
+		MaxItemsPerPlaylist:  positiveIntEnv("MAX_ITEMS_PER_PLAYLIST", defaultMaxItemsPerPlaylist),
+		MaxNameLength:        positiveIntEnv("MAX_PLAYLIST_NAME_LENGTH", defaultMaxNameLength),
+		MaxDescriptionLength: positiveIntEnv("MAX_PLAYLIST_DESCRIPTION_LENGTH", defaultMaxDescriptionLength),
+		MaxNoteLength:        positiveIntEnv("MAX_BOOKMARK_NOTE_LENGTH", defaultMaxNoteLength),
+	}
+	limits.Store(&l)
+	return l
+}
+
+// positiveIntEnv reads a positive integer from the environment, falling back
+// to def if it is unset or invalid.
+func positiveIntEnv(name string, def int) int {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		log.Printf("Invalid %s %q, using %d", name, v, def)
+		return def
+	}
+	return n
+}
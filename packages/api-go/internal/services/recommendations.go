@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/database"
+)
+
+const (
+	// MaxRecommendations is how many recommendations are kept per user.
+	MaxRecommendations = 50
+	// minCooccurrenceUsers is how many users must have saved two videos to
+	// the same list before one is recommended from the other, so a
+	// recommendation never reveals a single user's list.
+	minCooccurrenceUsers = 2
+	// maxCooccurrenceListSize skips larger lists, which say little about
+	// how their videos relate and cost the square of their size.
+	maxCooccurrenceListSize = 200
+	// channelAffinityWeight scales the boost for channels the user saves
+	// from. A video from a channel making up all their saves scores up to
+	// 1 + weight times its co-occurrence score.
+	channelAffinityWeight = 1.0
+)
+
+// recommendationsLock is the advisory lock key held while recomputing, so
+// concurrent instances don't repeat the work.
+const recommendationsLock = 4804901
+
+// ComputeRecommendations rebuilds video co-occurrence across every user's
+// playlists and Watch Later, then each user's recommendations from the
+// videos they saved. Candidates score the sum of their similarity to each
+// saved video, boosted by the user's affinity for the candidate's channel,
+// and anything already saved is left out. Does nothing if another instance
+// is already computing.
+func ComputeRecommendations() error {
+	ctx := context.Background()
+	tx, err := database.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var locked bool
+	if err := tx.QueryRow(ctx, `SELECT pg_try_advisory_xact_lock($1)`, recommendationsLock).Scan(&locked); err != nil {
+		return err
+	}
+	if !locked {
+		return nil
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM video_cooccurrence`); err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `
+		WITH items AS (
+			SELECT DISTINCT p.id, p.floatplane_user_id, v.video_id
+			FROM playlists p
+			CROSS JOIN LATERAL unnest(p.video_ids) AS v(video_id)
+			WHERE p.deleted_at IS NULL AND NOT p.is_smart
+			  AND cardinality(p.video_ids) BETWEEN 2 AND $1
+		),
+		popularity AS (
+			SELECT video_id, COUNT(DISTINCT floatplane_user_id) AS users FROM items GROUP BY video_id
+		),
+		pairs AS (
+			SELECT a.video_id, b.video_id AS related_video_id, COUNT(DISTINCT a.floatplane_user_id) AS users
+			FROM items a
+			JOIN items b ON b.id = a.id AND b.video_id <> a.video_id
+			GROUP BY a.video_id, b.video_id
+			HAVING COUNT(DISTINCT a.floatplane_user_id) >= $2
+		)
+		INSERT INTO video_cooccurrence (video_id, related_video_id, users, score)
+		SELECT pr.video_id, pr.related_video_id, pr.users, pr.users / sqrt(pa.users * pb.users)
+		FROM pairs pr
+		JOIN popularity pa ON pa.video_id = pr.video_id
+		JOIN popularity pb ON pb.video_id = pr.related_video_id
+	`, maxCooccurrenceListSize, minCooccurrenceUsers)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM recommendations`); err != nil {
+		return err
+	}
+	tag, err := tx.Exec(ctx, `
+		WITH saved AS (
+			SELECT DISTINCT p.floatplane_user_id, v.video_id
+			FROM playlists p
+			CROSS JOIN LATERAL unnest(p.video_ids) AS v(video_id)
+			WHERE p.deleted_at IS NULL AND NOT p.is_smart
+		),
+		affinity AS (
+			SELECT s.floatplane_user_id, fp.channel_id,
+			       COUNT(*)::float8 / SUM(COUNT(*)) OVER (PARTITION BY s.floatplane_user_id) AS affinity
+			FROM saved s
+			JOIN fp_posts fp ON fp.id = s.video_id
+			WHERE COALESCE(fp.channel_id, '') <> ''
+			GROUP BY s.floatplane_user_id, fp.channel_id
+		),
+		candidates AS (
+			SELECT s.floatplane_user_id, c.related_video_id AS video_id, SUM(c.score) AS score,
+			       (array_agg(c.video_id ORDER BY c.score DESC, c.video_id))[1] AS because_video_id
+			FROM saved s
+			JOIN video_cooccurrence c ON c.video_id = s.video_id
+			WHERE NOT EXISTS (
+				SELECT 1 FROM saved o
+				WHERE o.floatplane_user_id = s.floatplane_user_id AND o.video_id = c.related_video_id
+			)
+			GROUP BY s.floatplane_user_id, c.related_video_id
+		),
+		scored AS (
+			SELECT c.floatplane_user_id, c.video_id, c.because_video_id,
+			       c.score * (1 + $1 * COALESCE(a.affinity, 0)) AS score
+			FROM candidates c
+			LEFT JOIN fp_posts fp ON fp.id = c.video_id
+			LEFT JOIN affinity a ON a.floatplane_user_id = c.floatplane_user_id AND a.channel_id = fp.channel_id
+		),
+		ranked AS (
+			SELECT *, row_number() OVER (PARTITION BY floatplane_user_id ORDER BY score DESC, video_id) AS rank
+			FROM scored
+		)
+		INSERT INTO recommendations (floatplane_user_id, video_id, score, because_video_id, computed_at)
+		SELECT floatplane_user_id, video_id, score, because_video_id, $2
+		FROM ranked
+		WHERE rank <= $3
+	`, channelAffinityWeight, time.Now(), MaxRecommendations)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	log.Printf("Computed %d recommendations", tag.RowsAffected())
+	return nil
+}
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Setting value types
+const (
+	SettingNumber      = "number"
+	SettingString      = "string"
+	SettingBoolean     = "boolean"
+	SettingStringArray = "string_array"
+)
+
+// SettingSpec describes one user setting. Unset limits don't apply.
+type SettingSpec struct {
+	Type      string      `json:"type"`
+	Default   interface{} `json:"default"`
+	Min       *float64    `json:"min,omitempty"`
+	Max       *float64    `json:"max,omitempty"`
+	Enum      []string    `json:"enum,omitempty"`
+	MaxLength int         `json:"max_length,omitempty"`
+	MaxItems  int         `json:"max_items,omitempty"`
+}
+
+func float(f float64) *float64 { return &f }
+
+// SettingsSchema is every setting the server stores. Keys not listed here are
+// rejected.
+var SettingsSchema = map[string]SettingSpec{
+	"playback_speed": {
+		Type: SettingNumber, Default: 1.0, Min: float(0.25), Max: float(4),
+	},
+	"default_quality": {
+		Type: SettingString, Default: "auto",
+		Enum: []string{"auto", "360p", "480p", "720p", "1080p", "1440p", "2160p"},
+	},
+	"subtitle_language": {
+		Type: SettingString, Default: "", MaxLength: 35, // BCP 47 tag, empty for off
+	},
+	"hidden_channels": {
+		Type: SettingStringArray, Default: []string{}, MaxItems: 500, MaxLength: 100,
+	},
+	"autoplay": {
+		Type: SettingBoolean, Default: true,
+	},
+}
+
+// SettingKeys returns the schema's keys in order.
+func SettingKeys() []string {
+	keys := make([]string, 0, len(SettingsSchema))
+	for key := range SettingsSchema {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ValidateSetting decodes a value for key and checks it against the schema.
+func ValidateSetting(key string, raw json.RawMessage) (interface{}, error) {
+	spec, ok := SettingsSchema[key]
+	if !ok {
+		return nil, fmt.Errorf("unknown setting %q", key)
+	}
+
+	switch spec.Type {
+	case SettingNumber:
+		var v float64
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, fmt.Errorf("%s must be a number", key)
+		}
+		if err := spec.checkNumber(key, v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	case SettingString:
+		var v string
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, fmt.Errorf("%s must be a string", key)
+		}
+		if err := spec.checkString(key, v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	case SettingBoolean:
+		var v bool
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, fmt.Errorf("%s must be true or false", key)
+		}
+		return v, nil
+	case SettingStringArray:
+		var v []string
+		if err := json.Unmarshal(raw, &v); err != nil || v == nil {
+			return nil, fmt.Errorf("%s must be a list of strings", key)
+		}
+		if spec.MaxItems > 0 && len(v) > spec.MaxItems {
+			return nil, fmt.Errorf("%s can have at most %d entries", key, spec.MaxItems)
+		}
+		for _, s := range v {
+			if err := spec.checkString(key, s); err != nil {
+				return nil, err
+			}
+		}
+		return v, nil
+	}
+	return nil, fmt.Errorf("%s has an unsupported type", key)
+}
+
+func (spec SettingSpec) checkNumber(key string, v float64) error {
+	switch {
+	case spec.Min != nil && spec.Max != nil && (v < *spec.Min || v > *spec.Max):
+		return fmt.Errorf("%s must be between %g and %g", key, *spec.Min, *spec.Max)
+	case spec.Min != nil && v < *spec.Min:
+		return fmt.Errorf("%s must be at least %g", key, *spec.Min)
+	case spec.Max != nil && v > *spec.Max:
+		return fmt.Errorf("%s must be at most %g", key, *spec.Max)
+	}
+	return nil
+}
+
+func (spec SettingSpec) checkString(key, v string) error {
+	if spec.MaxLength > 0 && len(v) > spec.MaxLength {
+		return fmt.Errorf("%s can be at most %d characters", key, spec.MaxLength)
+	}
+	if len(spec.Enum) == 0 {
+		return nil
+	}
+	for _, allowed := range spec.Enum {
+		if v == allowed {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s must be one of %v", key, spec.Enum)
+}
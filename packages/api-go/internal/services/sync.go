@@ -0,0 +1,40 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/database"
+)
+
+// playlistWriteLock is the advisory lock class for LockPlaylistWrites.
+const playlistWriteLock = 4803301
+
+// LockPlaylistWrites serializes the user's playlist changes until the
+// transaction in ctx ends, for changes that read before they write, such as
+// checking a quota or merging playlists. Take it before any row locks, so
+// two changes can't wait on each other.
+func LockPlaylistWrites(ctx context.Context, userID string) error {
+	_, err := database.Conn(ctx).Exec(ctx, `SELECT pg_advisory_xact_lock($1, hashtext($2))`, playlistWriteLock, userID)
+	return err
+}
+
+// syncOperationRetention is how long applied offline mutations are remembered,
+// which bounds how late a client can safely retry a queue.
+const syncOperationRetention = 30 * 24 * time.Hour
+
+// PurgeSyncOperations forgets stored sync operation results past the retention
+// period.
+func PurgeSyncOperations() error {
+	tag, err := database.Pool.Exec(context.Background(), `
+		DELETE FROM sync_operations WHERE created_at < $1
+	`, time.Now().Add(-syncOperationRetention))
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() > 0 {
+		log.Printf("Purged %d sync operation results", tag.RowsAffected())
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/database"
+)
+
+const defaultTrashRetentionDays = 30
+
+// TrashRetention is how long deleted playlists stay in the trash before being
+// purged. Configured with PLAYLIST_TRASH_RETENTION_DAYS.
+func TrashRetention() time.Duration {
+	days := positiveIntEnv("PLAYLIST_TRASH_RETENTION_DAYS", defaultTrashRetentionDays)
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// PurgeDeletedPlaylists permanently deletes playlists that have been in the
+// trash for longer than the retention period.
+func PurgeDeletedPlaylists() error {
+	cutoff := time.Now().Add(-TrashRetention())
+	tag, err := database.Pool.Exec(context.Background(), `
+		DELETE FROM playlists WHERE deleted_at IS NOT NULL AND deleted_at < $1
+	`, cutoff)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() > 0 {
+		log.Printf("Purged %d deleted playlists", tag.RowsAffected())
+	}
+	return nil
+}
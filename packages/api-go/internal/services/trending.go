@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/database"
+)
+
+// Trending windows, as accepted by GET /trending
+const (
+	TrendingWindowDay  = "24h"
+	TrendingWindowWeek = "7d"
+)
+
+// TrendingWindows maps each trending window to its length.
+var TrendingWindows = map[string]time.Duration{
+	TrendingWindowDay:  24 * time.Hour,
+	TrendingWindowWeek: 7 * 24 * time.Hour,
+}
+
+const (
+	// MaxTrending is how many posts are kept per window.
+	MaxTrending = 100
+	// minTrendingUsers is how many users must have added a post in the
+	// window before it is listed, so no one user's saves can be picked out.
+	minTrendingUsers = 3
+	// videoAddRetention covers the longest window.
+	videoAddRetention = 7 * 24 * time.Hour
+)
+
+// trendingLock is the advisory lock key held while recomputing, so
+// concurrent instances don't repeat the work.
+const trendingLock = 4805001
+
+// ComputeTrending forgets adds older than the longest window, then ranks
+// the posts added by the most users in each window. Posts not in fp_posts
+// are left out. Does nothing if another instance is already computing.
+func ComputeTrending() error {
+	ctx := context.Background()
+	tx, err := database.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var locked bool
+	if err := tx.QueryRow(ctx, `SELECT pg_try_advisory_xact_lock($1)`, trendingLock).Scan(&locked); err != nil {
+		return err
+	}
+	if !locked {
+		return nil
+	}
+
+	now := time.Now()
+	if _, err := tx.Exec(ctx, `DELETE FROM video_adds WHERE added_at < $1`, now.Add(-videoAddRetention)); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM trending`); err != nil {
+		return err
+	}
+	for window, length := range TrendingWindows {
+		_, err := tx.Exec(ctx, `
+			WITH counts AS (
+				SELECT a.video_id, COUNT(DISTINCT a.floatplane_user_id) AS users
+				FROM video_adds a
+				JOIN fp_posts fp ON fp.id = a.video_id
+				WHERE a.added_at >= $2
+				GROUP BY a.video_id
+				HAVING COUNT(DISTINCT a.floatplane_user_id) >= $3
+			),
+			ranked AS (
+				SELECT c.video_id, c.users, row_number() OVER (ORDER BY c.users DESC, fp.release_date DESC NULLS LAST, c.video_id) AS rank
+				FROM counts c
+				JOIN fp_posts fp ON fp.id = c.video_id
+			)
+			INSERT INTO trending (time_window, video_id, users, rank, computed_at)
+			SELECT $1, video_id, users, rank, $4 FROM ranked WHERE rank <= $5
+		`, window, now.Add(-length), minTrendingUsers, now, MaxTrending)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/database"
+)
+
+// Video ID validation modes, set with VIDEO_ID_VALIDATION.
+const (
+	VideoValidationOff        = "off"        // Post URLs are resolved; any other non-empty string is stored as is
+	VideoValidationFormat     = "format"     // Well-formed post IDs or floatplane.com post URLs
+	VideoValidationLocal      = "fp_posts"   // As format, and flag IDs missing from fp_posts
+	VideoValidationFloatplane = "floatplane" // As fp_posts, then ask Floatplane about the rest
+)
+
+// maxRemoteLookups caps the Floatplane requests made for a single write.
+// IDs past the cap are assumed to exist.
+const maxRemoteLookups = 20
+
+// VideoValidationMode returns the configured validation mode, defaulting to
+// off so IDs stored before validation existed keep working.
+func VideoValidationMode() string {
+	switch mode := os.Getenv("VIDEO_ID_VALIDATION"); mode {
+	case "":
+		return VideoValidationOff
+	case VideoValidationOff, VideoValidationFormat, VideoValidationLocal, VideoValidationFloatplane:
+		return mode
+	default:
+		log.Printf("Invalid VIDEO_ID_VALIDATION %q, using %s", mode, VideoValidationOff)
+		return VideoValidationOff
+	}
+}
+
+// NormalizeVideoID returns the post ID to store for a client supplied video ID
+// or URL, or false if the current mode rejects it.
+func NormalizeVideoID(s string) (string, bool) {
+	if VideoValidationMode() == VideoValidationOff {
+		if id, ok := ExtractPostIDFromURL(s); ok {
+			return id, true
+		}
+		s = strings.TrimSpace(s)
+		return s, s != ""
+	}
+	return ExtractPostID(s)
+}
+
+// StoredVideoIDs returns the IDs a client supplied video ID may have been
+// stored as: the normalized post ID and the raw value, which older entries
+// or a stricter mode may not agree on. Used to match entries for removal,
+// so anything stored can always be removed.
+func StoredVideoIDs(s string) []string {
+	raw := strings.TrimSpace(s)
+	if raw == "" {
+		return nil
+	}
+	ids := []string{raw}
+	if id, ok := ExtractPostID(raw); ok && id != raw {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// UnknownVideoIDs returns the IDs in ids that couldn't be found, in order.
+// It is a no-op unless the mode checks existence.
+func UnknownVideoIDs(ctx context.Context, ids []string) ([]string, error) {
+	mode := VideoValidationMode()
+	if len(ids) == 0 || (mode != VideoValidationLocal && mode != VideoValidationFloatplane) {
+		return nil, nil
+	}
+
+	rows, err := database.Conn(ctx).Query(ctx, `SELECT id FROM fp_posts WHERE id = ANY($1)`, ids)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		known[id] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var unknown []string
+	lookups := 0
+	for _, id := range ids {
+		if known[id] {
+			continue
+		}
+		if mode == VideoValidationFloatplane {
+			if lookups >= maxRemoteLookups {
+				continue
+			}
+			lookups++
+			exists, err := floatplanePostExists(ctx, id)
+			if err != nil {
+				// Don't flag videos just because Floatplane is unreachable
+				log.Printf("Failed to look up post %s: %v", id, err)
+				continue
+			}
+			if exists {
+				known[id] = true
+				continue
+			}
+		}
+		known[id] = true // Report duplicates once
+		unknown = append(unknown, id)
+	}
+	return unknown, nil
+}
+
+// floatplanePostExists asks the Floatplane API whether a post exists.
+func floatplanePostExists(ctx context.Context, id string) (bool, error) {
+	apiUrl := os.Getenv("FLOATPLANE_API_URL")
+	sailsSid := os.Getenv("FLOATPLANE_SAILS_SID")
+	if sailsSid == "" {
+		return false, fmt.Errorf("FLOATPLANE_SAILS_SID is not set")
+	}
+	if apiUrl == "" {
+		apiUrl = "https://www.floatplane.com/api" // Default
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", apiUrl+"/v3/content/post?id="+url.QueryEscape(id), nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Cookie", "sails.sid="+sailsSid)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusBadRequest, http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("API request failed with status: %d", resp.StatusCode)
+	}
+}
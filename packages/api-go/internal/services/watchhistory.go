@@ -0,0 +1,27 @@
+package services
+
+import (
+	"context"
+	"log"
+
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/database"
+)
+
+// PurgeWatchHistory deletes history entries older than each user's retention
+// setting.
+func PurgeWatchHistory() error {
+	tag, err := database.Pool.Exec(context.Background(), `
+		DELETE FROM watch_history h
+		USING watch_history_settings s
+		WHERE h.floatplane_user_id = s.floatplane_user_id
+		  AND s.retention_days IS NOT NULL
+		  AND h.watched_at < NOW() - make_interval(days => s.retention_days)
+	`)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() > 0 {
+		log.Printf("Purged %d watch history entries", tag.RowsAffected())
+	}
+	return nil
+}
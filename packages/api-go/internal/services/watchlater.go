@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/database"
+	"github.com/jackc/pgx/v5"
+)
+
+// Reasons a Watch Later item expired
+const (
+	ExpiryReasonMaxAge   = "max_age"
+	ExpiryReasonMaxItems = "max_items"
+)
+
+// ExpiredRetention is how long expired Watch Later items can be restored.
+const ExpiredRetention = 30 * 24 * time.Hour
+
+// ExpireWatchLater applies every user's Watch Later expiry rules, then forgets
+// expired items that are past the restore window.
+func ExpireWatchLater() error {
+	ctx := context.Background()
+	rows, err := database.Pool.Query(ctx, `
+		SELECT floatplane_user_id FROM watch_later_expiry
+		WHERE max_age_days IS NOT NULL OR max_items IS NOT NULL
+	`)
+	if err != nil {
+		return err
+	}
+	var userIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		userIDs = append(userIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	total := 0
+	for _, userID := range userIDs {
+		expired, err := ExpireWatchLaterForUser(ctx, userID)
+		if err != nil {
+			log.Printf("Failed to expire Watch Later for %s: %v", userID, err)
+			continue
+		}
+		total += len(expired)
+	}
+	if total > 0 {
+		log.Printf("Expired %d Watch Later items", total)
+	}
+
+	_, err = database.Pool.Exec(ctx, `
+		DELETE FROM watch_later_expired WHERE expired_at < $1
+	`, time.Now().Add(-ExpiredRetention))
+	return err
+}
+
+// ExpireWatchLaterForUser removes the items the user's expiry rules select
+// from their Watch Later, recording them in watch_later_expired and as an
+// expire revision. It returns the removed video IDs.
+func ExpireWatchLaterForUser(ctx context.Context, userID string) ([]string, error) {
+	tx, err := database.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+	if err := LockPlaylistWrites(database.WithTx(ctx, tx), userID); err != nil {
+		return nil, err
+	}
+
+	var playlistID string
+	var maxAgeDays, maxItems *int
+	err = tx.QueryRow(ctx, `
+		SELECT p.id, e.max_age_days, e.max_items
+		FROM watch_later_expiry e
+		JOIN playlists p ON p.floatplane_user_id = e.floatplane_user_id AND p.is_watch_later
+		WHERE e.floatplane_user_id = $1
+		FOR UPDATE OF p
+	`, userID).Scan(&playlistID, &maxAgeDays, &maxItems)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil // No rules, or no Watch Later yet
+	}
+	if err != nil {
+		return nil, err
+	}
+	if maxAgeDays == nil && maxItems == nil {
+		return nil, nil
+	}
+
+	now := time.Now()
+	// Items without attribution count from when Watch Later was created
+	rows, err := tx.Query(ctx, `
+		WITH items AS (
+			SELECT v.video_id, COALESCE(i.added_at, p.created_at) AS added_at,
+			       row_number() OVER (ORDER BY COALESCE(i.added_at, p.created_at) DESC, v.position DESC) AS newest
+			FROM playlists p
+			CROSS JOIN LATERAL unnest(p.video_ids) WITH ORDINALITY AS v(video_id, position)
+			LEFT JOIN playlist_items i ON i.playlist_id = p.id AND i.video_id = v.video_id
+			WHERE p.id = $1
+		),
+		expired AS (
+			SELECT DISTINCT ON (video_id) video_id, added_at,
+			       CASE WHEN added_at < $5 - make_interval(days => $3::int) THEN $6 ELSE $7 END AS reason
+			FROM items
+			WHERE added_at < $5 - make_interval(days => $3::int) OR newest > $4::int
+			ORDER BY video_id
+		)
+		INSERT INTO watch_later_expired (floatplane_user_id, video_id, added_at, expired_at, reason)
+		SELECT $2, video_id, added_at, $5, reason FROM expired
+		ON CONFLICT (floatplane_user_id, video_id) DO UPDATE
+		SET added_at = EXCLUDED.added_at, expired_at = EXCLUDED.expired_at, reason = EXCLUDED.reason
+		RETURNING video_id
+	`, playlistID, userID, maxAgeDays, maxItems, now, ExpiryReasonMaxAge, ExpiryReasonMaxItems)
+	if err != nil {
+		return nil, err
+	}
+	var expired []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		expired = append(expired, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(expired) == 0 {
+		return nil, nil
+	}
+
+	var name string
+	var videoIDs []string
+	err = tx.QueryRow(ctx, `
+		UPDATE playlists
+		SET video_ids = ARRAY(
+		        SELECT v FROM unnest(video_ids) WITH ORDINALITY AS t(v, n)
+		        WHERE NOT (v = ANY($2)) ORDER BY n
+		    ),
+		    updated_at = $3
+		WHERE id = $1
+		RETURNING name, video_ids
+	`, playlistID, expired, now).Scan(&name, &videoIDs)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM playlist_items WHERE playlist_id = $1 AND video_id = ANY($2)
+	`, playlistID, expired); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO playlist_revisions (playlist_id, floatplane_user_id, action, name, video_ids, created_at)
+		VALUES ($1, $2, 'expire', $3, $4, $5)
+	`, playlistID, userID, name, videoIDs, now); err != nil {
+		return nil, err
+	}
+	if err := PublishEvent(database.WithTx(ctx, tx), userID, "", EventWatchLaterChanged, map[string]interface{}{
+		"playlist_id": playlistID,
+		"action":      "expire",
+		"name":        name,
+		"video_ids":   videoIDs,
+	}); err != nil {
+		return nil, err
+	}
+
+	return expired, tx.Commit(ctx)
+}
@@ -0,0 +1,9 @@
+-- Playlist share links
+CREATE TABLE IF NOT EXISTS playlist_shares (
+    token TEXT PRIMARY KEY,
+    playlist_id UUID NOT NULL REFERENCES playlists(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_playlist_shares_playlist_id ON playlist_shares(playlist_id);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS playlist_items;
+DROP TABLE IF EXISTS playlist_invites;
+DROP TABLE IF EXISTS playlist_members;
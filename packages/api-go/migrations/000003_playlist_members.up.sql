@@ -0,0 +1,40 @@
+-- Collaborative playlist members. The playlist's floatplane_user_id is always
+-- treated as an owner; rows here grant access to additional users.
+CREATE TABLE IF NOT EXISTS playlist_members (
+    playlist_id UUID NOT NULL REFERENCES playlists(id) ON DELETE CASCADE,
+    floatplane_user_id TEXT NOT NULL REFERENCES users(floatplane_user_id) ON DELETE CASCADE,
+    role TEXT NOT NULL CHECK (role IN ('viewer', 'editor', 'owner')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (playlist_id, floatplane_user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_playlist_members_user_id ON playlist_members(floatplane_user_id);
+
+-- Invitation codes for joining a playlist with a given role
+CREATE TABLE IF NOT EXISTS playlist_invites (
+    code TEXT PRIMARY KEY,
+    playlist_id UUID NOT NULL REFERENCES playlists(id) ON DELETE CASCADE,
+    role TEXT NOT NULL CHECK (role IN ('viewer', 'editor')),
+    created_by TEXT NOT NULL REFERENCES users(floatplane_user_id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_playlist_invites_playlist_id ON playlist_invites(playlist_id);
+
+-- Per-item attribution. video_ids on playlists stays the source of truth for order.
+CREATE TABLE IF NOT EXISTS playlist_items (
+    playlist_id UUID NOT NULL REFERENCES playlists(id) ON DELETE CASCADE,
+    video_id TEXT NOT NULL,
+    added_by TEXT NOT NULL,
+    added_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (playlist_id, video_id)
+);
+
+-- Backfill attribution for existing items, crediting the playlist owner. Only
+-- on the first run, as migrations re-run on every boot and the API keeps the
+-- table in step from then on.
+INSERT INTO playlist_items (playlist_id, video_id, added_by, added_at)
+SELECT id, unnest(video_ids), floatplane_user_id, updated_at FROM playlists
+WHERE NOT EXISTS (SELECT 1 FROM playlist_items)
+ON CONFLICT DO NOTHING;
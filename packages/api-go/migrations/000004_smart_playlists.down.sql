@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_fp_posts_channel_id;
+ALTER TABLE playlists DROP COLUMN IF EXISTS smart_rules;
+ALTER TABLE playlists DROP COLUMN IF EXISTS is_smart;
@@ -0,0 +1,6 @@
+-- Smart playlists store a rule set instead of a video list and are resolved
+-- against fp_posts on read.
+ALTER TABLE playlists ADD COLUMN IF NOT EXISTS is_smart BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE playlists ADD COLUMN IF NOT EXISTS smart_rules JSONB;
+
+CREATE INDEX IF NOT EXISTS idx_fp_posts_channel_id ON fp_posts(channel_id);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_playlists_deleted_at;
+ALTER TABLE playlists DROP COLUMN IF EXISTS deleted_at;
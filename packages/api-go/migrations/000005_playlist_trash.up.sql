@@ -0,0 +1,4 @@
+-- Soft delete: deleted playlists sit in the trash until purged
+ALTER TABLE playlists ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_playlists_deleted_at ON playlists(deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,15 @@
+-- Playlist revision history. Each row is a snapshot of the playlist after a
+-- mutation, so reverting is restoring a snapshot.
+CREATE TABLE IF NOT EXISTS playlist_revisions (
+    id BIGSERIAL PRIMARY KEY,
+    playlist_id UUID NOT NULL REFERENCES playlists(id) ON DELETE CASCADE,
+    floatplane_user_id TEXT NOT NULL,
+    device_session_id TEXT,
+    device_info TEXT,
+    action TEXT NOT NULL, -- create, add, remove, reorder, rename, replace, revert, restore
+    name TEXT NOT NULL,
+    video_ids TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_playlist_revisions_playlist_id ON playlist_revisions(playlist_id, id DESC);
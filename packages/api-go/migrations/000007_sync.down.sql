@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS sync_operations;
+DROP TRIGGER IF EXISTS playlist_members_sync ON playlist_members;
+DROP FUNCTION IF EXISTS playlist_member_changed();
+DROP TRIGGER IF EXISTS playlists_tombstone ON playlists;
+DROP FUNCTION IF EXISTS playlist_deleted_tombstone();
+DROP TABLE IF EXISTS sync_tombstones;
+DROP TRIGGER IF EXISTS playlists_sync_version ON playlists;
+DROP FUNCTION IF EXISTS bump_playlist_sync_version();
+DROP INDEX IF EXISTS idx_playlists_sync_version;
+ALTER TABLE playlists DROP COLUMN IF EXISTS sync_version;
+DROP SEQUENCE IF EXISTS playlist_sync_seq;
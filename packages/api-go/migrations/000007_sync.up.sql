@@ -0,0 +1,75 @@
+-- Delta sync. Every playlist write takes a new value from playlist_sync_seq,
+-- so clients can ask for everything that changed after the last value they saw.
+CREATE SEQUENCE IF NOT EXISTS playlist_sync_seq;
+
+ALTER TABLE playlists ADD COLUMN IF NOT EXISTS sync_version BIGINT NOT NULL DEFAULT nextval('playlist_sync_seq');
+
+CREATE INDEX IF NOT EXISTS idx_playlists_sync_version ON playlists(sync_version);
+
+CREATE OR REPLACE FUNCTION bump_playlist_sync_version() RETURNS trigger AS $$
+BEGIN
+    NEW.sync_version := nextval('playlist_sync_seq');
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS playlists_sync_version ON playlists;
+CREATE TRIGGER playlists_sync_version BEFORE UPDATE ON playlists
+    FOR EACH ROW EXECUTE FUNCTION bump_playlist_sync_version();
+
+-- Tombstones for playlists a user can no longer see: purged from the trash, or
+-- the user was removed as a member. Trashed playlists are still rows in
+-- playlists and sync through deleted_at.
+CREATE TABLE IF NOT EXISTS sync_tombstones (
+    sync_version BIGINT PRIMARY KEY DEFAULT nextval('playlist_sync_seq'),
+    floatplane_user_id TEXT NOT NULL,
+    playlist_id UUID NOT NULL,
+    reason TEXT NOT NULL, -- deleted, removed
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_sync_tombstones_user ON sync_tombstones(floatplane_user_id, sync_version);
+
+CREATE OR REPLACE FUNCTION playlist_deleted_tombstone() RETURNS trigger AS $$
+BEGIN
+    INSERT INTO sync_tombstones (floatplane_user_id, playlist_id, reason)
+    VALUES (OLD.floatplane_user_id, OLD.id, 'deleted');
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS playlists_tombstone ON playlists;
+CREATE TRIGGER playlists_tombstone AFTER DELETE ON playlists
+    FOR EACH ROW EXECUTE FUNCTION playlist_deleted_tombstone();
+
+-- Membership changes alter what a member sees, so they touch the playlist.
+-- Members of a purged playlist are removed by the cascade after the playlist
+-- row is gone, which is how deleted is told apart from removed.
+CREATE OR REPLACE FUNCTION playlist_member_changed() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        INSERT INTO sync_tombstones (floatplane_user_id, playlist_id, reason)
+        SELECT OLD.floatplane_user_id, OLD.playlist_id,
+               CASE WHEN EXISTS (SELECT 1 FROM playlists WHERE id = OLD.playlist_id) THEN 'removed' ELSE 'deleted' END;
+    END IF;
+    -- The value is replaced by bump_playlist_sync_version
+    UPDATE playlists SET sync_version = 0 WHERE id = COALESCE(NEW.playlist_id, OLD.playlist_id);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS playlist_members_sync ON playlist_members;
+CREATE TRIGGER playlist_members_sync AFTER INSERT OR UPDATE OR DELETE ON playlist_members
+    FOR EACH ROW EXECUTE FUNCTION playlist_member_changed();
+
+-- Results of applied offline mutations, so a client retrying a queue after a
+-- dropped connection doesn't apply anything twice.
+CREATE TABLE IF NOT EXISTS sync_operations (
+    floatplane_user_id TEXT NOT NULL,
+    op_id TEXT NOT NULL,
+    result JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (floatplane_user_id, op_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_sync_operations_created_at ON sync_operations(created_at);
@@ -0,0 +1,6 @@
+ALTER TABLE playlists DROP COLUMN IF EXISTS sort_order;
+ALTER TABLE playlists DROP COLUMN IF EXISTS pinned;
+ALTER TABLE playlists DROP COLUMN IF EXISTS emoji;
+ALTER TABLE playlists DROP COLUMN IF EXISTS color;
+ALTER TABLE playlists DROP COLUMN IF EXISTS cover_video_id;
+ALTER TABLE playlists DROP COLUMN IF EXISTS description;
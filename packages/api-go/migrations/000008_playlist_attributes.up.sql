@@ -0,0 +1,7 @@
+-- Presentation attributes for showing playlists as a shelf
+ALTER TABLE playlists ADD COLUMN IF NOT EXISTS description TEXT;
+ALTER TABLE playlists ADD COLUMN IF NOT EXISTS cover_video_id TEXT;
+ALTER TABLE playlists ADD COLUMN IF NOT EXISTS color TEXT; -- #RRGGBB
+ALTER TABLE playlists ADD COLUMN IF NOT EXISTS emoji TEXT;
+ALTER TABLE playlists ADD COLUMN IF NOT EXISTS pinned BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE playlists ADD COLUMN IF NOT EXISTS sort_order INT NOT NULL DEFAULT 0;
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_playlists_one_watch_later;
@@ -0,0 +1,42 @@
+-- Repair: merge duplicate Watch Later playlists into each user's oldest one,
+-- keeping the first occurrence of every video in age order.
+DROP TABLE IF EXISTS watch_later_ranked;
+CREATE TEMP TABLE watch_later_ranked AS
+SELECT id, floatplane_user_id, video_ids,
+       row_number() OVER (PARTITION BY floatplane_user_id ORDER BY created_at, id) AS rn
+FROM playlists
+WHERE is_watch_later
+  AND floatplane_user_id IN (
+      SELECT floatplane_user_id FROM playlists WHERE is_watch_later
+      GROUP BY floatplane_user_id HAVING COUNT(*) > 1
+  );
+
+WITH items AS (
+    SELECT r.floatplane_user_id, v.video_id, MIN(ARRAY[r.rn, v.position]) AS first_seen
+    FROM watch_later_ranked r
+    CROSS JOIN LATERAL unnest(r.video_ids) WITH ORDINALITY AS v(video_id, position)
+    GROUP BY r.floatplane_user_id, v.video_id
+),
+merged AS (
+    SELECT floatplane_user_id, array_agg(video_id ORDER BY first_seen) AS video_ids
+    FROM items GROUP BY floatplane_user_id
+)
+UPDATE playlists p
+SET video_ids = m.video_ids, updated_at = NOW()
+FROM watch_later_ranked r JOIN merged m ON m.floatplane_user_id = r.floatplane_user_id
+WHERE p.id = r.id AND r.rn = 1;
+
+INSERT INTO playlist_items (playlist_id, video_id, added_by, added_at)
+SELECT keeper.id, i.video_id, i.added_by, i.added_at
+FROM watch_later_ranked dup
+JOIN watch_later_ranked keeper ON keeper.floatplane_user_id = dup.floatplane_user_id AND keeper.rn = 1
+JOIN playlist_items i ON i.playlist_id = dup.id
+WHERE dup.rn > 1
+ON CONFLICT DO NOTHING;
+
+DELETE FROM playlists p USING watch_later_ranked r WHERE p.id = r.id AND r.rn > 1;
+
+DROP TABLE watch_later_ranked;
+
+-- One Watch Later per user
+CREATE UNIQUE INDEX IF NOT EXISTS idx_playlists_one_watch_later ON playlists(floatplane_user_id) WHERE is_watch_later;
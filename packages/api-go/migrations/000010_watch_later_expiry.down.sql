@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS watch_later_expired;
+DROP TABLE IF EXISTS watch_later_expiry;
@@ -0,0 +1,19 @@
+-- Per-user Watch Later expiry rules. NULL turns a rule off.
+CREATE TABLE IF NOT EXISTS watch_later_expiry (
+    floatplane_user_id TEXT PRIMARY KEY REFERENCES users(floatplane_user_id) ON DELETE CASCADE,
+    max_age_days INT,   -- Remove items added more than this many days ago
+    max_items INT,      -- Keep only the newest this many items
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Items removed by the expiry job, so they can be restored
+CREATE TABLE IF NOT EXISTS watch_later_expired (
+    floatplane_user_id TEXT NOT NULL REFERENCES users(floatplane_user_id) ON DELETE CASCADE,
+    video_id TEXT NOT NULL,
+    added_at TIMESTAMPTZ NOT NULL,
+    expired_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    reason TEXT NOT NULL, -- max_age, max_items
+    PRIMARY KEY (floatplane_user_id, video_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_watch_later_expired_expired_at ON watch_later_expired(floatplane_user_id, expired_at DESC);
@@ -0,0 +1,14 @@
+-- Playback position per user and video, synced across devices. Writes are
+-- last-writer-wins on the client's timestamp, not arrival order.
+CREATE TABLE IF NOT EXISTS watch_progress (
+    floatplane_user_id TEXT NOT NULL REFERENCES users(floatplane_user_id) ON DELETE CASCADE,
+    video_id TEXT NOT NULL,
+    position_seconds DOUBLE PRECISION NOT NULL,
+    duration_seconds DOUBLE PRECISION, -- NULL if the client didn't know it
+    device TEXT,
+    client_updated_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (floatplane_user_id, video_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_watch_progress_recent ON watch_progress(floatplane_user_id, client_updated_at DESC);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS watch_history_settings;
+DROP TABLE IF EXISTS watch_history;
@@ -0,0 +1,19 @@
+-- Watch history: one row per viewing of a post
+CREATE TABLE IF NOT EXISTS watch_history (
+    id BIGSERIAL PRIMARY KEY,
+    floatplane_user_id TEXT NOT NULL REFERENCES users(floatplane_user_id) ON DELETE CASCADE,
+    video_id TEXT NOT NULL,
+    watched_at TIMESTAMPTZ NOT NULL,
+    watched_seconds DOUBLE PRECISION NOT NULL DEFAULT 0,
+    device TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_watch_history_user_watched_at ON watch_history(floatplane_user_id, watched_at DESC, id DESC);
+
+-- Privacy controls. No row means history is recorded and kept forever.
+CREATE TABLE IF NOT EXISTS watch_history_settings (
+    floatplane_user_id TEXT PRIMARY KEY REFERENCES users(floatplane_user_id) ON DELETE CASCADE,
+    paused BOOLEAN NOT NULL DEFAULT FALSE,
+    retention_days INT, -- NULL keeps history forever
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
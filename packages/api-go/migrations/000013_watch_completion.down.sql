@@ -0,0 +1,3 @@
+ALTER TABLE playlists DROP COLUMN IF EXISTS remove_when_watched;
+ALTER TABLE watch_progress DROP COLUMN IF EXISTS completed;
+DROP TABLE IF EXISTS watch_completion_settings;
@@ -0,0 +1,14 @@
+-- When a video counts as finished. No row means the defaults: 90% watched,
+-- and finished videos leave Watch Later.
+CREATE TABLE IF NOT EXISTS watch_completion_settings (
+    floatplane_user_id TEXT PRIMARY KEY REFERENCES users(floatplane_user_id) ON DELETE CASCADE,
+    mode TEXT NOT NULL DEFAULT 'percent', -- percent, credits
+    percent INT NOT NULL DEFAULT 90,
+    remove_from_watch_later BOOLEAN NOT NULL DEFAULT TRUE,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE watch_progress ADD COLUMN IF NOT EXISTS completed BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Playlists that drop videos once the owner finishes them
+ALTER TABLE playlists ADD COLUMN IF NOT EXISTS remove_when_watched BOOLEAN NOT NULL DEFAULT FALSE;
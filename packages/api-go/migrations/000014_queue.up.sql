@@ -0,0 +1,20 @@
+-- Up Next queue. Items are ordered by a sparse position so inserts and moves
+-- touch a single row.
+CREATE TABLE IF NOT EXISTS queue_items (
+    id BIGSERIAL PRIMARY KEY,
+    floatplane_user_id TEXT NOT NULL REFERENCES users(floatplane_user_id) ON DELETE CASCADE,
+    video_id TEXT NOT NULL,
+    position DOUBLE PRECISION NOT NULL,
+    added_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_queue_items_user_position ON queue_items(floatplane_user_id, position);
+
+-- One row per user with a queue. version is bumped on every change so clients
+-- can cheaply check whether their copy is stale.
+CREATE TABLE IF NOT EXISTS queue_state (
+    floatplane_user_id TEXT PRIMARY KEY REFERENCES users(floatplane_user_id) ON DELETE CASCADE,
+    current_video_id TEXT,
+    version BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
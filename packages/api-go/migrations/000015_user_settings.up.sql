@@ -0,0 +1,11 @@
+-- User settings. The row with device '' holds the account settings; other
+-- rows hold per-device overrides. changed_at maps each key to when it last
+-- changed.
+CREATE TABLE IF NOT EXISTS user_settings (
+    floatplane_user_id TEXT NOT NULL REFERENCES users(floatplane_user_id) ON DELETE CASCADE,
+    device TEXT NOT NULL DEFAULT '',
+    settings JSONB NOT NULL DEFAULT '{}',
+    changed_at JSONB NOT NULL DEFAULT '{}',
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (floatplane_user_id, device)
+);
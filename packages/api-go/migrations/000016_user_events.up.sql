@@ -0,0 +1,13 @@
+-- Changes pushed to a user's devices over /events. Kept for a day so a
+-- reconnecting device can resume from its Last-Event-ID.
+CREATE TABLE IF NOT EXISTS user_events (
+    id BIGSERIAL PRIMARY KEY,
+    floatplane_user_id TEXT NOT NULL REFERENCES users(floatplane_user_id) ON DELETE CASCADE,
+    type TEXT NOT NULL,
+    data JSONB NOT NULL DEFAULT '{}',
+    origin_session_id TEXT, -- Device that made the change, which doesn't need it back
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_events_user_id ON user_events(floatplane_user_id, id);
+CREATE INDEX IF NOT EXISTS idx_user_events_created_at ON user_events(created_at);
@@ -0,0 +1,14 @@
+-- Timestamp bookmarks with optional notes on posts
+CREATE TABLE IF NOT EXISTS bookmarks (
+    id BIGSERIAL PRIMARY KEY,
+    floatplane_user_id TEXT NOT NULL REFERENCES users(floatplane_user_id) ON DELETE CASCADE,
+    video_id TEXT NOT NULL,
+    timestamp_seconds DOUBLE PRECISION NOT NULL,
+    label TEXT NOT NULL DEFAULT '',
+    note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_bookmarks_user_created_at ON bookmarks(floatplane_user_id, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_bookmarks_user_video ON bookmarks(floatplane_user_id, video_id, timestamp_seconds);
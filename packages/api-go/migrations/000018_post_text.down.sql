@@ -0,0 +1 @@
+ALTER TABLE fp_posts DROP COLUMN IF EXISTS text;
@@ -0,0 +1,2 @@
+-- Post descriptions, kept for parsing chapters
+ALTER TABLE fp_posts ADD COLUMN IF NOT EXISTS text TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS recommendations;
+DROP TABLE IF EXISTS video_cooccurrence;
@@ -0,0 +1,21 @@
+-- How often two videos are saved to the same list, by distinct users.
+-- Rebuilt by the recommendations job.
+CREATE TABLE IF NOT EXISTS video_cooccurrence (
+    video_id TEXT NOT NULL,
+    related_video_id TEXT NOT NULL,
+    users INT NOT NULL,
+    score DOUBLE PRECISION NOT NULL, -- users / sqrt(popularity of each video)
+    PRIMARY KEY (video_id, related_video_id)
+);
+
+-- Each user's top recommendations from the last job run
+CREATE TABLE IF NOT EXISTS recommendations (
+    floatplane_user_id TEXT NOT NULL REFERENCES users(floatplane_user_id) ON DELETE CASCADE,
+    video_id TEXT NOT NULL,
+    score DOUBLE PRECISION NOT NULL,
+    because_video_id TEXT NOT NULL,
+    computed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (floatplane_user_id, video_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_recommendations_user_score ON recommendations(floatplane_user_id, score DESC);
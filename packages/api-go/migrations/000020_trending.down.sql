@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS trending;
+DROP TABLE IF EXISTS video_adds;
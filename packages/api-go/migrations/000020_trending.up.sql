@@ -0,0 +1,22 @@
+-- Videos deliberately added to Watch Later or a playlist, for trending.
+-- Kept for the longest trending window.
+CREATE TABLE IF NOT EXISTS video_adds (
+    id BIGSERIAL PRIMARY KEY,
+    floatplane_user_id TEXT NOT NULL REFERENCES users(floatplane_user_id) ON DELETE CASCADE,
+    video_id TEXT NOT NULL,
+    added_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_video_adds_added_at ON video_adds(added_at);
+
+-- Most added posts per window from the last job run. Counts only, no users.
+CREATE TABLE IF NOT EXISTS trending (
+    time_window TEXT NOT NULL, -- 24h, 7d
+    video_id TEXT NOT NULL,
+    users INT NOT NULL,
+    rank INT NOT NULL,
+    computed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (time_window, video_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_trending_window_rank ON trending(time_window, rank);
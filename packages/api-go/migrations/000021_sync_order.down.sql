@@ -0,0 +1,11 @@
+CREATE OR REPLACE FUNCTION bump_playlist_sync_version() RETURNS trigger AS $$
+BEGIN
+    NEW.sync_version := nextval('playlist_sync_seq');
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP INDEX IF EXISTS idx_sync_tombstones_user_xid;
+DROP INDEX IF EXISTS idx_playlists_sync_xid;
+ALTER TABLE sync_tombstones DROP COLUMN IF EXISTS sync_xid;
+ALTER TABLE playlists DROP COLUMN IF EXISTS sync_xid;
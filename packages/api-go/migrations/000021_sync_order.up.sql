@@ -0,0 +1,27 @@
+-- Delta sync cursors must never skip a change. sync_version comes from a
+-- sequence when a row is written, not when its transaction commits, so a
+-- long transaction could commit a lower version after a client had already
+-- been given a cursor past it. Each row also records the transaction that
+-- wrote it, and GET /sync only hands out rows from transactions older than
+-- every one still running (the snapshot xmin), in (sync_xid, sync_version)
+-- order. Anything committed later has a sync_xid at or past the cursor.
+
+-- Earlier versions serialized all writers on an advisory lock instead.
+DROP TRIGGER IF EXISTS playlists_sync_lock ON playlists;
+DROP TRIGGER IF EXISTS playlist_members_sync_lock ON playlist_members;
+DROP TRIGGER IF EXISTS user_events_order_lock ON user_events;
+DROP FUNCTION IF EXISTS lock_playlist_sync();
+
+ALTER TABLE playlists ADD COLUMN IF NOT EXISTS sync_xid xid8 NOT NULL DEFAULT pg_current_xact_id();
+ALTER TABLE sync_tombstones ADD COLUMN IF NOT EXISTS sync_xid xid8 NOT NULL DEFAULT pg_current_xact_id();
+
+CREATE INDEX IF NOT EXISTS idx_playlists_sync_xid ON playlists(sync_xid, sync_version);
+CREATE INDEX IF NOT EXISTS idx_sync_tombstones_user_xid ON sync_tombstones(floatplane_user_id, sync_xid, sync_version);
+
+CREATE OR REPLACE FUNCTION bump_playlist_sync_version() RETURNS trigger AS $$
+BEGIN
+    NEW.sync_version := nextval('playlist_sync_seq');
+    NEW.sync_xid := pg_current_xact_id();
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_user_events_xid;
+ALTER TABLE user_events DROP COLUMN IF EXISTS xid;
@@ -0,0 +1,13 @@
+-- Event IDs come from a sequence when an event is inserted, not when its
+-- transaction commits, so a device that saw a later event could skip an
+-- earlier one committed after it. Each event records the transaction that
+-- published it, and events only go out in (xid, id) order once every
+-- transaction that could still commit an earlier one has ended, see
+-- services/events.go.
+
+-- Earlier versions serialized publishers on the playlist sync lock instead.
+DROP TRIGGER IF EXISTS user_events_order_lock ON user_events;
+
+ALTER TABLE user_events ADD COLUMN IF NOT EXISTS xid xid8 NOT NULL DEFAULT pg_current_xact_id();
+
+CREATE INDEX IF NOT EXISTS idx_user_events_xid ON user_events(xid, id);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_playlist_shares_active;
@@ -0,0 +1,14 @@
+-- A playlist has at most one active share link. Creating one used to check
+-- for an existing link and then insert, so two concurrent requests could both
+-- create one; the index lets the insert detect that itself.
+
+-- Keep only the newest active link of any playlist that already has several.
+UPDATE playlist_shares s SET revoked_at = NOW()
+WHERE s.revoked_at IS NULL AND EXISTS (
+    SELECT 1 FROM playlist_shares n
+    WHERE n.playlist_id = s.playlist_id AND n.revoked_at IS NULL
+      AND (n.created_at, n.token) > (s.created_at, s.token)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_playlist_shares_active
+    ON playlist_shares(playlist_id) WHERE revoked_at IS NULL;
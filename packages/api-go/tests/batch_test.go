@@ -0,0 +1,71 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type batchResponse struct {
+	Committed   bool `json:"committed"`
+	FailedIndex *int `json:"failed_index"`
+	Results     []struct {
+		Status string `json:"status"`
+		Code   int    `json:"code"`
+	} `json:"results"`
+}
+
+func TestBatchMoveFromWatchLater(t *testing.T) {
+	clearDatabase(t)
+	r := setupRouter()
+	apiKey := createTestUser(t)
+
+	doRequest(r, "PATCH", "/watch-later/add", apiKey, map[string]string{"video_id": "vid1"})
+	w := doRequest(r, "POST", "/playlists", apiKey, map[string]interface{}{"name": "Target"})
+	var target map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &target)
+	targetID := target["id"].(string)
+
+	move := func(playlistID string) *batchResponse {
+		w := doRequest(r, "POST", "/batch", apiKey, map[string]interface{}{
+			"operations": []map[string]interface{}{
+				{"type": "watch_later_remove", "data": map[string]string{"video_id": "vid1"}},
+				{"type": "add_video", "playlist_id": playlistID, "data": map[string]string{"video_id": "vid1"}},
+			},
+		})
+		var resp batchResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return &resp
+	}
+
+	// 1. A failing step rolls back the whole batch
+	resp := move("00000000-0000-0000-0000-000000000000")
+	assert.False(t, resp.Committed)
+	if assert.NotNil(t, resp.FailedIndex) {
+		assert.Equal(t, 1, *resp.FailedIndex)
+	}
+	assert.Equal(t, "rolled_back", resp.Results[0].Status)
+	assert.Equal(t, http.StatusNotFound, resp.Results[1].Code)
+
+	w = doRequest(r, "GET", "/watch-later", apiKey, nil)
+	assert.Contains(t, w.Body.String(), "vid1")
+
+	// 2. Both steps succeed together
+	resp = move(targetID)
+	assert.True(t, resp.Committed)
+	assert.Equal(t, "applied", resp.Results[0].Status)
+	assert.Equal(t, "applied", resp.Results[1].Status)
+
+	w = doRequest(r, "GET", "/watch-later", apiKey, nil)
+	assert.NotContains(t, w.Body.String(), "vid1")
+	w = doRequest(r, "GET", "/playlists", apiKey, nil)
+	assert.Contains(t, w.Body.String(), "vid1")
+
+	// Unknown operation types are rejected before anything runs
+	w = doRequest(r, "POST", "/batch", apiKey, map[string]interface{}{
+		"operations": []map[string]interface{}{{"type": "explode"}},
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,106 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/database"
+	"github.com/stretchr/testify/assert"
+)
+
+type bookmarkResponse struct {
+	ID               int64   `json:"id"`
+	VideoID          string  `json:"video_id"`
+	TimestampSeconds float64 `json:"timestamp_seconds"`
+	Label            string  `json:"label"`
+	Note             string  `json:"note"`
+	Title            string  `json:"title"`
+	ThumbnailURL     string  `json:"thumbnail_url"`
+}
+
+func TestBookmarks(t *testing.T) {
+	clearDatabase(t)
+	r := setupRouter()
+	apiKey := createTestUser(t)
+
+	_, err := database.Pool.Exec(context.Background(), `
+		INSERT INTO fp_posts (id, title, creator_id, creator_name, channel_id, channel_title, channel_icon_url, thumbnail_url, release_date)
+		VALUES ('bmPost', 'GPU Review', 'bm_creator', '', '', '', '', 'https://example.com/thumb.jpg', NOW())
+		ON CONFLICT (id) DO NOTHING
+	`)
+	assert.NoError(t, err)
+
+	create := func(video string, at float64, label string) bookmarkResponse {
+		w := doRequest(r, "POST", "/bookmarks", apiKey, map[string]interface{}{"video_id": video, "timestamp_seconds": at, "label": label})
+		assert.Equal(t, http.StatusCreated, w.Code)
+		var b bookmarkResponse
+		json.Unmarshal(w.Body.Bytes(), &b)
+		return b
+	}
+	list := func(query string) []bookmarkResponse {
+		w := doRequest(r, "GET", "/bookmarks"+query, apiKey, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp struct {
+			Bookmarks []bookmarkResponse `json:"bookmarks"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp.Bookmarks
+	}
+
+	// 1. Bookmarks are enriched from fp_posts
+	benchmarks := create("bmPost", 754, "GPU benchmarks")
+	assert.Equal(t, "GPU Review", benchmarks.Title)
+	assert.Equal(t, "https://example.com/thumb.jpg", benchmarks.ThumbnailURL)
+	create("bmPost", 60, "Intro")
+	other := create("bmOther", 30, "Elsewhere")
+	assert.Empty(t, other.Title)
+
+	// 2. Filtering by post orders by timestamp, otherwise newest first
+	byPost := list("?video_id=bmPost")
+	if assert.Len(t, byPost, 2) {
+		assert.Equal(t, "Intro", byPost[0].Label)
+		assert.Equal(t, "GPU benchmarks", byPost[1].Label)
+	}
+	all := list("")
+	if assert.Len(t, all, 3) {
+		assert.Equal(t, "Elsewhere", all[0].Label)
+	}
+
+	// 3. Filtering by playlist
+	playlist := createPlaylistWithVideos(t, r, apiKey, "Bookmarked", []string{"bmOther"})
+	byPlaylist := list("?playlist_id=" + playlist)
+	if assert.Len(t, byPlaylist, 1) {
+		assert.Equal(t, other.ID, byPlaylist[0].ID)
+	}
+	w := doRequest(r, "GET", "/bookmarks?playlist_id=00000000-0000-0000-0000-000000000000", apiKey, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// 4. Update and delete
+	path := "/bookmarks/" + strconv.FormatInt(benchmarks.ID, 10)
+	w = doRequest(r, "PATCH", path, apiKey, map[string]interface{}{"note": "3DMark results"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	var updated bookmarkResponse
+	json.Unmarshal(w.Body.Bytes(), &updated)
+	assert.Equal(t, "3DMark results", updated.Note)
+	assert.Equal(t, "GPU benchmarks", updated.Label)
+	assert.Equal(t, 754.0, updated.TimestampSeconds)
+
+	w = doRequest(r, "PATCH", path, apiKey, map[string]interface{}{"timestamp_seconds": -5})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	setLimit(t, "MAX_BOOKMARK_NOTE_LENGTH", "10")
+	w = doRequest(r, "PATCH", path, apiKey, map[string]interface{}{"note": "Longer than ten"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	var quota map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &quota)
+	assert.Equal(t, "note_length", quota["quota"])
+	assert.Equal(t, float64(10), quota["limit"])
+
+	w = doRequest(r, "DELETE", path, apiKey, nil)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	w = doRequest(r, "DELETE", path, apiKey, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
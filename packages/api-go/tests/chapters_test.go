@@ -0,0 +1,115 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/database"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/models"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/services"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseChapters(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		duration int
+		want     []models.Chapter
+	}{
+		{
+			name: "m:ss lines",
+			text: "0:00 Intro\n1:35 Benchmarks\n12:03 Conclusion",
+			want: []models.Chapter{
+				{StartSeconds: 0, EndSeconds: 95, Title: "Intro"},
+				{StartSeconds: 95, EndSeconds: 723, Title: "Benchmarks"},
+				{StartSeconds: 723, Title: "Conclusion"},
+			},
+		},
+		{
+			name:     "hh:mm:ss with separators and duration",
+			text:     "00:00:00 - Intro\n00:04:10 – Topic one\n01:02:03 | Merch Messages",
+			duration: 4000,
+			want: []models.Chapter{
+				{StartSeconds: 0, EndSeconds: 250, Title: "Intro"},
+				{StartSeconds: 250, EndSeconds: 3723, Title: "Topic one"},
+				{StartSeconds: 3723, EndSeconds: 4000, Title: "Merch Messages"},
+			},
+		},
+		{
+			name: "HTML with a Timestamps header",
+			text: `<p>Check out <a href="https://example.com">our sponsor</a> at 5:00 off.</p>` +
+				`<p><strong>Timestamps:</strong></p><p>0:00 Intro<br />1:00 Unboxing &amp; setup<br/>[2:30] Gaming</p>` +
+				`<p>Purchases made through some links may earn us a commission</p><p>9:99 Not a chapter</p>`,
+			want: []models.Chapter{
+				{StartSeconds: 0, EndSeconds: 60, Title: "Intro"},
+				{StartSeconds: 60, EndSeconds: 150, Title: "Unboxing & setup"},
+				{StartSeconds: 150, Title: "Gaming"},
+			},
+		},
+		{
+			name: "header names the list to use",
+			text: "Watch from 1:00 onwards\n2:00 for the good part\n\nTIMESTAMPS (may be approximate)\n0:00 Intro\n3:00 Outro",
+			want: []models.Chapter{
+				{StartSeconds: 0, EndSeconds: 180, Title: "Intro"},
+				{StartSeconds: 180, Title: "Outro"},
+			},
+		},
+		{
+			name: "blank lines, non-breaking spaces and backwards entries",
+			text: "Chapters:\n\n0:00 Intro\n\n0:45\u00a0Part one\n0:30 Typo\n1:10 Part two",
+			want: []models.Chapter{
+				{StartSeconds: 0, EndSeconds: 45, Title: "Intro"},
+				{StartSeconds: 45, EndSeconds: 70, Title: "Part one"},
+				{StartSeconds: 70, Title: "Part two"},
+			},
+		},
+		{
+			name: "invalid times end the list",
+			text: "0:00 Intro\n1:15 Start\n1:75 Broken",
+			want: []models.Chapter{
+				{StartSeconds: 0, EndSeconds: 75, Title: "Intro"},
+				{StartSeconds: 75, Title: "Start"},
+			},
+		},
+		{name: "single timestamp", text: "Skip to 4:20\n4:20 The good part"},
+		{name: "no timestamps", text: "<p>Just a description.</p>"},
+		{name: "empty", text: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, services.ParseChapters(tt.text, tt.duration))
+		})
+	}
+}
+
+func TestGetPostChapters(t *testing.T) {
+	clearDatabase(t)
+	r := setupRouter()
+	apiKey := createTestUser(t)
+
+	_, err := database.Pool.Exec(context.Background(), `
+		INSERT INTO fp_posts (id, title, creator_id, creator_name, channel_id, channel_title, channel_icon_url, thumbnail_url, video_duration, release_date, text)
+		VALUES ('chPost', 'Chapters', 'ch_creator', '', '', '', '', '', 600, NOW(), '<p>Timestamps:</p><p>0:00 Intro<br>2:00 Review</p>')
+		ON CONFLICT (id) DO UPDATE SET text = EXCLUDED.text, video_duration = EXCLUDED.video_duration
+	`)
+	assert.NoError(t, err)
+
+	w := doRequest(r, "GET", "/posts/chPost/chapters", apiKey, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		PostID   string           `json:"post_id"`
+		Chapters []models.Chapter `json:"chapters"`
+		Count    int              `json:"count"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.Equal(t, "chPost", resp.PostID)
+	assert.Equal(t, 2, resp.Count)
+	assert.Equal(t, models.Chapter{StartSeconds: 120, EndSeconds: 600, Title: "Review"}, resp.Chapters[1])
+
+	w = doRequest(r, "GET", "/posts/chMissing/chapters", apiKey, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
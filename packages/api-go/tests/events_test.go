@@ -0,0 +1,234 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/database"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/services"
+	"github.com/stretchr/testify/assert"
+)
+
+type streamedEvent struct {
+	ID   string
+	Type string
+	Data struct {
+		Data map[string]interface{} `json:"data"`
+	}
+}
+
+// openEventStream connects to /events over SSE and streams what it receives.
+func openEventStream(t *testing.T, baseURL, apiKey, lastEventID string) (<-chan streamedEvent, func()) {
+	req, _ := http.NewRequest("GET", baseURL+"/events", nil)
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to open event stream: %v", err)
+	}
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	events := make(chan streamedEvent, 16)
+	go func() {
+		defer close(events)
+		scanner := bufio.NewScanner(resp.Body)
+		var e streamedEvent
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case line == "":
+				if e.Type != "" {
+					events <- e
+				}
+				e = streamedEvent{}
+			case strings.HasPrefix(line, "id: "):
+				e.ID = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				e.Type = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &e.Data)
+			}
+		}
+	}()
+	return events, func() { resp.Body.Close() }
+}
+
+func nextEvent(t *testing.T, events <-chan streamedEvent) streamedEvent {
+	select {
+	case e, ok := <-events:
+		if !ok {
+			t.Fatal("Event stream closed")
+		}
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for an event")
+	}
+	return streamedEvent{}
+}
+
+func TestEventStream(t *testing.T) {
+	clearDatabase(t)
+	r := setupRouter()
+	phoneKey := createTestUser(t)
+	userID := fmt.Sprintf("test_user_%d", os.Getpid())
+	tvKey := "test_api_key_tv_" + userID
+	_, err := database.Pool.Exec(context.Background(), `
+		INSERT INTO device_sessions (id, api_key, floatplane_user_id, device_info, dpop_jkt, last_accessed_at, created_at)
+		VALUES ($1, $2, $3, 'TV', $4, NOW(), NOW())
+	`, "sess_tv_"+userID, tvKey, userID, "test_jkt_tv_"+userID)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go services.ListenEvents(ctx)
+	for deadline := time.Now().Add(5 * time.Second); !services.Events.Listening(); {
+		if time.Now().After(deadline) {
+			t.Fatal("Event listener didn't start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	// 1. A change on the phone reaches the TV
+	events, closeStream := openEventStream(t, srv.URL, tvKey, "")
+	doRequest(r, "PATCH", "/watch-later/add", phoneKey, map[string]string{"video_id": "evVid1"})
+	e := nextEvent(t, events)
+	assert.Equal(t, "watch_later.changed", e.Type)
+	assert.Equal(t, []interface{}{"evVid1"}, e.Data.Data["video_ids"])
+
+	// 2. The TV doesn't get its own changes back
+	doRequest(r, "PUT", "/progress/evVid1", tvKey, map[string]interface{}{"position_seconds": 10})
+	doRequest(r, "PUT", "/progress/evVid1", phoneKey, map[string]interface{}{"position_seconds": 20})
+	e = nextEvent(t, events)
+	assert.Equal(t, "progress.updated", e.Type)
+	assert.Equal(t, 20.0, e.Data.Data["position_seconds"])
+	closeStream()
+
+	// 3. Reconnecting with Last-Event-ID replays what was missed
+	doRequest(r, "POST", "/playlists", phoneKey, map[string]interface{}{"name": "Made While Away", "video_ids": []string{}})
+	events, closeStream = openEventStream(t, srv.URL, tvKey, e.ID)
+	e = nextEvent(t, events)
+	assert.Equal(t, "playlist.changed", e.Type)
+	assert.Equal(t, "Made While Away", e.Data.Data["name"])
+	closeStream()
+
+	events, closeStream = openEventStream(t, srv.URL, tvKey, "999999999")
+	assert.Equal(t, "resync", nextEvent(t, events).Type)
+	closeStream()
+
+	// 4. Over a WebSocket, logging out the phone is pushed to the TV
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+	fmt.Fprintf(conn, "GET /events HTTP/1.1\r\nHost: test\r\nAuthorization: Bearer %s\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n", tvKey)
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatalf("Failed to read handshake: %v", err)
+	}
+	assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+	assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", resp.Header.Get("Sec-WebSocket-Accept"))
+
+	doRequest(r, "POST", "/auth/logout", phoneKey, nil)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var header [2]byte
+	_, err = io.ReadFull(br, header[:])
+	assert.NoError(t, err)
+	assert.Equal(t, byte(0x81), header[0]) // Final text frame
+	n := int(header[1] & 0x7F)
+	if n == 126 {
+		var ext [2]byte
+		io.ReadFull(br, ext[:])
+		n = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload := make([]byte, n)
+	_, err = io.ReadFull(br, payload)
+	assert.NoError(t, err)
+	var msg struct {
+		Type string            `json:"type"`
+		Data map[string]string `json:"data"`
+	}
+	json.Unmarshal(payload, &msg)
+	assert.Equal(t, "session.revoked", msg.Type)
+	assert.Equal(t, "sess_"+userID, msg.Data["session_id"])
+}
+
+func TestEventsFollowCommitOrder(t *testing.T) {
+	clearDatabase(t)
+	createTestUser(t)
+	userID := fmt.Sprintf("test_user_%d", os.Getpid())
+	ctx := context.Background()
+
+	assert.NoError(t, services.PublishEvent(ctx, userID, "", "test.first", map[string]string{}))
+	var firstID int64
+	database.Pool.QueryRow(ctx, `SELECT id FROM user_events WHERE floatplane_user_id = $1`, userID).Scan(&firstID)
+	cursor, ok, err := services.EventCursorAt(ctx, userID, firstID)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	// An event published in a long transaction...
+	tx, err := database.Pool.Begin(ctx)
+	assert.NoError(t, err)
+	defer tx.Rollback(ctx)
+	assert.NoError(t, services.PublishEvent(database.WithTx(ctx, tx), userID, "", "test.slow", map[string]string{}))
+
+	// ...doesn't block later ones, but holds them back until it ends
+	assert.NoError(t, services.PublishEvent(ctx, userID, "", "test.fast", map[string]string{}))
+	events, ok, err := services.EventsSince(ctx, userID, cursor)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Empty(t, events)
+
+	assert.NoError(t, tx.Commit(ctx))
+	events, ok, err = services.EventsSince(ctx, userID, cursor)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	var types []string
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+	assert.Equal(t, []string{"test.slow", "test.fast"}, types)
+
+	_, ok, err = services.EventCursorAt(ctx, userID, 999999999)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestBestEffortWritesKeepTransaction(t *testing.T) {
+	clearDatabase(t)
+	apiKey := createTestUser(t)
+	ctx := context.Background()
+
+	tx, err := database.Pool.Begin(ctx)
+	assert.NoError(t, err)
+	defer tx.Rollback(ctx)
+	txCtx := database.WithTx(ctx, tx)
+
+	// A failed best effort write doesn't abort the transaction around it
+	err = database.Savepoint(txCtx, func(ctx context.Context) error {
+		_, err := database.Conn(ctx).Exec(ctx, `INSERT INTO no_such_table VALUES (1)`)
+		return err
+	})
+	assert.Error(t, err)
+	var n int
+	assert.NoError(t, database.Conn(txCtx).QueryRow(txCtx, `SELECT COUNT(*) FROM device_sessions WHERE api_key = $1`, apiKey).Scan(&n))
+	assert.Equal(t, 1, n)
+	assert.NoError(t, tx.Commit(ctx))
+}
@@ -0,0 +1,98 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlaylistImportExport(t *testing.T) {
+	clearDatabase(t)
+	r := setupRouter()
+	apiKey := createTestUser(t)
+
+	// 1. Import a plain URL list with a duplicate and a bad line
+	body := strings.Join([]string{
+		"https://www.floatplane.com/post/AbC123xyzW",
+		"floatplane.com/post/Def456uvwX?foo=bar",
+		"https://www.floatplane.com/post/AbC123xyzW",
+		"not a url",
+	}, "\n")
+	req, _ := http.NewRequest("POST", "/playlists/import?name=Backup", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var imported struct {
+		Playlist struct {
+			ID       string   `json:"id"`
+			Name     string   `json:"name"`
+			VideoIDs []string `json:"video_ids"`
+		} `json:"playlist"`
+		Imported   int `json:"imported"`
+		Duplicates int `json:"duplicates"`
+		Unresolved []struct {
+			Line int `json:"line"`
+		} `json:"unresolved"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &imported)
+	assert.Equal(t, "Backup", imported.Playlist.Name)
+	assert.Equal(t, []string{"AbC123xyzW", "Def456uvwX"}, imported.Playlist.VideoIDs)
+	assert.Equal(t, 1, imported.Duplicates)
+	assert.Len(t, imported.Unresolved, 1)
+	assert.Equal(t, 4, imported.Unresolved[0].Line)
+
+	// 2. Export as CSV and M3U
+	w = doRequest(r, "GET", "/playlists/"+imported.Playlist.ID+"/export?format=csv", apiKey, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "video_id,title,url")
+	assert.Contains(t, w.Body.String(), "https://www.floatplane.com/post/AbC123xyzW")
+
+	w = doRequest(r, "GET", "/playlists/"+imported.Playlist.ID+"/export?format=m3u", apiKey, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, strings.HasPrefix(w.Body.String(), "#EXTM3U"))
+
+	// 3. Round-trip the JSON export
+	w = doRequest(r, "GET", "/playlists/"+imported.Playlist.ID+"/export?format=json", apiKey, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	req, _ = http.NewRequest("POST", "/playlists/import", strings.NewReader(w.Body.String()))
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	json.Unmarshal(w.Body.Bytes(), &imported)
+	assert.Equal(t, "Backup", imported.Playlist.Name)
+	assert.Equal(t, 2, imported.Imported)
+}
+
+func TestImportTruncatesUnresolvedLines(t *testing.T) {
+	clearDatabase(t)
+	r := setupRouter()
+	apiKey := createTestUser(t)
+
+	// A long line of multi-byte characters is cut at a character boundary
+	body := "https://www.floatplane.com/post/AbC123xyzW\n" + strings.Repeat("é", 300)
+	req, _ := http.NewRequest("POST", "/playlists/import?format=text", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var imported struct {
+		Unresolved []struct {
+			Line  int    `json:"line"`
+			Value string `json:"value"`
+		} `json:"unresolved"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &imported)
+	if assert.Len(t, imported.Unresolved, 1) {
+		assert.Equal(t, 2, imported.Unresolved[0].Line)
+		assert.Equal(t, strings.Repeat("é", 200), imported.Unresolved[0].Value)
+	}
+}
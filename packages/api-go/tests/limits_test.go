@@ -0,0 +1,111 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/services"
+	"github.com/stretchr/testify/assert"
+)
+
+// setLimit overrides a quota until the end of the test. The quotas are
+// cached, so they are reloaded after setting and after restoring the env.
+func setLimit(t *testing.T, name, value string) {
+	t.Cleanup(func() { services.ReloadLimits() })
+	t.Setenv(name, value)
+	services.ReloadLimits()
+}
+
+func TestPlaylistQuotas(t *testing.T) {
+	clearDatabase(t)
+	r := setupRouter()
+	apiKey := createTestUser(t)
+	setLimit(t, "MAX_PLAYLISTS_PER_USER", "2")
+	setLimit(t, "MAX_ITEMS_PER_PLAYLIST", "3")
+
+	id := createPlaylistWithVideos(t, r, apiKey, "One", []string{"vid1", "vid2"})
+	createPlaylistWithVideos(t, r, apiKey, "Two", nil)
+
+	assertQuotaExceeded := func(w *http.Response, quota string) {
+		t.Helper()
+		assert.Equal(t, http.StatusForbidden, w.StatusCode)
+		var body map[string]interface{}
+		json.NewDecoder(w.Body).Decode(&body)
+		assert.Equal(t, "quota_exceeded", body["error"])
+		assert.Equal(t, quota, body["quota"])
+	}
+
+	// 1. Playlist count
+	w := doRequest(r, "POST", "/playlists", apiKey, map[string]string{"name": "Three"})
+	assertQuotaExceeded(w.Result(), "playlists")
+	w = doRequest(r, "POST", "/playlists/"+id+"/duplicate", apiKey, nil)
+	assertQuotaExceeded(w.Result(), "playlists")
+
+	// 2. Items per playlist, including Watch Later
+	w = doRequest(r, "PATCH", "/playlists/"+id+"/add", apiKey, map[string]string{"video_id": "vid3"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = doRequest(r, "PATCH", "/playlists/"+id+"/add", apiKey, map[string]string{"video_id": "vid4"})
+	assertQuotaExceeded(w.Result(), "items_per_playlist")
+	w = doRequest(r, "PUT", "/watch-later", apiKey, map[string]interface{}{"video_ids": []string{"a", "b", "c", "d"}})
+	assertQuotaExceeded(w.Result(), "items_per_playlist")
+
+	// 3. Usage
+	w = doRequest(r, "GET", "/account/limits", apiKey, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Limits struct {
+			MaxPlaylists        int `json:"max_playlists"`
+			MaxItemsPerPlaylist int `json:"max_items_per_playlist"`
+		} `json:"limits"`
+		Usage struct {
+			Playlists            int `json:"playlists"`
+			LargestPlaylistItems int `json:"largest_playlist_items"`
+		} `json:"usage"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.Equal(t, 2, resp.Limits.MaxPlaylists)
+	assert.Equal(t, 3, resp.Limits.MaxItemsPerPlaylist)
+	assert.Equal(t, 2, resp.Usage.Playlists)
+	assert.Equal(t, 3, resp.Usage.LargestPlaylistItems)
+
+	// Trashed playlists free up room
+	doRequest(r, "DELETE", "/playlists/"+id, apiKey, nil)
+	w = doRequest(r, "POST", "/playlists", apiKey, map[string]string{"name": "Three"})
+	assert.Equal(t, http.StatusCreated, w.Code)
+}
+
+func TestPlaylistQuotaConcurrentCreates(t *testing.T) {
+	clearDatabase(t)
+	r := setupRouter()
+	apiKey := createTestUser(t)
+	setLimit(t, "MAX_PLAYLISTS_PER_USER", "3")
+
+	codes := make([]int, 8)
+	var wg sync.WaitGroup
+	for i := range codes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w := doRequest(r, "POST", "/playlists", apiKey, map[string]string{"name": "Racing"})
+			codes[i] = w.Code
+		}(i)
+	}
+	wg.Wait()
+
+	created := 0
+	for _, code := range codes {
+		if code == http.StatusCreated {
+			created++
+		} else {
+			assert.Equal(t, http.StatusForbidden, code)
+		}
+	}
+	assert.Equal(t, 3, created)
+
+	w := doRequest(r, "GET", "/playlists", apiKey, nil)
+	var list map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &list)
+	assert.Equal(t, float64(3), list["count"])
+}
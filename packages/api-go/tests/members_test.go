@@ -0,0 +1,105 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollaborativePlaylist(t *testing.T) {
+	clearDatabase(t)
+	r := setupRouter()
+	ownerKey := createNamedTestUser(t, "owner_user")
+	friendKey := createNamedTestUser(t, "friend_user")
+
+	// 1. Owner creates a playlist
+	w := doRequest(r, "POST", "/playlists", ownerKey, map[string]interface{}{
+		"name":      "Movie Night",
+		"video_ids": []string{"vid1"},
+	})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var created map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	playlistID := created["id"].(string)
+
+	// Friend has no access yet
+	w = doRequest(r, "PATCH", "/playlists/"+playlistID+"/add", friendKey, map[string]string{"video_id": "vid2"})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// 2. Owner invites as editor, friend joins
+	w = doRequest(r, "POST", "/playlists/"+playlistID+"/invites", ownerKey, map[string]string{"role": "editor"})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var invite map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &invite)
+
+	w = doRequest(r, "POST", "/playlists/join", friendKey, map[string]interface{}{"code": invite["code"]})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// 3. Friend sees the playlist and can add to it
+	w = doRequest(r, "GET", "/playlists", friendKey, nil)
+	assert.Contains(t, w.Body.String(), "Movie Night")
+	assert.Contains(t, w.Body.String(), `"role":"editor"`)
+
+	w = doRequest(r, "PATCH", "/playlists/"+playlistID+"/add", friendKey, map[string]string{"video_id": "vid2"})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Editors can't delete
+	w = doRequest(r, "DELETE", "/playlists/"+playlistID, friendKey, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// 4. Items are attributed to whoever added them
+	w = doRequest(r, "GET", "/playlists/"+playlistID+"/items", ownerKey, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var itemsResp struct {
+		Items []struct {
+			VideoID string `json:"video_id"`
+			AddedBy string `json:"added_by"`
+		} `json:"items"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &itemsResp)
+	assert.Len(t, itemsResp.Items, 2)
+	assert.Equal(t, "owner_user", itemsResp.Items[0].AddedBy)
+	assert.Equal(t, "friend_user", itemsResp.Items[1].AddedBy)
+
+	// 5. Downgrade friend to viewer; edits are now refused
+	w = doRequest(r, "PATCH", "/playlists/"+playlistID+"/members/friend_user", ownerKey, map[string]string{"role": "viewer"})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = doRequest(r, "PATCH", "/playlists/"+playlistID+"/remove", friendKey, map[string]string{"video_id": "vid1"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// 6. Friend leaves
+	w = doRequest(r, "DELETE", "/playlists/"+playlistID+"/members/friend_user", friendKey, nil)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	w = doRequest(r, "GET", "/playlists/"+playlistID+"/members", friendKey, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestRevokePlaylistInvite(t *testing.T) {
+	clearDatabase(t)
+	r := setupRouter()
+	ownerKey := createNamedTestUser(t, "owner_user")
+	friendKey := createNamedTestUser(t, "friend_user")
+	playlistID := createPlaylistWithVideos(t, r, ownerKey, "Invite Only", []string{"vid1"})
+
+	w := doRequest(r, "POST", "/playlists/"+playlistID+"/invites", ownerKey, map[string]string{"role": "viewer"})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var invite map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &invite)
+	code := invite["code"].(string)
+	assert.Len(t, code, 64)
+
+	// Only the owner can revoke
+	w = doRequest(r, "DELETE", "/playlists/"+playlistID+"/invites/"+code, friendKey, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = doRequest(r, "DELETE", "/playlists/"+playlistID+"/invites/"+code, ownerKey, nil)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	w = doRequest(r, "DELETE", "/playlists/"+playlistID+"/invites/"+code, ownerKey, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = doRequest(r, "POST", "/playlists/join", friendKey, map[string]interface{}{"code": code})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
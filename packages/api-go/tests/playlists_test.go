@@ -2,11 +2,15 @@ package tests
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"sync"
 	"testing"
 
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/database"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -74,3 +78,228 @@ func TestPlaylistsCRUD(t *testing.T) {
 	json.Unmarshal(w.Body.Bytes(), &listResp)
 	assert.Equal(t, float64(0), listResp["count"])
 }
+
+func TestPlaylistsPagination(t *testing.T) {
+	clearDatabase(t)
+	r := setupRouter()
+	apiKey := createTestUser(t)
+
+	for _, name := range []string{"Charlie", "alpha", "Bravo", "Delta", "Echo"} {
+		w := doRequest(r, "POST", "/playlists", apiKey, map[string]interface{}{
+			"name":      name,
+			"video_ids": []string{"vid1"},
+		})
+		assert.Equal(t, http.StatusCreated, w.Code)
+	}
+
+	type page struct {
+		Playlists  []map[string]interface{} `json:"playlists"`
+		Count      int                      `json:"count"`
+		NextCursor string                   `json:"next_cursor"`
+	}
+
+	// Walk pages of 2 sorted by name
+	var names []string
+	cursor := ""
+	for i := 0; i < 5; i++ {
+		path := "/playlists?sort=name&limit=2&include_videos=false"
+		if cursor != "" {
+			path += "&cursor=" + cursor
+		}
+		w := doRequest(r, "GET", path, apiKey, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var p page
+		json.Unmarshal(w.Body.Bytes(), &p)
+		for _, pl := range p.Playlists {
+			names = append(names, pl["name"].(string))
+			assert.NotContains(t, pl, "video_ids")
+			assert.Equal(t, float64(1), pl["item_count"])
+		}
+		cursor = p.NextCursor
+		if cursor == "" {
+			break
+		}
+	}
+	assert.Equal(t, []string{"alpha", "Bravo", "Charlie", "Delta", "Echo"}, names)
+
+	// Name filter
+	w := doRequest(r, "GET", "/playlists?q=CHO", apiKey, nil)
+	var p page
+	json.Unmarshal(w.Body.Bytes(), &p)
+	assert.Equal(t, 1, p.Count)
+	assert.Equal(t, "Echo", p.Playlists[0]["name"])
+
+	// Bad sort
+	w = doRequest(r, "GET", "/playlists?sort=bogus", apiKey, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestPlaylistTrash(t *testing.T) {
+	clearDatabase(t)
+	r := setupRouter()
+	apiKey := createTestUser(t)
+
+	w := doRequest(r, "POST", "/playlists", apiKey, map[string]interface{}{"name": "Curated"})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var created map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	playlistID := created["id"].(string)
+
+	// 1. Delete moves it to the trash
+	w = doRequest(r, "DELETE", "/playlists/"+playlistID, apiKey, nil)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	w = doRequest(r, "GET", "/playlists", apiKey, nil)
+	assert.NotContains(t, w.Body.String(), "Curated")
+
+	w = doRequest(r, "GET", "/playlists/trash", apiKey, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Curated")
+	assert.Contains(t, w.Body.String(), "purge_at")
+
+	// Trashed playlists can't be edited
+	w = doRequest(r, "PATCH", "/playlists/"+playlistID+"/add", apiKey, map[string]string{"video_id": "vid1"})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// 2. Restore brings it back
+	w = doRequest(r, "POST", "/playlists/"+playlistID+"/restore", apiKey, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = doRequest(r, "GET", "/playlists", apiKey, nil)
+	assert.Contains(t, w.Body.String(), "Curated")
+
+	w = doRequest(r, "POST", "/playlists/"+playlistID+"/restore", apiKey, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestPlaylistAttributes(t *testing.T) {
+	clearDatabase(t)
+	r := setupRouter()
+	apiKey := createTestUser(t)
+
+	_, err := database.Pool.Exec(context.Background(), `
+		INSERT INTO fp_posts (id, title, creator_id, thumbnail_url) VALUES ('coverPost1', 'Cover', 'cover_creator', 'https://pbs.floatplane.com/cover.jpg')
+		ON CONFLICT (id) DO NOTHING
+	`)
+	assert.NoError(t, err)
+
+	// 1. Create with attributes; the cover can be given as a URL
+	w := doRequest(r, "POST", "/playlists", apiKey, map[string]interface{}{
+		"name":           "Shelf",
+		"description":    "Best of",
+		"cover_video_id": "https://www.floatplane.com/post/coverPost1",
+		"color":          "#AABBCC",
+		"emoji":          "🎬",
+	})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var created map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	assert.Equal(t, "Best of", created["description"])
+	assert.Equal(t, "coverPost1", created["cover_video_id"])
+	assert.Equal(t, "https://pbs.floatplane.com/cover.jpg", created["cover_thumbnail_url"])
+	assert.Equal(t, "#aabbcc", created["color"])
+	shelfID := created["id"].(string)
+
+	// 2. Validation
+	for _, body := range []map[string]interface{}{
+		{"name": "Bad", "color": "red"},
+		{"name": "Bad", "emoji": "abc"},
+		{"name": "Bad", "emoji": "12"},
+		{"name": "Bad", "emoji": "#"},
+		{"name": "Bad", "emoji": "日本"},
+		{"name": "Bad", "emoji": "🎬🎬"},
+		{"name": "Bad", "cover_video_id": "https://example.com/nope"},
+	} {
+		w = doRequest(r, "POST", "/playlists", apiKey, body)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	}
+
+	for _, emoji := range []string{"1\ufe0f\u20e3", "\U0001f1ec\U0001f1e7", "\U0001f44d\U0001f3fd", "\U0001f468\u200d\U0001f469\u200d\U0001f467"} {
+		w = doRequest(r, "PUT", "/playlists/"+shelfID, apiKey, map[string]interface{}{"emoji": emoji})
+		assert.Equal(t, http.StatusOK, w.Code, emoji)
+	}
+
+	// 3. Clearing a field and pinning
+	w = doRequest(r, "PUT", "/playlists/"+shelfID, apiKey, map[string]interface{}{"description": "", "pinned": true})
+	assert.Equal(t, http.StatusOK, w.Code)
+	var updated map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &updated)
+	assert.NotContains(t, updated, "description")
+	assert.Equal(t, "#aabbcc", updated["color"])
+	assert.Equal(t, true, updated["pinned"])
+
+	// 4. Pinned playlists come first, then manual order
+	doRequest(r, "POST", "/playlists", apiKey, map[string]interface{}{"name": "Second", "sort_order": 2})
+	doRequest(r, "POST", "/playlists", apiKey, map[string]interface{}{"name": "First", "sort_order": 1})
+
+	var names []string
+	cursor := ""
+	for i := 0; i < 3; i++ {
+		path := "/playlists?sort=manual&limit=1"
+		if cursor != "" {
+			path += "&cursor=" + cursor
+		}
+		w = doRequest(r, "GET", path, apiKey, nil)
+		var page struct {
+			Playlists  []map[string]interface{} `json:"playlists"`
+			NextCursor string                   `json:"next_cursor"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &page)
+		for _, pl := range page.Playlists {
+			names = append(names, pl["name"].(string))
+		}
+		cursor = page.NextCursor
+		if cursor == "" {
+			break
+		}
+	}
+	assert.Equal(t, []string{"Shelf", "First", "Second"}, names)
+}
+
+func TestConcurrentVideoAdds(t *testing.T) {
+	clearDatabase(t)
+	r := setupRouter()
+	apiKey := createTestUser(t)
+	id := createPlaylistWithVideos(t, r, apiKey, "Busy", []string{"keep"})
+
+	// Every add lands, on a playlist and on Watch Later
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		vid := "concurrent" + strconv.Itoa(i)
+		go func() {
+			defer wg.Done()
+			doRequest(r, "PATCH", "/playlists/"+id+"/add", apiKey, map[string]string{"video_id": vid})
+		}()
+		go func() {
+			defer wg.Done()
+			doRequest(r, "PATCH", "/watch-later/add", apiKey, map[string]string{"video_id": vid})
+		}()
+	}
+	wg.Wait()
+
+	w := doRequest(r, "GET", "/playlists", apiKey, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var list struct {
+		Playlists []struct {
+			ID       string   `json:"id"`
+			VideoIDs []string `json:"video_ids"`
+		} `json:"playlists"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &list)
+	var videoIDs []string
+	for _, p := range list.Playlists {
+		if p.ID == id {
+			videoIDs = p.VideoIDs
+		}
+	}
+	assert.Len(t, videoIDs, 11)
+
+	w = doRequest(r, "GET", "/watch-later", apiKey, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var wl struct {
+		VideoIDs []string `json:"video_ids"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &wl)
+	assert.Len(t, wl.VideoIDs, 10)
+}
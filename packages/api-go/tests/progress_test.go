@@ -0,0 +1,199 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/database"
+	"github.com/stretchr/testify/assert"
+)
+
+type progressResponse struct {
+	VideoID         string  `json:"video_id"`
+	PositionSeconds float64 `json:"position_seconds"`
+	Device          string  `json:"device"`
+	Applied         bool    `json:"applied"`
+}
+
+func TestProgressLastWriterWins(t *testing.T) {
+	clearDatabase(t)
+	r := setupRouter()
+	apiKey := createTestUser(t)
+
+	put := func(position float64, device, at string) progressResponse {
+		w := doRequest(r, "PUT", "/progress/vid1", apiKey, map[string]interface{}{
+			"position_seconds": position, "duration": 600, "device": device, "client_updated_at": at,
+		})
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp progressResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp
+	}
+
+	resp := put(120, "tv", "2024-01-01T10:00:00Z")
+	assert.True(t, resp.Applied)
+
+	// A write that happened earlier on another device loses, even if it arrives later
+	resp = put(30, "phone", "2024-01-01T09:00:00Z")
+	assert.False(t, resp.Applied)
+	assert.Equal(t, 120.0, resp.PositionSeconds)
+	assert.Equal(t, "tv", resp.Device)
+
+	resp = put(200, "phone", "2024-01-01T11:00:00Z")
+	assert.True(t, resp.Applied)
+
+	w := doRequest(r, "GET", "/progress?ids=vid1,vid2", apiKey, nil)
+	var lookup struct {
+		Progress []progressResponse `json:"progress"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &lookup)
+	if assert.Len(t, lookup.Progress, 1) {
+		assert.Equal(t, 200.0, lookup.Progress[0].PositionSeconds)
+	}
+
+	w = doRequest(r, "PUT", "/progress/vid1", apiKey, map[string]interface{}{"position_seconds": -1})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestContinueWatching(t *testing.T) {
+	clearDatabase(t)
+	r := setupRouter()
+	apiKey := createTestUser(t)
+
+	_, err := database.Pool.Exec(context.Background(), `
+		INSERT INTO fp_posts (id, title, creator_id, creator_name, channel_id, channel_title, channel_icon_url, thumbnail_url, video_duration, release_date)
+		VALUES ('cwPost', 'Continue Me', 'cw_creator', '', '', '', '', '', 1000, NOW())
+		ON CONFLICT (id) DO NOTHING
+	`)
+	assert.NoError(t, err)
+
+	for _, p := range []struct {
+		video    string
+		position float64
+		at       string
+	}{
+		{"cwPost", 300, "2024-01-01T10:00:00Z"},   // Duration from fp_posts
+		{"finished", 590, "2024-01-01T11:00:00Z"}, // Past 95%
+		{"unstarted", 0, "2024-01-01T12:00:00Z"},  // Not started
+		{"partial", 100, "2024-01-01T13:00:00Z"},  // Unknown post
+	} {
+		body := map[string]interface{}{"position_seconds": p.position, "client_updated_at": p.at}
+		if p.video != "cwPost" {
+			body["duration"] = 600
+		}
+		doRequest(r, "PUT", "/progress/"+p.video, apiKey, body)
+	}
+
+	w := doRequest(r, "GET", "/continue-watching", apiKey, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Items []struct {
+			VideoID string `json:"video_id"`
+			Post    *struct {
+				Title string `json:"title"`
+			} `json:"post"`
+		} `json:"items"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if assert.Len(t, resp.Items, 2) {
+		assert.Equal(t, "partial", resp.Items[0].VideoID)
+		assert.Nil(t, resp.Items[0].Post)
+		assert.Equal(t, "cwPost", resp.Items[1].VideoID)
+		if assert.NotNil(t, resp.Items[1].Post) {
+			assert.Equal(t, "Continue Me", resp.Items[1].Post.Title)
+		}
+	}
+}
+
+func TestFinishedVideosAreRemoved(t *testing.T) {
+	clearDatabase(t)
+	r := setupRouter()
+	apiKey := createTestUser(t)
+
+	for _, vid := range []string{"doneVid", "keepVid"} {
+		doRequest(r, "PATCH", "/watch-later/add", apiKey, map[string]string{"video_id": vid})
+	}
+	flagged := createPlaylistWithVideos(t, r, apiKey, "Flagged", []string{"doneVid", "keepVid"})
+	doRequest(r, "PUT", "/playlists/"+flagged, apiKey, map[string]interface{}{"remove_when_watched": true})
+	plain := createPlaylistWithVideos(t, r, apiKey, "Plain", []string{"doneVid"})
+
+	watchLater := func() []string {
+		var p struct {
+			VideoIDs []string `json:"video_ids"`
+		}
+		json.Unmarshal(doRequest(r, "GET", "/watch-later", apiKey, nil).Body.Bytes(), &p)
+		return p.VideoIDs
+	}
+	playlist := func(id string) []string {
+		var list struct {
+			Playlists []struct {
+				ID       string   `json:"id"`
+				VideoIDs []string `json:"video_ids"`
+			} `json:"playlists"`
+		}
+		json.Unmarshal(doRequest(r, "GET", "/playlists", apiKey, nil).Body.Bytes(), &list)
+		for _, p := range list.Playlists {
+			if p.ID == id {
+				return p.VideoIDs
+			}
+		}
+		return nil
+	}
+	type completionResponse struct {
+		Completed   bool     `json:"completed"`
+		RemovedFrom []string `json:"removed_from"`
+	}
+	put := func(video string, body map[string]interface{}) completionResponse {
+		w := doRequest(r, "PUT", "/progress/"+video, apiKey, body)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp completionResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp
+	}
+
+	// 1. Below the default 90% nothing happens
+	resp := put("doneVid", map[string]interface{}{"position_seconds": 500, "duration": 600})
+	assert.False(t, resp.Completed)
+	assert.Empty(t, resp.RemovedFrom)
+
+	// 2. Finishing removes it from Watch Later and the flagged playlist only
+	resp = put("doneVid", map[string]interface{}{"position_seconds": 550, "duration": 600})
+	assert.True(t, resp.Completed)
+	assert.Len(t, resp.RemovedFrom, 2)
+	assert.Contains(t, resp.RemovedFrom, flagged)
+	assert.Equal(t, []string{"keepVid"}, watchLater())
+	assert.Equal(t, []string{"keepVid"}, playlist(flagged))
+	assert.Equal(t, []string{"doneVid"}, playlist(plain))
+
+	// 3. Completed videos drop out of continue watching
+	w := doRequest(r, "GET", "/continue-watching", apiKey, nil)
+	var cw struct {
+		Count int `json:"count"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &cw)
+	assert.Equal(t, 0, cw.Count)
+
+	// 4. In credits mode the client decides, and Watch Later can be kept
+	w = doRequest(r, "PUT", "/completion-settings", apiKey, map[string]interface{}{"mode": "credits", "remove_from_watch_later": false})
+	assert.Equal(t, http.StatusOK, w.Code)
+	resp = put("keepVid", map[string]interface{}{"position_seconds": 590, "duration": 600})
+	assert.False(t, resp.Completed)
+	resp = put("keepVid", map[string]interface{}{"position_seconds": 560, "duration": 600, "credits_reached": true})
+	assert.True(t, resp.Completed)
+	assert.Equal(t, []string{flagged}, resp.RemovedFrom)
+	assert.Equal(t, []string{"keepVid"}, watchLater())
+
+	w = doRequest(r, "GET", "/completion-settings", apiKey, nil)
+	var settings struct {
+		Mode    string `json:"mode"`
+		Percent int    `json:"percent"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &settings)
+	assert.Equal(t, "credits", settings.Mode)
+	assert.Equal(t, 90, settings.Percent)
+
+	w = doRequest(r, "PUT", "/completion-settings", apiKey, map[string]interface{}{"percent": 0})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,112 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type queueItem struct {
+	ID      int64  `json:"id"`
+	VideoID string `json:"video_id"`
+}
+
+type queueResponse struct {
+	Items   []queueItem `json:"items"`
+	Current *struct {
+		VideoID         string   `json:"video_id"`
+		PositionSeconds *float64 `json:"position_seconds"`
+	} `json:"current"`
+	Version int64 `json:"version"`
+}
+
+func TestQueue(t *testing.T) {
+	clearDatabase(t)
+	r := setupRouter()
+	apiKey := createTestUser(t)
+
+	getQueue := func() queueResponse {
+		w := doRequest(r, "GET", "/queue", apiKey, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var q queueResponse
+		json.Unmarshal(w.Body.Bytes(), &q)
+		return q
+	}
+	order := func() []string {
+		var ids []string
+		for _, item := range getQueue().Items {
+			ids = append(ids, item.VideoID)
+		}
+		return ids
+	}
+	add := func(body map[string]interface{}) []queueItem {
+		w := doRequest(r, "POST", "/queue/items", apiKey, body)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		var resp struct {
+			Items []queueItem `json:"items"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp.Items
+	}
+
+	// 1. Append, insert next, insert after an item
+	items := add(map[string]interface{}{"video_ids": []string{"vidA", "vidB", "vidA"}})
+	assert.Len(t, items, 3)
+	add(map[string]interface{}{"video_ids": []string{"vidFirst"}, "next": true})
+	add(map[string]interface{}{"video_ids": []string{"vidMid"}, "after_item_id": items[0].ID})
+	assert.Equal(t, []string{"vidFirst", "vidA", "vidMid", "vidB", "vidA"}, order())
+
+	w := doRequest(r, "POST", "/queue/items", apiKey, map[string]interface{}{"video_ids": []string{"vidX"}, "after_item_id": 999999})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// 2. Move and remove
+	w = doRequest(r, "PATCH", "/queue/items/"+strconv.FormatInt(items[1].ID, 10), apiKey, map[string]interface{}{"next": true})
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = doRequest(r, "PATCH", "/queue/items/"+strconv.FormatInt(items[2].ID, 10), apiKey, map[string]interface{}{})
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = doRequest(r, "DELETE", "/queue/items/"+strconv.FormatInt(items[2].ID, 10), apiKey, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"vidB", "vidFirst", "vidA", "vidMid"}, order())
+
+	// 3. Many inserts into the same gap stay in order
+	after := items[0].ID
+	for i := 0; i < 60; i++ {
+		inserted := add(map[string]interface{}{"video_ids": []string{"gap" + strconv.Itoa(i)}, "after_item_id": after})
+		after = inserted[0].ID
+	}
+	ids := order()
+	assert.Equal(t, "vidA", ids[2])
+	assert.Equal(t, "gap0", ids[3])
+	assert.Equal(t, "gap59", ids[62])
+	assert.Equal(t, "vidMid", ids[63])
+
+	// 4. Pop makes the head current, with its progress for another device
+	doRequest(r, "PUT", "/progress/vidB", apiKey, map[string]interface{}{"position_seconds": 42})
+	w = doRequest(r, "POST", "/queue/pop", apiKey, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	q := getQueue()
+	assert.Equal(t, "vidFirst", q.Items[0].VideoID)
+	if assert.NotNil(t, q.Current) {
+		assert.Equal(t, "vidB", q.Current.VideoID)
+		if assert.NotNil(t, q.Current.PositionSeconds) {
+			assert.Equal(t, 42.0, *q.Current.PositionSeconds)
+		}
+	}
+
+	// 5. An unchanged version is not sent again
+	w = doRequest(r, "GET", "/queue?version="+strconv.FormatInt(q.Version, 10), apiKey, nil)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	doRequest(r, "PUT", "/queue/current", apiKey, map[string]interface{}{"video_id": nil})
+	w = doRequest(r, "GET", "/queue?version="+strconv.FormatInt(q.Version, 10), apiKey, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// 6. Clear empties it, and popping an empty queue is a 404
+	w = doRequest(r, "DELETE", "/queue", apiKey, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, getQueue().Items)
+	w = doRequest(r, "POST", "/queue/pop", apiKey, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
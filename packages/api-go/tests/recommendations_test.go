@@ -0,0 +1,90 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/database"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/services"
+	"github.com/stretchr/testify/assert"
+)
+
+type recommendationsResponse struct {
+	Recommendations []struct {
+		VideoID        string  `json:"video_id"`
+		Score          float64 `json:"score"`
+		BecauseVideoID string  `json:"because_video_id"`
+		Title          string  `json:"title"`
+		BecauseTitle   string  `json:"because_title"`
+	} `json:"recommendations"`
+	Count      int     `json:"count"`
+	ComputedAt *string `json:"computed_at"`
+}
+
+func TestRecommendations(t *testing.T) {
+	clearDatabase(t)
+	r := setupRouter()
+	apiKey := createTestUser(t)
+	bobKey := createNamedTestUser(t, "rec_bob")
+	carolKey := createNamedTestUser(t, "rec_carol")
+	daveKey := createNamedTestUser(t, "rec_dave")
+
+	_, err := database.Pool.Exec(context.Background(), `
+		INSERT INTO fp_posts (id, title, creator_id, creator_name, channel_id, channel_title, channel_icon_url, thumbnail_url, release_date)
+		VALUES ('recA', 'Seed', 'rec_creator', '', 'recOther', '', '', '', NOW()),
+		       ('recB', 'Other Channel', 'rec_creator', '', 'recOther', '', '', '', NOW()),
+		       ('recC', 'Favourite Channel', 'rec_creator', '', 'recFav', '', '', '', NOW()),
+		       ('recFav1', 'Fav One', 'rec_creator', '', 'recFav', '', '', '', NOW()),
+		       ('recFav2', 'Fav Two', 'rec_creator', '', 'recFav', '', '', '', NOW())
+		ON CONFLICT (id) DO UPDATE SET channel_id = EXCLUDED.channel_id
+	`)
+	assert.NoError(t, err)
+
+	get := func() recommendationsResponse {
+		w := doRequest(r, "GET", "/recommendations", apiKey, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp recommendationsResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp
+	}
+
+	// 1. Nothing before the first run
+	resp := get()
+	assert.Equal(t, 0, resp.Count)
+	assert.Nil(t, resp.ComputedAt)
+
+	// Bob's playlist and Carol's Watch Later both have A, B and C. Only
+	// Dave saved D with A.
+	createPlaylistWithVideos(t, r, bobKey, "Bob's", []string{"recA", "recB", "recC"})
+	for _, id := range []string{"recA", "recB", "recC"} {
+		doRequest(r, "PATCH", "/watch-later/add", carolKey, map[string]string{"video_id": id})
+	}
+	createPlaylistWithVideos(t, r, daveKey, "Dave's", []string{"recA", "recD"})
+	createPlaylistWithVideos(t, r, apiKey, "Mine", []string{"recA", "recFav1", "recFav2"})
+	assert.NoError(t, services.ComputeRecommendations())
+
+	// 2. B and C are recommended because of A; C ranks first as most of
+	// the user's saves are from its channel. D was only saved by one user.
+	resp = get()
+	assert.NotNil(t, resp.ComputedAt)
+	if assert.Equal(t, 2, resp.Count) {
+		assert.Equal(t, "recC", resp.Recommendations[0].VideoID)
+		assert.Equal(t, "recB", resp.Recommendations[1].VideoID)
+		assert.Greater(t, resp.Recommendations[0].Score, resp.Recommendations[1].Score)
+		assert.Equal(t, "recA", resp.Recommendations[0].BecauseVideoID)
+		assert.Equal(t, "Favourite Channel", resp.Recommendations[0].Title)
+		assert.Equal(t, "Seed", resp.Recommendations[0].BecauseTitle)
+	}
+
+	// 3. Saving a recommendation hides it before the next run
+	doRequest(r, "PATCH", "/watch-later/add", apiKey, map[string]string{"video_id": "recC"})
+	resp = get()
+	if assert.Equal(t, 1, resp.Count) {
+		assert.Equal(t, "recB", resp.Recommendations[0].VideoID)
+	}
+
+	w := doRequest(r, "GET", "/recommendations?limit=51", apiKey, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
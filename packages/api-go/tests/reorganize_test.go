@@ -0,0 +1,155 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/database"
+	"github.com/stretchr/testify/assert"
+)
+
+func createPlaylistWithVideos(t *testing.T, r http.Handler, apiKey, name string, videoIDs []string) string {
+	w := doRequest(r, "POST", "/playlists", apiKey, map[string]interface{}{"name": name, "video_ids": videoIDs})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var created map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	return created["id"].(string)
+}
+
+func TestDuplicatePlaylist(t *testing.T) {
+	clearDatabase(t)
+	r := setupRouter()
+	apiKey := createTestUser(t)
+	id := createPlaylistWithVideos(t, r, apiKey, "Original", []string{"vid1", "vid2"})
+
+	w := doRequest(r, "POST", "/playlists/"+id+"/duplicate", apiKey, nil)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var copied map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &copied)
+	assert.Equal(t, "Original (copy)", copied["name"])
+	assert.Equal(t, []interface{}{"vid1", "vid2"}, copied["video_ids"])
+	assert.NotEqual(t, id, copied["id"])
+
+	w = doRequest(r, "POST", "/playlists/"+id+"/duplicate", apiKey, map[string]string{"name": "Named"})
+	json.Unmarshal(w.Body.Bytes(), &copied)
+	assert.Equal(t, "Named", copied["name"])
+
+	otherKey := createNamedTestUser(t, "other_user")
+	w = doRequest(r, "POST", "/playlists/"+id+"/duplicate", otherKey, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Smart playlists are resolved in the copy too
+	w = doRequest(r, "POST", "/playlists", apiKey, map[string]interface{}{
+		"name":        "Smart",
+		"smart_rules": map[string]interface{}{"channel_ids": []string{"dup_channel"}},
+	})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var smart map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &smart)
+	w = doRequest(r, "POST", "/playlists/"+smart["id"].(string)+"/duplicate", apiKey, nil)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	json.Unmarshal(w.Body.Bytes(), &copied)
+	assert.Equal(t, true, copied["is_smart"])
+}
+
+func TestMergePlaylists(t *testing.T) {
+	clearDatabase(t)
+	r := setupRouter()
+	apiKey := createTestUser(t)
+	target := createPlaylistWithVideos(t, r, apiKey, "Target", []string{"vid1", "vid2"})
+	a := createPlaylistWithVideos(t, r, apiKey, "A", []string{"vid2", "vid3"})
+	b := createPlaylistWithVideos(t, r, apiKey, "B", []string{"vid4", "vid3", "vid1"})
+
+	w := doRequest(r, "POST", "/playlists/merge", apiKey, map[string]interface{}{
+		"target_id":      target,
+		"source_ids":     []string{a, b},
+		"delete_sources": true,
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Playlist struct {
+			VideoIDs []string `json:"video_ids"`
+		} `json:"playlist"`
+		Added int `json:"added"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.Equal(t, []string{"vid1", "vid2", "vid3", "vid4"}, resp.Playlist.VideoIDs)
+	assert.Equal(t, 2, resp.Added)
+
+	w = doRequest(r, "GET", "/playlists/trash", apiKey, nil)
+	assert.Contains(t, w.Body.String(), `"name":"A"`)
+	assert.Contains(t, w.Body.String(), `"name":"B"`)
+
+	// A missing source rolls everything back
+	c := createPlaylistWithVideos(t, r, apiKey, "C", []string{"vid9"})
+	w = doRequest(r, "POST", "/playlists/merge", apiKey, map[string]interface{}{
+		"target_id":      target,
+		"source_ids":     []string{c, "00000000-0000-0000-0000-000000000000"},
+		"delete_sources": true,
+	})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = doRequest(r, "GET", "/playlists/"+target+"/items", apiKey, nil)
+	assert.NotContains(t, w.Body.String(), "vid9")
+	w = doRequest(r, "GET", "/playlists/trash", apiKey, nil)
+	assert.NotContains(t, w.Body.String(), `"name":"C"`)
+
+	// The target can't be one of the sources
+	w = doRequest(r, "POST", "/playlists/merge", apiKey, map[string]interface{}{
+		"target_id": target, "source_ids": []string{target},
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestSplitPlaylist(t *testing.T) {
+	clearDatabase(t)
+	r := setupRouter()
+	apiKey := createTestUser(t)
+
+	for _, post := range []struct{ id, channel, title, released string }{
+		{"splitA1", "split_ch_a", "Channel A", "2023-03-01"},
+		{"splitB1", "split_ch_b", "Channel B", "2024-05-01"},
+		{"splitA2", "split_ch_a", "Channel A", "2024-06-01"},
+	} {
+		_, err := database.Pool.Exec(context.Background(), `
+			INSERT INTO fp_posts (id, title, creator_id, creator_name, channel_id, channel_title, channel_icon_url, thumbnail_url, release_date)
+			VALUES ($1, $1, 'split_creator', '', $2, $3, '', '', $4::date)
+			ON CONFLICT (id) DO NOTHING
+		`, post.id, post.channel, post.title, post.released)
+		assert.NoError(t, err)
+	}
+	id := createPlaylistWithVideos(t, r, apiKey, "Mix", []string{"splitA1", "splitB1", "unknownPost", "splitA2"})
+
+	split := func(by string) map[string][]string {
+		w := doRequest(r, "POST", "/playlists/"+id+"/split", apiKey, map[string]string{"by": by})
+		assert.Equal(t, http.StatusCreated, w.Code)
+		var resp struct {
+			Playlists []struct {
+				Name     string   `json:"name"`
+				VideoIDs []string `json:"video_ids"`
+			} `json:"playlists"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		groups := make(map[string][]string)
+		for _, p := range resp.Playlists {
+			groups[p.Name] = p.VideoIDs
+		}
+		return groups
+	}
+
+	assert.Equal(t, map[string][]string{
+		"Mix - Channel A": {"splitA1", "splitA2"},
+		"Mix - Channel B": {"splitB1"},
+		"Mix - Other":     {"unknownPost"},
+	}, split("channel"))
+
+	assert.Equal(t, map[string][]string{
+		"Mix - 2023":  {"splitA1"},
+		"Mix - 2024":  {"splitB1", "splitA2"},
+		"Mix - Other": {"unknownPost"},
+	}, split("year"))
+
+	w := doRequest(r, "POST", "/playlists/"+id+"/split", apiKey, map[string]string{"by": "creator"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
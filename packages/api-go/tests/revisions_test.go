@@ -0,0 +1,86 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type revisionsResponse struct {
+	Revisions []struct {
+		ID               int64    `json:"id"`
+		FloatplaneUserID string   `json:"floatplane_user_id"`
+		Action           string   `json:"action"`
+		Name             string   `json:"name"`
+		VideoIDs         []string `json:"video_ids"`
+	} `json:"revisions"`
+	Count int `json:"count"`
+}
+
+func TestPlaylistHistory(t *testing.T) {
+	clearDatabase(t)
+	r := setupRouter()
+	apiKey := createTestUser(t)
+
+	w := doRequest(r, "POST", "/playlists", apiKey, map[string]interface{}{
+		"name":      "History",
+		"video_ids": []string{"vid1", "vid2"},
+	})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var created map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	playlistID := created["id"].(string)
+
+	doRequest(r, "PATCH", "/playlists/"+playlistID+"/add", apiKey, map[string]string{"video_id": "vid3"})
+	// Adding an existing video changes nothing and isn't recorded
+	doRequest(r, "PATCH", "/playlists/"+playlistID+"/add", apiKey, map[string]string{"video_id": "vid3"})
+	doRequest(r, "PUT", "/playlists/"+playlistID, apiKey, map[string]interface{}{
+		"video_ids": []string{"vid3", "vid2", "vid1"},
+	})
+	doRequest(r, "PUT", "/playlists/"+playlistID, apiKey, map[string]interface{}{"name": "Renamed"})
+	doRequest(r, "PATCH", "/playlists/"+playlistID+"/remove", apiKey, map[string]string{"video_id": "vid1"})
+
+	w = doRequest(r, "GET", "/playlists/"+playlistID+"/history", apiKey, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var history revisionsResponse
+	json.Unmarshal(w.Body.Bytes(), &history)
+
+	var actions []string
+	for _, rev := range history.Revisions {
+		actions = append(actions, rev.Action)
+	}
+	assert.Equal(t, []string{"remove", "rename", "reorder", "add", "create"}, actions)
+	assert.NotEmpty(t, history.Revisions[0].FloatplaneUserID)
+
+	// Paging back
+	w = doRequest(r, "GET", "/playlists/"+playlistID+"/history?limit=2&before="+strconv.FormatInt(history.Revisions[1].ID, 10), apiKey, nil)
+	var page revisionsResponse
+	json.Unmarshal(w.Body.Bytes(), &page)
+	assert.Equal(t, 2, page.Count)
+	assert.Equal(t, "reorder", page.Revisions[0].Action)
+
+	// Revert to the original snapshot
+	original := history.Revisions[4]
+	w = doRequest(r, "POST", "/playlists/"+playlistID+"/revert", apiKey, map[string]interface{}{"revision_id": original.ID})
+	assert.Equal(t, http.StatusOK, w.Code)
+	var reverted map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &reverted)
+	assert.Equal(t, "History", reverted["name"])
+	assert.Equal(t, []interface{}{"vid1", "vid2"}, reverted["video_ids"])
+
+	w = doRequest(r, "GET", "/playlists/"+playlistID+"/history?limit=1", apiKey, nil)
+	json.Unmarshal(w.Body.Bytes(), &history)
+	assert.Equal(t, "revert", history.Revisions[0].Action)
+
+	// Unknown revisions and revisions from other playlists are rejected
+	w = doRequest(r, "POST", "/playlists/"+playlistID+"/revert", apiKey, map[string]interface{}{"revision_id": 999999})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Other users can't see the history
+	otherKey := createNamedTestUser(t, "other_user")
+	w = doRequest(r, "GET", "/playlists/"+playlistID+"/history", otherKey, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
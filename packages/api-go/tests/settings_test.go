@@ -0,0 +1,95 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/services"
+	"github.com/stretchr/testify/assert"
+)
+
+type settingsResponse struct {
+	Settings  map[string]interface{} `json:"settings"`
+	Sources   map[string]string      `json:"sources"`
+	ChangedAt map[string]string      `json:"changed_at"`
+}
+
+func TestSettings(t *testing.T) {
+	clearDatabase(t)
+	r := setupRouter()
+	apiKey := createTestUser(t)
+
+	get := func(path string) settingsResponse {
+		w := doRequest(r, "GET", path, apiKey, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var s settingsResponse
+		json.Unmarshal(w.Body.Bytes(), &s)
+		return s
+	}
+
+	// 1. Defaults
+	s := get("/settings")
+	assert.Equal(t, 1.0, s.Settings["playback_speed"])
+	assert.Equal(t, true, s.Settings["autoplay"])
+	assert.Equal(t, "default", s.Sources["playback_speed"])
+	assert.Empty(t, s.ChangedAt)
+
+	// 2. Account settings are inherited by a new device
+	w := doRequest(r, "PUT", "/settings", apiKey, map[string]interface{}{"playback_speed": 1.5, "hidden_channels": []string{"ch1"}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	s = get("/settings?device=tv")
+	assert.Equal(t, 1.5, s.Settings["playback_speed"])
+	assert.Equal(t, "account", s.Sources["playback_speed"])
+	assert.Equal(t, []interface{}{"ch1"}, s.Settings["hidden_channels"])
+	changed := s.ChangedAt["playback_speed"]
+	assert.NotEmpty(t, changed)
+
+	// 3. Device overrides apply to that device only
+	w = doRequest(r, "PATCH", "/settings?device=tv", apiKey, map[string]interface{}{"default_quality": "2160p"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2160p", get("/settings?device=tv").Settings["default_quality"])
+	assert.Equal(t, "auto", get("/settings?device=phone").Settings["default_quality"])
+
+	// 4. Unchanged values keep their timestamp, null falls back
+	w = doRequest(r, "PATCH", "/settings", apiKey, map[string]interface{}{"playback_speed": 1.5, "hidden_channels": nil})
+	assert.Equal(t, http.StatusOK, w.Code)
+	s = get("/settings")
+	assert.Equal(t, changed, s.ChangedAt["playback_speed"])
+	assert.Equal(t, "default", s.Sources["hidden_channels"])
+
+	// 5. The schema is enforced
+	for _, body := range []map[string]interface{}{
+		{"playback_speed": 10},
+		{"default_quality": "8k"},
+		{"autoplay": "yes"},
+		{"unknown_setting": 1},
+	} {
+		w = doRequest(r, "PATCH", "/settings", apiKey, body)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+
+	w = doRequest(r, "GET", "/settings/schema", apiKey, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestSettingNumberBounds(t *testing.T) {
+	min, max := 1.0, 10.0
+	services.SettingsSchema["test_min_only"] = services.SettingSpec{Type: services.SettingNumber, Default: 1.0, Min: &min}
+	services.SettingsSchema["test_max_only"] = services.SettingSpec{Type: services.SettingNumber, Default: 1.0, Max: &max}
+	defer delete(services.SettingsSchema, "test_min_only")
+	defer delete(services.SettingsSchema, "test_max_only")
+
+	_, err := services.ValidateSetting("test_min_only", json.RawMessage("0"))
+	assert.EqualError(t, err, "test_min_only must be at least 1")
+	_, err = services.ValidateSetting("test_min_only", json.RawMessage("1000"))
+	assert.NoError(t, err)
+
+	_, err = services.ValidateSetting("test_max_only", json.RawMessage("11"))
+	assert.EqualError(t, err, "test_max_only must be at most 10")
+	_, err = services.ValidateSetting("test_max_only", json.RawMessage("-5"))
+	assert.NoError(t, err)
+
+	_, err = services.ValidateSetting("playback_speed", json.RawMessage("5"))
+	assert.EqualError(t, err, "playback_speed must be between 0.25 and 4")
+}
@@ -1,9 +1,14 @@
 package tests
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"log"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 
@@ -54,6 +59,23 @@ func setupRouter() *chi.Mux {
 	return router.New()
 }
 
+// Helper to send an (optionally authenticated) JSON request through the router
+func doRequest(r http.Handler, method, path, apiKey string, payload interface{}) *httptest.ResponseRecorder {
+	var body io.Reader
+	if payload != nil {
+		b, _ := json.Marshal(payload)
+		body = bytes.NewReader(b)
+	}
+	req, _ := http.NewRequest(method, path, body)
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
 	// Helper to clear database state between tests
 func clearDatabase(t *testing.T) {
 	// Truncate relevant tables
@@ -64,6 +86,8 @@ func clearDatabase(t *testing.T) {
 		"TRUNCATE TABLE playlists CASCADE",
 		"TRUNCATE TABLE qr_sessions CASCADE",
 		"TRUNCATE TABLE users CASCADE",
+		"TRUNCATE TABLE sync_tombstones",
+		"TRUNCATE TABLE sync_operations",
 	}
 
 	for _, q := range queries {
@@ -76,7 +100,11 @@ func clearDatabase(t *testing.T) {
 
 // Helper to Create a User and get API Key for authenticated requests
 func createTestUser(t *testing.T) string {
-	userID := "test_user_" + fmt.Sprintf("%d", os.Getpid()) // pseudo random
+	return createNamedTestUser(t, "test_user_"+fmt.Sprintf("%d", os.Getpid())) // pseudo random
+}
+
+// Helper to create a specific user, for tests that need more than one
+func createNamedTestUser(t *testing.T, userID string) string {
 	apiKey := "test_api_key_" + userID
 
 	// Create User
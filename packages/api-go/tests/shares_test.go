@@ -0,0 +1,110 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlaylistSharing(t *testing.T) {
+	clearDatabase(t)
+	r := setupRouter()
+	apiKey := createTestUser(t)
+
+	// 1. Create Playlist
+	payload := map[string]interface{}{
+		"name":      "Shared List",
+		"video_ids": []string{"vid1", "vid2"},
+	}
+	body, _ := json.Marshal(payload)
+	req, _ := http.NewRequest("POST", "/playlists", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var created map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	playlistID := created["id"].(string)
+
+	// 2. Share it
+	req, _ = http.NewRequest("POST", "/playlists/"+playlistID+"/share", nil)
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var share map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &share)
+	token := share["token"].(string)
+	assert.NotEmpty(t, token)
+
+	// Sharing again returns the same token
+	req, _ = http.NewRequest("POST", "/playlists/"+playlistID+"/share", nil)
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), token)
+
+	// 3. Public read (no auth), owner ID is not exposed
+	req, _ = http.NewRequest("GET", "/shared/"+token, nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Shared List")
+	assert.NotContains(t, w.Body.String(), "floatplane_user_id")
+
+	// 4. Import into own playlists
+	req, _ = http.NewRequest("POST", "/shared/"+token+"/import", nil)
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var imported map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &imported)
+	assert.NotEqual(t, playlistID, imported["id"])
+	assert.Len(t, imported["video_ids"].([]interface{}), 2)
+
+	// 5. Revoke
+	req, _ = http.NewRequest("DELETE", "/playlists/"+playlistID+"/share", nil)
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	req, _ = http.NewRequest("GET", "/shared/"+token, nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestConcurrentSharesReturnOneToken(t *testing.T) {
+	clearDatabase(t)
+	r := setupRouter()
+	apiKey := createTestUser(t)
+	id := createPlaylistWithVideos(t, r, apiKey, "Shared", []string{"vid1"})
+
+	tokens := make([]string, 8)
+	var wg sync.WaitGroup
+	for i := range tokens {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w := doRequest(r, "POST", "/playlists/"+id+"/share", apiKey, nil)
+			var share map[string]interface{}
+			json.Unmarshal(w.Body.Bytes(), &share)
+			tokens[i], _ = share["token"].(string)
+		}(i)
+	}
+	wg.Wait()
+
+	assert.NotEmpty(t, tokens[0])
+	for _, token := range tokens {
+		assert.Equal(t, tokens[0], token)
+	}
+}
@@ -0,0 +1,72 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/database"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSmartPlaylist(t *testing.T) {
+	clearDatabase(t)
+	r := setupRouter()
+	apiKey := createTestUser(t)
+
+	// Seed posts: two long main-channel videos, one short one, one on another channel
+	posts := []struct {
+		id, channel string
+		duration    int
+		daysAgo     int
+	}{
+		{"smartLong1", "smart_main", 1800, 1},
+		{"smartLong2", "smart_main", 1200, 5},
+		{"smartShort", "smart_main", 300, 2},
+		{"smartOther", "smart_other", 1800, 1},
+	}
+	for _, p := range posts {
+		_, err := database.Pool.Exec(context.Background(), `
+			INSERT INTO fp_posts (id, title, creator_id, channel_id, has_video, video_duration, release_date)
+			VALUES ($1, $1, 'smart_creator', $2, true, $3, NOW() - make_interval(days => $4))
+			ON CONFLICT (id) DO NOTHING
+		`, p.id, p.channel, p.duration, p.daysAgo)
+		assert.NoError(t, err)
+	}
+
+	// 1. Create smart playlist: main channel, over 15 min, last 30 days
+	w := doRequest(r, "POST", "/playlists", apiKey, map[string]interface{}{
+		"name": "Long Main",
+		"smart_rules": map[string]interface{}{
+			"channel_ids":          []string{"smart_main"},
+			"min_duration":         900,
+			"released_within_days": 30,
+		},
+	})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var created map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	playlistID := created["id"].(string)
+	assert.Equal(t, true, created["is_smart"])
+	assert.Equal(t, []interface{}{"smartLong1", "smartLong2"}, created["video_ids"])
+
+	// 2. Appears in GET /playlists resolved
+	w = doRequest(r, "GET", "/playlists", apiKey, nil)
+	assert.Contains(t, w.Body.String(), "smartLong2")
+
+	// 3. Item edits are refused
+	w = doRequest(r, "PATCH", "/playlists/"+playlistID+"/add", apiKey, map[string]string{"video_id": "smartShort"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = doRequest(r, "PUT", "/playlists/"+playlistID, apiKey, map[string]interface{}{"video_ids": []string{"x"}})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// 4. Rules can be changed
+	w = doRequest(r, "PUT", "/playlists/"+playlistID, apiKey, map[string]interface{}{
+		"smart_rules": map[string]interface{}{"channel_ids": []string{"smart_other"}},
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+	json.Unmarshal(w.Body.Bytes(), &created)
+	assert.Equal(t, []interface{}{"smartOther"}, created["video_ids"])
+}
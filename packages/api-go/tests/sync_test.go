@@ -0,0 +1,246 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/database"
+	"github.com/stretchr/testify/assert"
+)
+
+type syncResponse struct {
+	Playlists []struct {
+		ID          string   `json:"id"`
+		Name        string   `json:"name"`
+		VideoIDs    []string `json:"video_ids"`
+		SyncVersion int64    `json:"sync_version"`
+	} `json:"playlists"`
+	Deleted []struct {
+		PlaylistID string `json:"playlist_id"`
+		Reason     string `json:"reason"`
+	} `json:"deleted"`
+	Cursor  string `json:"cursor"`
+	HasMore bool   `json:"has_more"`
+}
+
+type syncResultsResponse struct {
+	Results []struct {
+		OpID       string `json:"op_id"`
+		Status     string `json:"status"`
+		Code       int    `json:"code"`
+		PlaylistID string `json:"playlist_id"`
+	} `json:"results"`
+}
+
+func TestDeltaSync(t *testing.T) {
+	clearDatabase(t)
+	r := setupRouter()
+	apiKey := createTestUser(t)
+
+	w := doRequest(r, "POST", "/playlists", apiKey, map[string]interface{}{"name": "First"})
+	var first map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &first)
+	firstID := first["id"].(string)
+	doRequest(r, "POST", "/playlists", apiKey, map[string]interface{}{"name": "Second"})
+
+	// 1. Full sync, paged
+	w = doRequest(r, "GET", "/sync?limit=1", apiKey, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var page syncResponse
+	json.Unmarshal(w.Body.Bytes(), &page)
+	assert.Len(t, page.Playlists, 1)
+	assert.True(t, page.HasMore)
+
+	w = doRequest(r, "GET", "/sync?since="+page.Cursor, apiKey, nil)
+	json.Unmarshal(w.Body.Bytes(), &page)
+	assert.Len(t, page.Playlists, 1)
+	assert.False(t, page.HasMore)
+	cursor := page.Cursor
+
+	// 2. Nothing changed
+	w = doRequest(r, "GET", "/sync?since="+cursor, apiKey, nil)
+	json.Unmarshal(w.Body.Bytes(), &page)
+	assert.Empty(t, page.Playlists)
+	assert.Empty(t, page.Deleted)
+	cursor = page.Cursor
+
+	// 3. Updates and trashing show up as changes and tombstones
+	doRequest(r, "PATCH", "/playlists/"+firstID+"/add", apiKey, map[string]string{"video_id": "vid1"})
+	w = doRequest(r, "GET", "/sync?since="+cursor, apiKey, nil)
+	json.Unmarshal(w.Body.Bytes(), &page)
+	assert.Len(t, page.Playlists, 1)
+	assert.Equal(t, []string{"vid1"}, page.Playlists[0].VideoIDs)
+	cursor = page.Cursor
+
+	doRequest(r, "DELETE", "/playlists/"+firstID, apiKey, nil)
+	w = doRequest(r, "GET", "/sync?since="+cursor, apiKey, nil)
+	json.Unmarshal(w.Body.Bytes(), &page)
+	assert.Empty(t, page.Playlists)
+	assert.Len(t, page.Deleted, 1)
+	assert.Equal(t, firstID, page.Deleted[0].PlaylistID)
+	assert.Equal(t, "trashed", page.Deleted[0].Reason)
+
+	w = doRequest(r, "GET", "/sync?since=abc", apiKey, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = doRequest(r, "GET", "/sync?since=12.x", apiKey, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestSyncMemberRemovalTombstone(t *testing.T) {
+	clearDatabase(t)
+	r := setupRouter()
+	ownerKey := createNamedTestUser(t, "owner_user")
+	friendKey := createNamedTestUser(t, "friend_user")
+
+	w := doRequest(r, "POST", "/playlists", ownerKey, map[string]interface{}{"name": "Shared"})
+	var created map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	playlistID := created["id"].(string)
+
+	w = doRequest(r, "POST", "/playlists/"+playlistID+"/invites", ownerKey, map[string]string{"role": "viewer"})
+	var invite map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &invite)
+	doRequest(r, "POST", "/playlists/join", friendKey, map[string]interface{}{"code": invite["code"]})
+
+	var page syncResponse
+	w = doRequest(r, "GET", "/sync", friendKey, nil)
+	json.Unmarshal(w.Body.Bytes(), &page)
+	assert.Len(t, page.Playlists, 1)
+
+	doRequest(r, "DELETE", "/playlists/"+playlistID+"/members/friend_user", ownerKey, nil)
+	w = doRequest(r, "GET", "/sync?since="+page.Cursor, friendKey, nil)
+	json.Unmarshal(w.Body.Bytes(), &page)
+	assert.Empty(t, page.Playlists)
+	assert.Len(t, page.Deleted, 1)
+	assert.Equal(t, "removed", page.Deleted[0].Reason)
+}
+
+func TestSyncOfflineMutations(t *testing.T) {
+	clearDatabase(t)
+	r := setupRouter()
+	apiKey := createTestUser(t)
+
+	w := doRequest(r, "POST", "/playlists", apiKey, map[string]interface{}{"name": "Existing"})
+	var existing map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &existing)
+	existingID := existing["id"].(string)
+	baseVersion := existing["sync_version"]
+
+	// Changed on another device after the offline edit was made
+	doRequest(r, "PUT", "/playlists/"+existingID, apiKey, map[string]interface{}{"name": "Changed Elsewhere"})
+
+	queue := map[string]interface{}{
+		"operations": []map[string]interface{}{
+			{"op_id": "op1", "type": "create_playlist", "client_id": "tmp-1", "data": map[string]interface{}{"name": "Flight"}},
+			{"op_id": "op2", "type": "add_video", "playlist_id": "tmp-1", "data": map[string]string{"video_id": "vid1"}},
+			{"op_id": "op3", "type": "update_playlist", "playlist_id": existingID, "base_version": baseVersion, "data": map[string]string{"name": "Offline Name"}},
+			{"op_id": "op4", "type": "add_video", "playlist_id": "00000000-0000-0000-0000-000000000000", "data": map[string]string{"video_id": "vid1"}},
+			{"op_id": "op5", "type": "explode"},
+		},
+	}
+	w = doRequest(r, "POST", "/sync", apiKey, queue)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp syncResultsResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.Len(t, resp.Results, 5)
+	assert.Equal(t, "applied", resp.Results[0].Status)
+	assert.Equal(t, "applied", resp.Results[1].Status)
+	assert.Equal(t, resp.Results[0].PlaylistID, resp.Results[1].PlaylistID)
+	assert.Equal(t, "conflict", resp.Results[2].Status)
+	assert.Equal(t, "rejected", resp.Results[3].Status)
+	assert.Equal(t, http.StatusNotFound, resp.Results[3].Code)
+	assert.Equal(t, "rejected", resp.Results[4].Status)
+
+	// Replaying the queue doesn't create a second playlist
+	w = doRequest(r, "POST", "/sync", apiKey, queue)
+	var replay syncResultsResponse
+	json.Unmarshal(w.Body.Bytes(), &replay)
+	assert.Equal(t, resp.Results[0].PlaylistID, replay.Results[0].PlaylistID)
+
+	w = doRequest(r, "GET", "/playlists", apiKey, nil)
+	var list map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &list)
+	assert.Equal(t, float64(2), list["count"])
+}
+
+func TestSyncCursorWithOpenTransactions(t *testing.T) {
+	clearDatabase(t)
+	r := setupRouter()
+	apiKey := createTestUser(t)
+	slowID := createPlaylistWithVideos(t, r, apiKey, "Slow", nil)
+	fastID := createPlaylistWithVideos(t, r, apiKey, "Fast", nil)
+
+	w := doRequest(r, "GET", "/sync", apiKey, nil)
+	var page syncResponse
+	json.Unmarshal(w.Body.Bytes(), &page)
+	cursor := page.Cursor
+
+	// A long transaction writes first, then a short one writes and commits
+	ctx := context.Background()
+	slow, err := database.Pool.Begin(ctx)
+	assert.NoError(t, err)
+	defer slow.Rollback(ctx)
+	_, err = slow.Exec(ctx, `UPDATE playlists SET name = 'Slow Renamed' WHERE id = $1`, slowID)
+	assert.NoError(t, err)
+
+	_, err = database.Pool.Exec(ctx, `UPDATE playlists SET name = 'Fast Renamed' WHERE id = $1`, fastID)
+	assert.NoError(t, err)
+
+	// Syncing meanwhile mustn't move the cursor past the open write, so the
+	// short one is held back until the long one ends
+	w = doRequest(r, "GET", "/sync?since="+cursor, apiKey, nil)
+	json.Unmarshal(w.Body.Bytes(), &page)
+	seen := make(map[string]string)
+	for _, p := range page.Playlists {
+		seen[p.ID] = p.Name
+	}
+	assert.Empty(t, seen)
+	cursor = page.Cursor
+
+	assert.NoError(t, slow.Commit(ctx))
+
+	w = doRequest(r, "GET", "/sync?since="+cursor, apiKey, nil)
+	json.Unmarshal(w.Body.Bytes(), &page)
+	for _, p := range page.Playlists {
+		seen[p.ID] = p.Name
+	}
+	assert.Equal(t, map[string]string{slowID: "Slow Renamed", fastID: "Fast Renamed"}, seen)
+}
+
+func TestSyncConcurrentRetry(t *testing.T) {
+	clearDatabase(t)
+	r := setupRouter()
+	apiKey := createTestUser(t)
+
+	queue := map[string]interface{}{
+		"operations": []map[string]interface{}{
+			{"op_id": "retry1", "type": "create_playlist", "client_id": "tmp-1", "data": map[string]interface{}{"name": "Once"}},
+		},
+	}
+	// A flaky connection resends the queue while the first request runs
+	results := make([]syncResultsResponse, 4)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w := doRequest(r, "POST", "/sync", apiKey, queue)
+			json.Unmarshal(w.Body.Bytes(), &results[i])
+		}(i)
+	}
+	wg.Wait()
+
+	for _, resp := range results {
+		if assert.Len(t, resp.Results, 1) {
+			assert.Equal(t, "applied", resp.Results[0].Status)
+			assert.Equal(t, results[0].Results[0].PlaylistID, resp.Results[0].PlaylistID)
+		}
+	}
+	w := doRequest(r, "GET", "/playlists", apiKey, nil)
+	var list map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &list)
+	assert.Equal(t, float64(1), list["count"])
+}
@@ -0,0 +1,99 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/database"
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/services"
+	"github.com/stretchr/testify/assert"
+)
+
+type trendingResponse struct {
+	Window string `json:"window"`
+	Posts  []struct {
+		ID      string `json:"id"`
+		Title   string `json:"title"`
+		Rank    int    `json:"rank"`
+		SavedBy int    `json:"saved_by"`
+	} `json:"posts"`
+	Count      int     `json:"count"`
+	ComputedAt *string `json:"computed_at"`
+}
+
+func TestTrending(t *testing.T) {
+	clearDatabase(t)
+	r := setupRouter()
+	apiKey := createTestUser(t)
+	keys := []string{apiKey}
+	for i := 1; i <= 3; i++ {
+		keys = append(keys, createNamedTestUser(t, fmt.Sprintf("trend_user_%d", i)))
+	}
+
+	_, err := database.Pool.Exec(context.Background(), `
+		INSERT INTO fp_posts (id, title, creator_id, creator_name, channel_id, channel_title, channel_icon_url, thumbnail_url, release_date)
+		VALUES ('trHot', 'Hot', 'tr_creator', '', '', '', '', '', NOW()),
+		       ('trWarm', 'Warm', 'tr_creator', '', '', '', '', '', NOW())
+		ON CONFLICT (id) DO NOTHING
+	`)
+	assert.NoError(t, err)
+
+	get := func(query string) trendingResponse {
+		w := doRequest(r, "GET", "/trending"+query, apiKey, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp trendingResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp
+	}
+
+	// Four users add trHot, to Watch Later or a playlist; adding it to a
+	// second list doesn't count twice
+	for i, key := range keys {
+		if i%2 == 0 {
+			doRequest(r, "PATCH", "/watch-later/add", key, map[string]string{"video_id": "trHot"})
+		} else {
+			id := createPlaylistWithVideos(t, r, key, "Trending", []string{})
+			doRequest(r, "PATCH", "/playlists/"+id+"/add", key, map[string]string{"video_id": "trHot"})
+		}
+	}
+	doRequest(r, "PATCH", "/watch-later/add", keys[1], map[string]string{"video_id": "trHot"})
+
+	// Two users add trWarm today, a third did five days ago
+	for _, key := range keys[:2] {
+		doRequest(r, "PATCH", "/watch-later/add", key, map[string]string{"video_id": "trWarm"})
+	}
+	_, err = database.Pool.Exec(context.Background(), `
+		INSERT INTO video_adds (floatplane_user_id, video_id, added_at) VALUES ('trend_user_3', 'trWarm', $1)
+	`, time.Now().Add(-5*24*time.Hour))
+	assert.NoError(t, err)
+
+	// Creating a playlist with videos isn't a single add
+	createPlaylistWithVideos(t, r, keys[2], "Imported", []string{"trWarm"})
+
+	assert.NoError(t, services.ComputeTrending())
+
+	// 1. Below three users, trWarm isn't listed for the last day
+	resp := get("")
+	assert.Equal(t, "24h", resp.Window)
+	assert.NotNil(t, resp.ComputedAt)
+	if assert.Equal(t, 1, resp.Count) {
+		assert.Equal(t, "trHot", resp.Posts[0].ID)
+		assert.Equal(t, "Hot", resp.Posts[0].Title)
+		assert.Equal(t, 4, resp.Posts[0].SavedBy)
+		assert.Equal(t, 1, resp.Posts[0].Rank)
+	}
+
+	// 2. Over the week it is
+	resp = get("?window=7d")
+	if assert.Equal(t, 2, resp.Count) {
+		assert.Equal(t, "trWarm", resp.Posts[1].ID)
+		assert.Equal(t, 3, resp.Posts[1].SavedBy)
+	}
+
+	w := doRequest(r, "GET", "/trending?window=30d", apiKey, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
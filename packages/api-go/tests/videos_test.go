@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/database"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVideoIDValidation(t *testing.T) {
+	clearDatabase(t)
+	r := setupRouter()
+	apiKey := createTestUser(t)
+	id := createPlaylistWithVideos(t, r, apiKey, "Videos", nil)
+	t.Setenv("VIDEO_ID_VALIDATION", "format")
+
+	// 1. URLs are resolved to post IDs
+	w := doRequest(r, "PATCH", "/playlists/"+id+"/add", apiKey, map[string]string{"video_id": "https://www.floatplane.com/post/Abc123defG?t=30"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	var p struct {
+		VideoIDs        []string `json:"video_ids"`
+		UnknownVideoIDs []string `json:"unknown_video_ids"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &p)
+	assert.Equal(t, []string{"Abc123defG"}, p.VideoIDs)
+
+	// 2. Malformed IDs are rejected, including ones of the wrong length
+	for _, videoID := range []string{"not a video", "Abc123", "Abc123defGh", "https://www.floatplane.com/post/Abc123"} {
+		w = doRequest(r, "PATCH", "/playlists/"+id+"/add", apiKey, map[string]string{"video_id": videoID})
+		assert.Equal(t, http.StatusBadRequest, w.Code, videoID)
+	}
+	w = doRequest(r, "PUT", "/watch-later", apiKey, map[string]interface{}{"video_ids": []string{"okVideo001", "https://example.com/post/x"}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// 3. Unknown videos are stored but flagged
+	t.Setenv("VIDEO_ID_VALIDATION", "fp_posts")
+	_, err := database.Pool.Exec(context.Background(), `
+		INSERT INTO fp_posts (id, title, creator_id) VALUES ('knownPost1', 'Known', 'test_creator')
+		ON CONFLICT (id) DO NOTHING
+	`)
+	assert.NoError(t, err)
+
+	w = doRequest(r, "PUT", "/playlists/"+id, apiKey, map[string]interface{}{"video_ids": []string{"knownPost1", "missingPs1"}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	json.Unmarshal(w.Body.Bytes(), &p)
+	assert.Equal(t, []string{"knownPost1", "missingPs1"}, p.VideoIDs)
+	assert.Equal(t, []string{"missingPs1"}, p.UnknownVideoIDs)
+
+	w = doRequest(r, "GET", "/playlists/"+id+"/items", apiKey, nil)
+	var items struct {
+		Items []struct {
+			VideoID string `json:"video_id"`
+			Unknown bool   `json:"unknown"`
+		} `json:"items"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &items)
+	if assert.Len(t, items.Items, 2) {
+		assert.False(t, items.Items[0].Unknown)
+		assert.True(t, items.Items[1].Unknown)
+	}
+
+	// 4. off accepts anything non-empty
+	t.Setenv("VIDEO_ID_VALIDATION", "off")
+	w = doRequest(r, "PATCH", "/playlists/"+id+"/add", apiKey, map[string]string{"video_id": "legacy-id_1"})
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestVideoIDsStoredBeforeValidation(t *testing.T) {
+	clearDatabase(t)
+	r := setupRouter()
+	apiKey := createTestUser(t)
+
+	// 1. By default, free-form IDs are still accepted and URLs resolved
+	id := createPlaylistWithVideos(t, r, apiKey, "Legacy", []string{"wl_vid_1", "https://www.floatplane.com/post/Abc123defG"})
+	doRequest(r, "PATCH", "/watch-later/add", apiKey, map[string]string{"video_id": "old typo"})
+
+	// 2. Under a stricter mode, entries it would reject can still be removed
+	t.Setenv("VIDEO_ID_VALIDATION", "format")
+	w := doRequest(r, "PATCH", "/playlists/"+id+"/remove", apiKey, map[string]string{"video_id": "wl_vid_1"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	var p struct {
+		VideoIDs []string `json:"video_ids"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &p)
+	assert.Equal(t, []string{"Abc123defG"}, p.VideoIDs)
+
+	w = doRequest(r, "PATCH", "/watch-later/remove", apiKey, map[string]string{"video_id": "old typo"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	json.Unmarshal(w.Body.Bytes(), &p)
+	assert.Empty(t, p.VideoIDs)
+
+	// 3. A post given as both a URL and an ID is stored once
+	w = doRequest(r, "PUT", "/playlists/"+id, apiKey, map[string]interface{}{"video_ids": []string{"https://www.floatplane.com/post/Abc123defG", "Def456ghiJ", "Abc123defG"}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	json.Unmarshal(w.Body.Bytes(), &p)
+	assert.Equal(t, []string{"Abc123defG", "Def456ghiJ"}, p.VideoIDs)
+}
@@ -0,0 +1,76 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type watchHistoryPage struct {
+	Entries []struct {
+		ID      int64  `json:"id"`
+		VideoID string `json:"video_id"`
+	} `json:"entries"`
+	NextCursor string `json:"next_cursor"`
+}
+
+func TestWatchHistory(t *testing.T) {
+	clearDatabase(t)
+	r := setupRouter()
+	apiKey := createTestUser(t)
+
+	for _, e := range []struct{ video, at string }{
+		{"vid1", "2024-01-01T10:00:00Z"},
+		{"vid2", "2024-01-02T10:00:00Z"},
+		{"vid3", "2024-01-03T10:00:00Z"},
+	} {
+		w := doRequest(r, "POST", "/history", apiKey, map[string]interface{}{"video_id": e.video, "watched_at": e.at, "watched_seconds": 60})
+		assert.Equal(t, http.StatusCreated, w.Code)
+	}
+
+	// 1. Cursor pagination, newest first
+	page := func(path string) watchHistoryPage {
+		w := doRequest(r, "GET", path, apiKey, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var p watchHistoryPage
+		json.Unmarshal(w.Body.Bytes(), &p)
+		return p
+	}
+	first := page("/history?limit=2")
+	if assert.Len(t, first.Entries, 2) {
+		assert.Equal(t, "vid3", first.Entries[0].VideoID)
+		assert.Equal(t, "vid2", first.Entries[1].VideoID)
+	}
+	assert.NotEmpty(t, first.NextCursor)
+	second := page("/history?limit=2&cursor=" + first.NextCursor)
+	if assert.Len(t, second.Entries, 1) {
+		assert.Equal(t, "vid1", second.Entries[0].VideoID)
+	}
+	assert.Empty(t, second.NextCursor)
+
+	// 2. Deleting an entry and a date range
+	w := doRequest(r, "DELETE", "/history/"+strconv.FormatInt(first.Entries[0].ID, 10), apiKey, nil)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	w = doRequest(r, "DELETE", "/history?from=2024-01-02", apiKey, nil)
+	assert.Contains(t, w.Body.String(), `"deleted":1`)
+	w = doRequest(r, "DELETE", "/history", apiKey, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	all := page("/history")
+	if assert.Len(t, all.Entries, 1) {
+		assert.Equal(t, "vid1", all.Entries[0].VideoID)
+	}
+
+	// 3. Pausing stops recording
+	w = doRequest(r, "PUT", "/history/settings", apiKey, map[string]interface{}{"paused": true})
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = doRequest(r, "POST", "/history", apiKey, map[string]interface{}{"video_id": "vid4"})
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	// 4. Retention deletes older entries straight away
+	w = doRequest(r, "PUT", "/history/settings", apiKey, map[string]interface{}{"paused": false, "retention_days": 30})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, page("/history").Entries)
+}
@@ -2,11 +2,14 @@ package tests
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
 
+	"github.com/coulterpeterson/floatnative/packages/api-go/internal/database"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -45,3 +48,93 @@ func TestWatchLater(t *testing.T) {
 	assert.Len(t, vids, 1)
 	assert.Equal(t, "wl_vid_1", vids[0])
 }
+
+func TestWatchLaterIsUnique(t *testing.T) {
+	clearDatabase(t)
+	r := setupRouter()
+	apiKey := createTestUser(t)
+
+	// 1. Concurrent first requests create a single Watch Later
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			doRequest(r, "PATCH", "/watch-later/add", apiKey, map[string]string{"video_id": "vid1"})
+		}()
+	}
+	wg.Wait()
+
+	var count int
+	err := database.Pool.QueryRow(context.Background(), `SELECT COUNT(*) FROM playlists WHERE is_watch_later`).Scan(&count)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	// 2. PUT replaces the existing one
+	w := doRequest(r, "PUT", "/watch-later", apiKey, map[string]interface{}{"video_ids": []string{"vid2"}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	database.Pool.QueryRow(context.Background(), `SELECT COUNT(*) FROM playlists WHERE is_watch_later`).Scan(&count)
+	assert.Equal(t, 1, count)
+
+	// 3. The name is reserved
+	w = doRequest(r, "POST", "/playlists", apiKey, map[string]string{"name": " watch later"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	id := createPlaylistWithVideos(t, r, apiKey, "Later", nil)
+	w = doRequest(r, "PUT", "/playlists/"+id, apiKey, map[string]string{"name": "Watch Later"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestWatchLaterExpiry(t *testing.T) {
+	clearDatabase(t)
+	r := setupRouter()
+	apiKey := createTestUser(t)
+
+	for _, vid := range []string{"old1", "new1", "new2"} {
+		doRequest(r, "PATCH", "/watch-later/add", apiKey, map[string]string{"video_id": vid})
+	}
+	_, err := database.Pool.Exec(context.Background(), `
+		UPDATE playlist_items SET added_at = NOW() - INTERVAL '10 days' WHERE video_id = 'old1'
+	`)
+	assert.NoError(t, err)
+
+	// 1. Rules apply straight away
+	w := doRequest(r, "PUT", "/watch-later/expiry", apiKey, map[string]interface{}{"max_age_days": 7})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"expired":["old1"]`)
+
+	w = doRequest(r, "PUT", "/watch-later/expiry", apiKey, map[string]interface{}{"max_age_days": 7, "max_items": 1})
+	assert.Contains(t, w.Body.String(), `"expired":["new1"]`)
+
+	w = doRequest(r, "GET", "/watch-later", apiKey, nil)
+	var wl struct {
+		VideoIDs []string `json:"video_ids"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &wl)
+	assert.Equal(t, []string{"new2"}, wl.VideoIDs)
+
+	// 2. Expired items are listed with their reason
+	w = doRequest(r, "GET", "/watch-later/expired", apiKey, nil)
+	var expired struct {
+		Items []struct {
+			VideoID string `json:"video_id"`
+			Reason  string `json:"reason"`
+		} `json:"items"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &expired)
+	reasons := make(map[string]string)
+	for _, item := range expired.Items {
+		reasons[item.VideoID] = item.Reason
+	}
+	assert.Equal(t, map[string]string{"old1": "max_age", "new1": "max_items"}, reasons)
+
+	// 3. Restoring puts them back and removes them from the expired list
+	w = doRequest(r, "PUT", "/watch-later/expiry", apiKey, map[string]interface{}{"max_age_days": nil, "max_items": nil})
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = doRequest(r, "POST", "/watch-later/expired/restore", apiKey, map[string]interface{}{"video_ids": []string{"new1", "old1"}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	json.Unmarshal(w.Body.Bytes(), &wl)
+	assert.Equal(t, []string{"new2", "old1", "new1"}, wl.VideoIDs)
+
+	w = doRequest(r, "POST", "/watch-later/expired/restore", apiKey, map[string]interface{}{"video_ids": []string{"new1"}})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}